@@ -16,10 +16,13 @@ require (
 	github.com/KarpelesLab/typutil v0.2.29
 	github.com/KarpelesLab/xuid v0.1.8
 	github.com/ModChain/base58 v1.0.3
+	github.com/ModChain/bech32m v0.1.4
+	github.com/ModChain/edwards25519 v1.0.1
 	github.com/ModChain/ethrpc v0.2.3
 	github.com/ModChain/outscript v0.2.25
 	github.com/ModChain/secp256k1 v0.2.7
 	github.com/ModChain/tss-lib/v2 v2.1.4
+	github.com/coder/websocket v1.8.12
 	github.com/fxamacker/cbor/v2 v2.7.0
 	github.com/glebarez/go-sqlite v1.22.0
 	github.com/google/uuid v1.6.0
@@ -38,10 +41,7 @@ require (
 	github.com/KarpelesLab/putil v1.0.0 // indirect
 	github.com/KarpelesLab/ringslice v0.1.1 // indirect
 	github.com/KarpelesLab/webutil v0.2.2 // indirect
-	github.com/ModChain/bech32m v0.1.4 // indirect
-	github.com/ModChain/edwards25519 v1.0.1 // indirect
 	github.com/ModChain/rlp v0.1.3 // indirect
-	github.com/coder/websocket v1.8.12 // indirect
 	github.com/dustin/go-humanize v1.0.1 // indirect
 	github.com/gogo/protobuf v1.3.2 // indirect
 	github.com/google/go-cmp v0.6.0 // indirect
@@ -0,0 +1,161 @@
+package wltabi
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/EllipX/libwallet/wltintf"
+	"github.com/KarpelesLab/apirouter"
+	"github.com/KarpelesLab/pobj"
+	"github.com/KarpelesLab/xuid"
+)
+
+// ContractAbi is a user supplied JSON ABI used to decode calls to a given contract
+type ContractAbi struct {
+	Id       *xuid.XUID `json:"id" gorm:"primaryKey"`
+	Name     string     `json:"name"`
+	Network  *xuid.XUID `json:"network,omitempty" gorm:"index"` // if nil, applies to all networks
+	Contract string     `json:"contract" gorm:"index"`          // lowercase contract address, if empty applies to all contracts
+	Abi      string     `json:"abi"`                            // JSON ABI
+	Created  time.Time  `json:"created" gorm:"autoCreateTime"`
+	Updated  time.Time  `json:"updated" gorm:"autoUpdateTime"`
+}
+
+func init() {
+	pobj.RegisterActions[ContractAbi]("Abi",
+		&pobj.ObjectActions{
+			Fetch:  pobj.Static(apiFetchAbi),
+			List:   pobj.Static(apiListAbi),
+			Create: pobj.Static(apiCreateAbi),
+		},
+	)
+	pobj.RegisterStatic("Abi:decode", apiDecode)
+}
+
+func (a *ContractAbi) validate() error {
+	a.Contract = strings.ToLower(a.Contract)
+	if a.Contract != "" {
+		v, ok := strings.CutPrefix(a.Contract, "0x")
+		if !ok || len(v) != 40 {
+			return fmt.Errorf("invalid contract address %s", a.Contract)
+		}
+	}
+	methods, err := ParseJSON([]byte(a.Abi))
+	if err != nil {
+		return err
+	}
+	if len(methods) == 0 {
+		return errors.New("ABI does not contain any function")
+	}
+	return nil
+}
+
+// methods returns the functions of the ABI, tagged with its id as source
+func (a *ContractAbi) methods() []*Method {
+	res, err := ParseJSON([]byte(a.Abi))
+	if err != nil {
+		return nil
+	}
+	for _, m := range res {
+		m.source = a.Id.String()
+	}
+	return res
+}
+
+// Decode decodes calldata sent to contract on the given network, using matching user supplied ABIs
+// first and the built-in list of common methods next
+func Decode(e wltintf.Env, network *xuid.XUID, contract string, data []byte) (*Call, error) {
+	var list, generic []*ContractAbi
+	if contract != "" {
+		if err := e.Find(&list, map[string]any{"Contract": strings.ToLower(contract)}); err != nil {
+			return nil, err
+		}
+	}
+	if err := e.Find(&generic, map[string]any{"Contract": ""}); err != nil {
+		return nil, err
+	}
+
+	var methods []*Method
+	for _, a := range append(list, generic...) {
+		if a.Network != nil && (network == nil || a.Network.String() != network.String()) {
+			continue
+		}
+		methods = append(methods, a.methods()...)
+	}
+	return decodeWith(data, methods, 0)
+}
+
+func AbiById(e wltintf.Env, id *xuid.XUID) (*ContractAbi, error) {
+	if id.Prefix != "abi" {
+		return nil, fmt.Errorf("invalid key for abi: %s", id.Prefix)
+	}
+	return wltintf.ByPrimaryKey[ContractAbi](e, id)
+}
+
+func (a *ContractAbi) ApiDelete(ctx *apirouter.Context) error {
+	e := wltintf.GetEnv(ctx)
+	if e == nil {
+		return errors.New("failed to get env")
+	}
+
+	return e.Delete(a)
+}
+
+func apiListAbi(ctx *apirouter.Context) (any, error) {
+	return wltintf.ListHelper[ContractAbi](ctx, "Created DESC", "Contract", "Network")
+}
+
+func apiFetchAbi(ctx *apirouter.Context, in struct{ Id string }) (any, error) {
+	e := wltintf.GetEnv(ctx)
+	if e == nil {
+		return nil, errors.New("failed to get env")
+	}
+
+	id, err := xuid.Parse(in.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return AbiById(e, id)
+}
+
+func apiCreateAbi(ctx *apirouter.Context, a *ContractAbi) (any, error) {
+	e := wltintf.GetEnv(ctx)
+	if e == nil {
+		return nil, errors.New("failed to get env")
+	}
+
+	if err := a.validate(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	a.Id, err = xuid.NewRandom("abi")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := e.Save(a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func apiDecode(ctx *apirouter.Context, in struct {
+	Network  *xuid.XUID
+	Contract string
+	Data     string
+}) (any, error) {
+	e := wltintf.GetEnv(ctx)
+	if e == nil {
+		return nil, errors.New("failed to get env")
+	}
+
+	data, err := decodeHex(in.Data)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(e, in.Network, in.Contract, data)
+}
@@ -0,0 +1,168 @@
+package wltabi
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	buf, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatalf("bad hex: %s", err)
+	}
+	return buf
+}
+
+func TestSelectors(t *testing.T) {
+	tests := map[string]string{
+		"transfer(address to,uint256 amount)":                                                                     "a9059cbb",
+		"approve(address spender,uint256 amount)":                                                                 "095ea7b3",
+		"transferFrom(address from,address to,uint256 amount)":                                                    "23b872dd",
+		"setApprovalForAll(address operator,bool approved)":                                                       "a22cb465",
+		"safeTransferFrom(address from,address to,uint256 tokenId)":                                               "42842e0e",
+		"safeTransferFrom(address from,address to,uint256 tokenId,bytes data)":                                    "b88d4fde",
+		"multicall(bytes[] data)":                                                                                 "ac9650d8",
+		"execute(bytes commands,bytes[] inputs,uint256 deadline)":                                                 "3593564c",
+		"aggregate3((address target,bool allowFailure,bytes callData)[] calls)":                                   "82ad56cb",
+		"approve(address token,address spender,uint160 amount,uint48 expiration)":                                 "87517c45",
+		"swapExactTokensForTokens(uint256,uint256,address[],address,uint256)":                                     "38ed1739",
+		"safeBatchTransferFrom(address,address,uint256[],uint256[],bytes)":                                        "2eb2c2d6",
+		"function exactInputSingle(tuple(address,address,uint24,address,uint256,uint256,uint256,uint160) params)": "414bf389",
+	}
+	for sig, expect := range tests {
+		m, err := ParseSignature(sig)
+		if err != nil {
+			t.Errorf("failed to parse %s: %s", sig, err)
+			continue
+		}
+		if v := hex.EncodeToString(m.Selector()); v != expect {
+			t.Errorf("unexpected selector %s for %s (%s), expected %s", v, sig, m.Signature(), expect)
+		}
+	}
+}
+
+func TestParseType(t *testing.T) {
+	tests := map[string]string{
+		"uint":                           "uint256",
+		"bytes32[2][]":                   "bytes32[2][]",
+		"(address to, uint256 amount)[]": "(address,uint256)[]",
+		"tuple(bool,(string,int8))":      "(bool,(string,int8))",
+		"address payable":                "",
+		"uint7":                          "",
+		"bytes33":                        "",
+	}
+	for in, expect := range tests {
+		typ, err := ParseType(in)
+		if expect == "" {
+			if err == nil {
+				t.Errorf("expected %s to fail", in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("failed to parse %s: %s", in, err)
+			continue
+		}
+		if typ.String() != expect {
+			t.Errorf("unexpected type %s for %s, expected %s", typ, in, expect)
+		}
+	}
+}
+
+func TestDecodeBuiltin(t *testing.T) {
+	approve := "095ea7b3" +
+		"000000000000000000000000000000000022d473030f116ddee9f6b43ac78ba3" +
+		"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"
+
+	c, err := DecodeBuiltin(mustHex(t, approve))
+	if err != nil {
+		t.Fatalf("failed to decode approve: %s", err)
+	}
+	if c.Method != "approve" || c.Source != "builtin" || len(c.Args) != 2 {
+		t.Fatalf("unexpected call %+v", c)
+	}
+	if c.Args[0].Name != "spender" || c.Args[0].Value != "0x000000000022D473030F116dDEE9F6B43aC78BA3" {
+		t.Errorf("unexpected spender %+v", c.Args[0])
+	}
+	if c.Args[1].Value != "115792089237316195423570985008687907853269984665640564039457584007913129639935" {
+		t.Errorf("unexpected amount %+v", c.Args[1])
+	}
+
+	// multicall(bytes[]) wrapping the approve call
+	multicall := "ac9650d8" +
+		"0000000000000000000000000000000000000000000000000000000000000020" + // offset of data
+		"0000000000000000000000000000000000000000000000000000000000000001" + // 1 element
+		"0000000000000000000000000000000000000000000000000000000000000020" + // offset of element 0
+		"0000000000000000000000000000000000000000000000000000000000000044" + // 68 bytes
+		approve + "00000000000000000000000000000000000000000000000000000000"
+	c, err = DecodeBuiltin(mustHex(t, multicall))
+	if err != nil {
+		t.Fatalf("failed to decode multicall: %s", err)
+	}
+	if len(c.Calls) != 1 || c.Calls[0].Method != "approve" {
+		t.Fatalf("expected approve sub call, got %+v", c.Calls)
+	}
+
+	if _, err := DecodeBuiltin(mustHex(t, "deadbeef")); err != ErrUnknownMethod {
+		t.Errorf("expected unknown method, got %v", err)
+	}
+	// truncated data must fail rather than panic
+	if _, err := DecodeBuiltin(mustHex(t, multicall[:200])); err == nil {
+		t.Errorf("expected truncated multicall to fail")
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	abi := `[
+		{"type":"event","name":"Foo","inputs":[]},
+		{"type":"function","name":"submit","inputs":[
+			{"name":"orders","type":"tuple[]","components":[
+				{"name":"maker","type":"address"},
+				{"name":"amounts","type":"uint256[]"}
+			]},
+			{"name":"memo","type":"string"}
+		]}
+	]`
+	methods, err := ParseJSON([]byte(abi))
+	if err != nil {
+		t.Fatalf("failed to parse ABI: %s", err)
+	}
+	if len(methods) != 1 || methods[0].Signature() != "submit((address,uint256[])[],string)" {
+		t.Fatalf("unexpected methods %+v", methods)
+	}
+
+	data := hex.EncodeToString(methods[0].Selector()) +
+		"0000000000000000000000000000000000000000000000000000000000000040" + // offset of orders
+		"0000000000000000000000000000000000000000000000000000000000000120" + // offset of memo
+		"0000000000000000000000000000000000000000000000000000000000000001" + // 1 order
+		"0000000000000000000000000000000000000000000000000000000000000020" + // offset of order 0
+		"0000000000000000000000001111111111111111111111111111111111111111" + // maker
+		"0000000000000000000000000000000000000000000000000000000000000040" + // offset of amounts
+		"0000000000000000000000000000000000000000000000000000000000000002" + // 2 amounts
+		"0000000000000000000000000000000000000000000000000000000000000005" +
+		"0000000000000000000000000000000000000000000000000000000000000007" +
+		"0000000000000000000000000000000000000000000000000000000000000002" + // memo length
+		"6869000000000000000000000000000000000000000000000000000000000000" // "hi"
+
+	c, err := decodeWith(mustHex(t, data), methods, 0)
+	if err != nil {
+		t.Fatalf("failed to decode: %s", err)
+	}
+	orders := c.Args[0].Value.([]any)
+	if len(orders) != 1 {
+		t.Fatalf("expected 1 order, got %d", len(orders))
+	}
+	order := orders[0].([]*Arg)
+	if order[0].Name != "maker" || order[0].Value != "0x1111111111111111111111111111111111111111" {
+		t.Errorf("unexpected maker %+v", order[0])
+	}
+	amounts := order[1].Value.([]any)
+	if len(amounts) != 2 || amounts[0] != "5" || amounts[1] != "7" {
+		t.Errorf("unexpected amounts %+v", amounts)
+	}
+	if c.Args[1].Value != "hi" {
+		t.Errorf("unexpected memo %+v", c.Args[1])
+	}
+}
@@ -0,0 +1,165 @@
+package wltabi
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// builtinSignatures lists commonly used methods that can be decoded without an ABI
+var builtinSignatures = []string{
+	// ERC-20
+	"transfer(address to,uint256 amount)",
+	"approve(address spender,uint256 amount)",
+	"transferFrom(address from,address to,uint256 amount)", // also ERC-721
+	"increaseAllowance(address spender,uint256 addedValue)",
+	"decreaseAllowance(address spender,uint256 subtractedValue)",
+	"permit(address owner,address spender,uint256 value,uint256 deadline,uint8 v,bytes32 r,bytes32 s)",
+
+	// WETH
+	"deposit()",
+	"withdraw(uint256 amount)",
+
+	// ERC-721
+	"safeTransferFrom(address from,address to,uint256 tokenId)",
+	"safeTransferFrom(address from,address to,uint256 tokenId,bytes data)",
+	"setApprovalForAll(address operator,bool approved)", // also ERC-1155
+
+	// ERC-1155
+	"safeTransferFrom(address from,address to,uint256 id,uint256 amount,bytes data)",
+	"safeBatchTransferFrom(address from,address to,uint256[] ids,uint256[] amounts,bytes data)",
+
+	// Multicall
+	"multicall(bytes[] data)",
+	"multicall(uint256 deadline,bytes[] data)",
+	"multicall(bytes32 previousBlockhash,bytes[] data)",
+	"aggregate((address target,bytes callData)[] calls)",
+	"aggregate3((address target,bool allowFailure,bytes callData)[] calls)",
+	"tryAggregate(bool requireSuccess,(address target,bytes callData)[] calls)",
+
+	// Permit2
+	"approve(address token,address spender,uint160 amount,uint48 expiration)",
+	"permit(address owner,((address token,uint160 amount,uint48 expiration,uint48 nonce) details,address spender,uint256 sigDeadline) permitSingle,bytes signature)",
+	"permit(address owner,((address token,uint160 amount,uint48 expiration,uint48 nonce)[] details,address spender,uint256 sigDeadline) permitBatch,bytes signature)",
+	"transferFrom(address from,address to,uint160 amount,address token)",
+	"permitTransferFrom(((address token,uint256 amount) permitted,uint256 nonce,uint256 deadline) permit,(address to,uint256 requestedAmount) transferDetails,address owner,bytes signature)",
+	"lockdown((address token,address spender)[] approvals)",
+	"invalidateNonces(address token,address spender,uint48 newNonce)",
+
+	// Uniswap V2 router
+	"swapExactTokensForTokens(uint256 amountIn,uint256 amountOutMin,address[] path,address to,uint256 deadline)",
+	"swapTokensForExactTokens(uint256 amountOut,uint256 amountInMax,address[] path,address to,uint256 deadline)",
+	"swapExactETHForTokens(uint256 amountOutMin,address[] path,address to,uint256 deadline)",
+	"swapTokensForExactETH(uint256 amountOut,uint256 amountInMax,address[] path,address to,uint256 deadline)",
+	"swapExactTokensForETH(uint256 amountIn,uint256 amountOutMin,address[] path,address to,uint256 deadline)",
+	"swapETHForExactTokens(uint256 amountOut,address[] path,address to,uint256 deadline)",
+	"swapExactTokensForTokensSupportingFeeOnTransferTokens(uint256 amountIn,uint256 amountOutMin,address[] path,address to,uint256 deadline)",
+	"swapExactETHForTokensSupportingFeeOnTransferTokens(uint256 amountOutMin,address[] path,address to,uint256 deadline)",
+	"swapExactTokensForETHSupportingFeeOnTransferTokens(uint256 amountIn,uint256 amountOutMin,address[] path,address to,uint256 deadline)",
+	"addLiquidity(address tokenA,address tokenB,uint256 amountADesired,uint256 amountBDesired,uint256 amountAMin,uint256 amountBMin,address to,uint256 deadline)",
+	"addLiquidityETH(address token,uint256 amountTokenDesired,uint256 amountTokenMin,uint256 amountETHMin,address to,uint256 deadline)",
+	"removeLiquidity(address tokenA,address tokenB,uint256 liquidity,uint256 amountAMin,uint256 amountBMin,address to,uint256 deadline)",
+	"removeLiquidityETH(address token,uint256 liquidity,uint256 amountTokenMin,uint256 amountETHMin,address to,uint256 deadline)",
+
+	// Uniswap V3 SwapRouter
+	"exactInputSingle((address tokenIn,address tokenOut,uint24 fee,address recipient,uint256 deadline,uint256 amountIn,uint256 amountOutMinimum,uint160 sqrtPriceLimitX96) params)",
+	"exactInput((bytes path,address recipient,uint256 deadline,uint256 amountIn,uint256 amountOutMinimum) params)",
+	"exactOutputSingle((address tokenIn,address tokenOut,uint24 fee,address recipient,uint256 deadline,uint256 amountOut,uint256 amountInMaximum,uint160 sqrtPriceLimitX96) params)",
+	"exactOutput((bytes path,address recipient,uint256 deadline,uint256 amountOut,uint256 amountInMaximum) params)",
+
+	// Uniswap SwapRouter02
+	"exactInputSingle((address tokenIn,address tokenOut,uint24 fee,address recipient,uint256 amountIn,uint256 amountOutMinimum,uint160 sqrtPriceLimitX96) params)",
+	"exactInput((bytes path,address recipient,uint256 amountIn,uint256 amountOutMinimum) params)",
+	"exactOutputSingle((address tokenIn,address tokenOut,uint24 fee,address recipient,uint256 amountOut,uint256 amountInMaximum,uint160 sqrtPriceLimitX96) params)",
+	"exactOutput((bytes path,address recipient,uint256 amountOut,uint256 amountInMaximum) params)",
+	"unwrapWETH9(uint256 amountMinimum,address recipient)",
+	"unwrapWETH9(uint256 amountMinimum)",
+	"refundETH()",
+	"sweepToken(address token,uint256 amountMinimum,address recipient)",
+
+	// Uniswap Universal Router
+	"execute(bytes commands,bytes[] inputs,uint256 deadline)",
+	"execute(bytes commands,bytes[] inputs)",
+}
+
+// builtin maps hex encoded selectors to methods
+var builtin = func() map[string][]*Method {
+	res := make(map[string][]*Method)
+	for _, sig := range builtinSignatures {
+		m, err := ParseSignature(sig)
+		if err != nil {
+			panic(err)
+		}
+		m.source = "builtin"
+		k := hex.EncodeToString(m.selector)
+		res[k] = append(res[k], m)
+	}
+	return res
+}()
+
+// ErrUnknownMethod is returned when calldata cannot be decoded with any known method
+var ErrUnknownMethod = errors.New("unknown method")
+
+// DecodeBuiltin decodes calldata using the built-in list of common methods
+func DecodeBuiltin(data []byte) (*Call, error) {
+	return decodeWith(data, nil, 0)
+}
+
+// decodeWith tries the given methods first, then the built-in ones
+func decodeWith(data []byte, methods []*Method, depth int) (*Call, error) {
+	if len(data) < 4 {
+		return nil, ErrUnknownMethod
+	}
+	try := func(m *Method) *Call {
+		if string(m.selector) != string(data[:4]) {
+			return nil
+		}
+		c, err := m.Decode(data)
+		if err != nil {
+			return nil
+		}
+		c.Source = m.source
+		return c
+	}
+
+	var res *Call
+	for _, m := range methods {
+		if res = try(m); res != nil {
+			break
+		}
+	}
+	if res == nil {
+		for _, m := range builtin[hex.EncodeToString(data[:4])] {
+			if res = try(m); res != nil {
+				break
+			}
+		}
+	}
+	if res == nil {
+		return nil, ErrUnknownMethod
+	}
+
+	if strings.HasPrefix(res.Method, "multicall") && depth < 2 {
+		// sub calls are sent to the same contract
+		for _, a := range res.Args {
+			if a.Type != "bytes[]" {
+				continue
+			}
+			for _, v := range a.Value.([]any) {
+				buf, err := hex.DecodeString(strings.TrimPrefix(v.(string), "0x"))
+				if err != nil {
+					continue
+				}
+				sub, err := decodeWith(buf, methods, depth+1)
+				if err != nil {
+					sub = &Call{Method: "unknown"}
+					if len(buf) >= 4 {
+						sub.Selector = "0x" + hex.EncodeToString(buf[:4])
+					}
+				}
+				res.Calls = append(res.Calls, sub)
+			}
+		}
+	}
+	return res, nil
+}
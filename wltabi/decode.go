@@ -0,0 +1,197 @@
+package wltabi
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ModChain/outscript"
+)
+
+// maxDepth limits nesting of arrays and tuples when decoding
+const maxDepth = 16
+
+// Arg is a decoded argument. Value is a string for addresses, numbers (in base 10), bytes (hex) and
+// strings, a bool for bool, []any for arrays and []*Arg for tuples.
+type Arg struct {
+	Name  string `json:"name,omitempty"`
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
+var errShortData = errors.New("abi: data too short")
+
+func word(data []byte, pos int) ([]byte, error) {
+	if pos < 0 || pos+32 > len(data) {
+		return nil, errShortData
+	}
+	return data[pos : pos+32], nil
+}
+
+func readOffset(data []byte, pos int) (int, error) {
+	w, err := word(data, pos)
+	if err != nil {
+		return 0, err
+	}
+	v := new(big.Int).SetBytes(w)
+	if !v.IsUint64() || v.Uint64() > uint64(len(data)) {
+		return 0, errors.New("abi: invalid offset")
+	}
+	return int(v.Uint64()), nil
+}
+
+// DecodeArgs decodes ABI encoded values of the given types that are not prefixed with a selector, such as event data
+func DecodeArgs(types []*Type, data []byte) ([]*Arg, error) {
+	return decodeTuple(types, nil, data, 0, 0)
+}
+
+// decodeTuple decodes a list of values starting at base
+func decodeTuple(types []*Type, names []string, data []byte, base, depth int) ([]*Arg, error) {
+	if depth > maxDepth {
+		return nil, errors.New("abi: nesting too deep")
+	}
+	res := make([]*Arg, 0, len(types))
+	off := base
+	for i, t := range types {
+		pos := off
+		if t.dynamic() {
+			ptr, err := readOffset(data, off)
+			if err != nil {
+				return nil, err
+			}
+			pos = base + ptr
+		}
+		v, err := t.decodeAt(data, pos, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		arg := &Arg{Type: t.String(), Value: v}
+		if i < len(names) {
+			arg.Name = names[i]
+		}
+		res = append(res, arg)
+		off += t.headSize()
+	}
+	return res, nil
+}
+
+// decodeAt decodes a value of type t found at pos
+func (t *Type) decodeAt(data []byte, pos, depth int) (any, error) {
+	switch t.kind {
+	case kindUint, kindInt, kindAddress, kindBool, kindFixedBytes:
+		w, err := word(data, pos)
+		if err != nil {
+			return nil, err
+		}
+		return t.decodeWord(w)
+	case kindBytes, kindString:
+		ln, err := readOffset(data, pos)
+		if err != nil {
+			return nil, err
+		}
+		if pos+32+ln > len(data) {
+			return nil, errShortData
+		}
+		buf := data[pos+32 : pos+32+ln]
+		if t.kind == kindString {
+			return string(buf), nil
+		}
+		return "0x" + hex.EncodeToString(buf), nil
+	case kindArray:
+		ln := t.length
+		if ln < 0 {
+			var err error
+			ln, err = readOffset(data, pos)
+			if err != nil {
+				return nil, err
+			}
+			pos += 32
+		}
+		// each element takes at least 32 bytes, reject lengths that cannot fit in data
+		if ln*32 > len(data)-pos {
+			return nil, errShortData
+		}
+		types := make([]*Type, ln)
+		for i := range types {
+			types[i] = t.elem
+		}
+		args, err := decodeTuple(types, nil, data, pos, depth)
+		if err != nil {
+			return nil, err
+		}
+		res := make([]any, len(args))
+		for i, a := range args {
+			res[i] = a.Value
+		}
+		return res, nil
+	case kindTuple:
+		return decodeTuple(t.components, t.names, data, pos, depth)
+	}
+	return nil, fmt.Errorf("abi: cannot decode %s", t)
+}
+
+// decodeWord decodes a static value stored in a single word
+func (t *Type) decodeWord(w []byte) (any, error) {
+	v := new(big.Int).SetBytes(w)
+	switch t.kind {
+	case kindUint:
+		if v.BitLen() > t.size {
+			return nil, fmt.Errorf("abi: value out of range for %s", t)
+		}
+		return v.String(), nil
+	case kindInt:
+		if v.Bit(255) == 1 {
+			v.Sub(v, new(big.Int).Lsh(big.NewInt(1), 256))
+		}
+		limit := new(big.Int).Lsh(big.NewInt(1), uint(t.size-1))
+		if v.Cmp(limit) >= 0 || v.Cmp(new(big.Int).Neg(limit)) < 0 {
+			return nil, fmt.Errorf("abi: value out of range for %s", t)
+		}
+		return v.String(), nil
+	case kindAddress:
+		if v.BitLen() > 160 {
+			return nil, errors.New("abi: invalid address")
+		}
+		return formatAddress(w[12:]), nil
+	case kindBool:
+		switch {
+		case v.Sign() == 0:
+			return false, nil
+		case v.Cmp(big.NewInt(1)) == 0:
+			return true, nil
+		}
+		return nil, errors.New("abi: invalid bool")
+	case kindFixedBytes:
+		for _, c := range w[t.size:] {
+			if c != 0 {
+				return nil, fmt.Errorf("abi: invalid %s", t)
+			}
+		}
+		return "0x" + hex.EncodeToString(w[:t.size]), nil
+	}
+	return nil, fmt.Errorf("abi: cannot decode %s", t)
+}
+
+// formatAddress returns the EIP-55 form of a 20 bytes address
+func formatAddress(addr []byte) string {
+	s := "0x" + hex.EncodeToString(addr)
+	out, err := outscript.ParseEvmAddress(s)
+	if err != nil {
+		return s
+	}
+	if res, err := out.Address(); err == nil {
+		return res
+	}
+	return s
+}
+
+// decodeHex decodes a 0x prefixed hex string
+func decodeHex(s string) ([]byte, error) {
+	v, ok := strings.CutPrefix(s, "0x")
+	if !ok {
+		return nil, errors.New("hex data must start with 0x")
+	}
+	return hex.DecodeString(v)
+}
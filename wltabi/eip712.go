@@ -0,0 +1,384 @@
+package wltabi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/KarpelesLab/cryptutil"
+	"golang.org/x/crypto/sha3"
+)
+
+// TypedField is a field of a struct type in EIP-712 typed data
+type TypedField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// TypedData is EIP-712 typed data as passed to eth_signTypedData_v4
+type TypedData struct {
+	Types       map[string][]*TypedField `json:"types"`
+	PrimaryType string                   `json:"primaryType"`
+	Domain      map[string]any           `json:"domain"`
+	Message     map[string]any           `json:"message"`
+}
+
+// eip712DomainFields lists the fields of EIP712Domain in their standard order
+var eip712DomainFields = []*TypedField{
+	{Name: "name", Type: "string"},
+	{Name: "version", Type: "string"},
+	{Name: "chainId", Type: "uint256"},
+	{Name: "verifyingContract", Type: "address"},
+	{Name: "salt", Type: "bytes32"},
+}
+
+// ParseTypedData parses typed data given either as a JSON string or as an already decoded object
+func ParseTypedData(in any) (*TypedData, error) {
+	var buf []byte
+	switch v := in.(type) {
+	case string:
+		buf = []byte(v)
+	case []byte:
+		buf = v
+	default:
+		var err error
+		buf, err = json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var res *TypedData
+	if err := json.Unmarshal(buf, &res); err != nil {
+		return nil, fmt.Errorf("invalid typed data: %w", err)
+	}
+	if res == nil || res.PrimaryType == "" || res.Message == nil {
+		return nil, errors.New("invalid typed data: primaryType and message are required")
+	}
+	if _, ok := res.Types[res.PrimaryType]; !ok && res.PrimaryType != "EIP712Domain" {
+		return nil, fmt.Errorf("invalid typed data: unknown primary type %s", res.PrimaryType)
+	}
+	return res, nil
+}
+
+// UnmarshalJSON decodes numbers as json.Number so large integers keep their precision
+func (td *TypedData) UnmarshalJSON(b []byte) error {
+	type alias TypedData
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return dec.Decode((*alias)(td))
+}
+
+// ChainId returns the chain id of the domain, or nil if not specified
+func (td *TypedData) ChainId() (*big.Int, error) {
+	v, ok := td.Domain["chainId"]
+	if !ok || v == nil {
+		return nil, nil
+	}
+	return typedInt(v)
+}
+
+// Hash returns the EIP-712 hash to be signed: keccak256("\x19\x01" ‖ domainSeparator ‖ hashStruct(message))
+func (td *TypedData) Hash() ([]byte, error) {
+	domain, err := td.DomainSeparator()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash domain: %w", err)
+	}
+	buf := append([]byte{0x19, 0x01}, domain...)
+	if td.PrimaryType != "EIP712Domain" {
+		msg, err := td.HashStruct(td.PrimaryType, td.Message)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash message: %w", err)
+		}
+		buf = append(buf, msg...)
+	}
+	return keccak(buf), nil
+}
+
+// DomainSeparator returns hashStruct(domain)
+func (td *TypedData) DomainSeparator() ([]byte, error) {
+	return td.HashStruct("EIP712Domain", td.Domain)
+}
+
+// HashStruct returns keccak256(typeHash ‖ encodeData(data))
+func (td *TypedData) HashStruct(typ string, data map[string]any) ([]byte, error) {
+	enc, err := td.encodeData(typ, data, 0)
+	if err != nil {
+		return nil, err
+	}
+	return keccak(enc), nil
+}
+
+// fields returns the fields of a struct type. EIP712Domain is derived from the domain if not
+// explicitly defined.
+func (td *TypedData) fields(typ string) ([]*TypedField, bool) {
+	if f, ok := td.Types[typ]; ok {
+		return f, true
+	}
+	if typ != "EIP712Domain" {
+		return nil, false
+	}
+	var res []*TypedField
+	for _, f := range eip712DomainFields {
+		if _, ok := td.Domain[f.Name]; ok {
+			res = append(res, f)
+		}
+	}
+	return res, true
+}
+
+// baseType returns the type without any array suffix
+func baseType(typ string) string {
+	if pos := strings.IndexByte(typ, '['); pos != -1 {
+		return typ[:pos]
+	}
+	return typ
+}
+
+// dependencies adds to deps all struct types referenced by typ, recursively
+func (td *TypedData) dependencies(typ string, deps map[string]bool) {
+	typ = baseType(typ)
+	if deps[typ] {
+		return
+	}
+	fields, ok := td.fields(typ)
+	if !ok {
+		return
+	}
+	deps[typ] = true
+	for _, f := range fields {
+		td.dependencies(f.Type, deps)
+	}
+}
+
+// EncodeType returns the type encoding, for example Mail(Person from,Person to,string contents)Person(string name,address wallet)
+func (td *TypedData) EncodeType(typ string) string {
+	deps := make(map[string]bool)
+	td.dependencies(typ, deps)
+	delete(deps, typ)
+	list := make([]string, 0, len(deps))
+	for k := range deps {
+		list = append(list, k)
+	}
+	slices.Sort(list)
+	list = append([]string{typ}, list...)
+
+	var b strings.Builder
+	for _, t := range list {
+		fields, _ := td.fields(t)
+		b.WriteString(t)
+		b.WriteByte('(')
+		for i, f := range fields {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(f.Type)
+			b.WriteByte(' ')
+			b.WriteString(f.Name)
+		}
+		b.WriteByte(')')
+	}
+	return b.String()
+}
+
+// TypeHash returns keccak256(encodeType(typ))
+func (td *TypedData) TypeHash(typ string) []byte {
+	return keccak([]byte(td.EncodeType(typ)))
+}
+
+func (td *TypedData) encodeData(typ string, data map[string]any, depth int) ([]byte, error) {
+	if depth > maxDepth {
+		return nil, errors.New("typed data nesting too deep")
+	}
+	fields, ok := td.fields(typ)
+	if !ok {
+		return nil, fmt.Errorf("unknown type %s", typ)
+	}
+	res := td.TypeHash(typ)
+	for _, f := range fields {
+		v, err := td.encodeValue(f.Type, data[f.Name], depth)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", typ, f.Name, err)
+		}
+		res = append(res, v...)
+	}
+	return res, nil
+}
+
+// encodeValue returns the 32 bytes encoding of a value
+func (td *TypedData) encodeValue(typ string, v any, depth int) ([]byte, error) {
+	if strings.HasSuffix(typ, "]") {
+		// array: hash of the concatenated encoding of each element
+		pos := strings.LastIndexByte(typ, '[')
+		elem := typ[:pos]
+		items, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("expected array for %s", typ)
+		}
+		if n := typ[pos+1 : len(typ)-1]; n != "" {
+			if ln, err := strconv.Atoi(n); err != nil || ln != len(items) {
+				return nil, fmt.Errorf("expected %s elements for %s", n, typ)
+			}
+		}
+		var buf []byte
+		for _, item := range items {
+			enc, err := td.encodeValue(elem, item, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			buf = append(buf, enc...)
+		}
+		return keccak(buf), nil
+	}
+
+	if _, ok := td.Types[typ]; ok {
+		if v == nil {
+			return make([]byte, 32), nil
+		}
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expected object for %s", typ)
+		}
+		enc, err := td.encodeData(typ, m, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		return keccak(enc), nil
+	}
+
+	if v == nil {
+		return nil, errors.New("missing value")
+	}
+
+	switch typ {
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.New("expected string")
+		}
+		return keccak([]byte(s)), nil
+	case "bytes":
+		buf, err := typedBytes(v)
+		if err != nil {
+			return nil, err
+		}
+		return keccak(buf), nil
+	}
+
+	t, err := ParseType(typ)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]byte, 32)
+	switch t.kind {
+	case kindAddress:
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.New("expected address")
+		}
+		buf, err := decodeHex(strings.ToLower(s))
+		if err != nil || len(buf) != 20 {
+			return nil, fmt.Errorf("invalid address %s", s)
+		}
+		copy(res[12:], buf)
+	case kindBool:
+		b, ok := v.(bool)
+		if !ok {
+			return nil, errors.New("expected bool")
+		}
+		if b {
+			res[31] = 1
+		}
+	case kindUint, kindInt:
+		n, err := typedInt(v)
+		if err != nil {
+			return nil, err
+		}
+		if t.kind == kindUint {
+			if n.Sign() < 0 || n.BitLen() > t.size {
+				return nil, fmt.Errorf("value out of range for %s", typ)
+			}
+		} else {
+			limit := new(big.Int).Lsh(big.NewInt(1), uint(t.size-1))
+			if n.Cmp(limit) >= 0 || n.Cmp(new(big.Int).Neg(limit)) < 0 {
+				return nil, fmt.Errorf("value out of range for %s", typ)
+			}
+			if n.Sign() < 0 {
+				n = new(big.Int).Add(n, new(big.Int).Lsh(big.NewInt(1), 256))
+			}
+		}
+		n.FillBytes(res)
+	case kindFixedBytes:
+		buf, err := typedBytes(v)
+		if err != nil {
+			return nil, err
+		}
+		if len(buf) > t.size {
+			return nil, fmt.Errorf("value too long for %s", typ)
+		}
+		copy(res, buf)
+	default:
+		return nil, fmt.Errorf("unsupported type %s", typ)
+	}
+	return res, nil
+}
+
+// maxTypedIntLen is the maximum length of the string form of an integer, enough for any 256 bits value
+const maxTypedIntLen = 100
+
+// typedInt parses an integer given as a JSON number, or a decimal or 0x prefixed hex string
+func typedInt(v any) (*big.Int, error) {
+	var s string
+	switch n := v.(type) {
+	case json.Number:
+		s = n.String()
+	case string:
+		s = n
+	case float64:
+		s = strconv.FormatFloat(n, 'f', -1, 64)
+	default:
+		return nil, fmt.Errorf("expected integer, got %T", v)
+	}
+	if len(s) > maxTypedIntLen {
+		return nil, errors.New("integer too long")
+	}
+	if len(s) > 2 && (s[:2] == "0x" || s[:2] == "0X") {
+		res, ok := new(big.Int).SetString(s[2:], 16)
+		if !ok || s[2] == '-' || s[2] == '+' {
+			return nil, fmt.Errorf("invalid integer %s", s)
+		}
+		return res, nil
+	}
+	// base 10 only: base 0 would read a leading zero as octal and accept 0b, 0o and underscores
+	res, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		// SetString rejects values such as 1e18
+		f, _, err := big.ParseFloat(s, 10, 512, big.ToNearestEven)
+		if err != nil || !f.IsInt() {
+			return nil, fmt.Errorf("invalid integer %s", s)
+		}
+		// check the size before converting, as exponents allow huge values
+		if f.MantExp(nil) > 256 {
+			return nil, fmt.Errorf("integer %s out of range", s)
+		}
+		res, _ = f.Int(nil)
+	}
+	return res, nil
+}
+
+// typedBytes parses bytes given as a 0x prefixed hex string
+func typedBytes(v any) ([]byte, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, errors.New("expected hex string")
+	}
+	return decodeHex(s)
+}
+
+func keccak(buf []byte) []byte {
+	return cryptutil.Hash(buf, sha3.NewLegacyKeccak256)
+}
@@ -0,0 +1,154 @@
+package wltabi
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const testTypedMail = `{
+	"types": {
+		"EIP712Domain": [
+			{"name": "name", "type": "string"},
+			{"name": "version", "type": "string"},
+			{"name": "chainId", "type": "uint256"},
+			{"name": "verifyingContract", "type": "address"}
+		],
+		"Person": [
+			{"name": "name", "type": "string"},
+			{"name": "wallet", "type": "address"}
+		],
+		"Mail": [
+			{"name": "from", "type": "Person"},
+			{"name": "to", "type": "Person"},
+			{"name": "contents", "type": "string"}
+		]
+	},
+	"primaryType": "Mail",
+	"domain": {
+		"name": "Ether Mail",
+		"version": "1",
+		"chainId": 1,
+		"verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+	},
+	"message": {
+		"from": {"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+		"to": {"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+		"contents": "Hello, Bob!"
+	}
+}`
+
+func TestTypedDataHash(t *testing.T) {
+	td, err := ParseTypedData(testTypedMail)
+	if err != nil {
+		t.Fatalf("failed to parse typed data: %s", err)
+	}
+
+	if v := td.EncodeType("Mail"); v != "Mail(Person from,Person to,string contents)Person(string name,address wallet)" {
+		t.Errorf("unexpected type encoding %s", v)
+	}
+	if v := hex.EncodeToString(td.TypeHash("Mail")); v != "a0cedeb2dc280ba39b857546d74f5549c3a1d7bdc2dd96bf881f76108e23dac2" {
+		t.Errorf("unexpected type hash %s", v)
+	}
+	domain, err := td.DomainSeparator()
+	if err != nil {
+		t.Fatalf("failed to hash domain: %s", err)
+	}
+	if v := hex.EncodeToString(domain); v != "f2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f" {
+		t.Errorf("unexpected domain separator %s", v)
+	}
+	msg, err := td.HashStruct("Mail", td.Message)
+	if err != nil {
+		t.Fatalf("failed to hash message: %s", err)
+	}
+	if v := hex.EncodeToString(msg); v != "c52c0ee5d84264471806290a3f2c4cecfc5490626bf912d01f240d7a274b371e" {
+		t.Errorf("unexpected message hash %s", v)
+	}
+	hash, err := td.Hash()
+	if err != nil {
+		t.Fatalf("failed to hash: %s", err)
+	}
+	if v := hex.EncodeToString(hash); v != "be609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2" {
+		t.Errorf("unexpected hash %s", v)
+	}
+
+	// EIP712Domain omitted from types is derived from the domain fields
+	delete(td.Types, "EIP712Domain")
+	domain2, err := td.DomainSeparator()
+	if err != nil || hex.EncodeToString(domain2) != hex.EncodeToString(domain) {
+		t.Errorf("derived domain separator does not match: %x", domain2)
+	}
+}
+
+func TestTypedDataArrays(t *testing.T) {
+	td, err := ParseTypedData(map[string]any{
+		"types": map[string]any{
+			"Group": []any{
+				map[string]any{"name": "members", "type": "Member[]"},
+				map[string]any{"name": "ids", "type": "uint256[2]"},
+			},
+			"Member": []any{
+				map[string]any{"name": "wallet", "type": "address"},
+			},
+		},
+		"primaryType": "Group",
+		"domain":      map[string]any{"name": "Test"},
+		"message": map[string]any{
+			"members": []any{map[string]any{"wallet": "0x1111111111111111111111111111111111111111"}},
+			"ids":     []any{"1", "0x115792089237316195423570985008687907853269984665640564039457584007913129639935"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to parse typed data: %s", err)
+	}
+	if _, err := td.Hash(); err == nil {
+		t.Errorf("expected out of range value to fail")
+	}
+
+	td.Message["ids"] = []any{"1", "115792089237316195423570985008687907853269984665640564039457584007913129639935"}
+	if _, err := td.Hash(); err != nil {
+		t.Errorf("failed to hash: %s", err)
+	}
+	td.Message["ids"] = []any{"1"}
+	if _, err := td.Hash(); err == nil {
+		t.Errorf("expected wrong array length to fail")
+	}
+}
+
+func TestTypedInt(t *testing.T) {
+	tests := []struct {
+		in     any
+		expect string // empty if an error is expected
+	}{
+		{"1e18", "1000000000000000000"},
+		{"0x10", "16"},
+		{"0X1f", "31"},
+		{"010", "10"},
+		{"0b101", ""},
+		{"0o17", ""},
+		{"1_000", ""},
+		{"0x", ""},
+		{"0x-1", ""},
+		{json.Number("42"), "42"},
+		{"1e600000000", ""},
+		{"1e77", "100000000000000000000000000000000000000000000000000000000000000000000000000000"},
+		{"1e78", ""},
+		{"1" + strings.Repeat("0", 200), ""},
+		{"1.5", ""},
+	}
+	for _, test := range tests {
+		res, err := typedInt(test.in)
+		if test.expect == "" {
+			if err == nil {
+				t.Errorf("%v: expected error, got %s", test.in, res)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error %s", test.in, err)
+		} else if res.String() != test.expect {
+			t.Errorf("%v: expected %s, got %s", test.in, test.expect, res)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+package wltabi
+
+import "github.com/EllipX/libwallet/wltintf"
+
+func InitEnv(e wltintf.Env) {
+	e.AutoMigrate(&ContractAbi{})
+}
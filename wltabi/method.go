@@ -0,0 +1,150 @@
+package wltabi
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/KarpelesLab/cryptutil"
+	"golang.org/x/crypto/sha3"
+)
+
+// Method is a contract function calldata can be decoded against
+type Method struct {
+	Name     string
+	Inputs   []*Type
+	Names    []string // names of inputs, may be empty
+	selector []byte
+	source   string // where the method comes from, see Call.Source
+}
+
+// Call is the result of decoding calldata
+type Call struct {
+	Selector  string  `json:"selector"`
+	Method    string  `json:"method"`
+	Signature string  `json:"signature"`
+	Source    string  `json:"source"` // "builtin", or the id of the user supplied ABI
+	Args      []*Arg  `json:"args"`
+	Calls     []*Call `json:"calls,omitempty"`   // decoded sub calls, for multicall methods
+	Summary   string  `json:"summary,omitempty"` // human readable description, if available
+}
+
+// ParseSignature parses a human readable signature such as transfer(address to,uint256 amount)
+func ParseSignature(sig string) (*Method, error) {
+	sig = strings.TrimSpace(sig)
+	sig = strings.TrimPrefix(sig, "function ")
+	pos := strings.IndexByte(sig, '(')
+	if pos <= 0 {
+		return nil, fmt.Errorf("invalid method signature %s", sig)
+	}
+	p := &typeParser{s: sig, pos: pos}
+	types, names, err := p.parseList()
+	if err != nil {
+		return nil, fmt.Errorf("invalid method signature %s: %w", sig, err)
+	}
+	return newMethod(strings.TrimSpace(sig[:pos]), types, names), nil
+}
+
+func newMethod(name string, types []*Type, names []string) *Method {
+	m := &Method{Name: name, Inputs: types, Names: names}
+	m.selector = cryptutil.Hash([]byte(m.Signature()), sha3.NewLegacyKeccak256)[:4]
+	return m
+}
+
+// Signature returns the canonical signature of the method
+func (m *Method) Signature() string {
+	return m.Name + "(" + joinTypes(m.Inputs) + ")"
+}
+
+// Selector returns the 4 bytes selector of the method
+func (m *Method) Selector() []byte {
+	return m.selector
+}
+
+// Decode decodes calldata, including its selector
+func (m *Method) Decode(data []byte) (*Call, error) {
+	if len(data) < 4 || string(data[:4]) != string(m.selector) {
+		return nil, errors.New("abi: selector does not match")
+	}
+	args, err := decodeTuple(m.Inputs, m.Names, data[4:], 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &Call{
+		Selector:  "0x" + hex.EncodeToString(m.selector),
+		Method:    m.Name,
+		Signature: m.Signature(),
+		Args:      args,
+	}, nil
+}
+
+type jsonParam struct {
+	Name       string       `json:"name"`
+	Type       string       `json:"type"`
+	Components []*jsonParam `json:"components"`
+}
+
+type jsonEntry struct {
+	Type   string       `json:"type"`
+	Name   string       `json:"name"`
+	Inputs []*jsonParam `json:"inputs"`
+}
+
+// ParseJSON returns the functions found in a JSON ABI
+func ParseJSON(buf []byte) ([]*Method, error) {
+	var entries []*jsonEntry
+	if err := json.Unmarshal(buf, &entries); err != nil {
+		return nil, fmt.Errorf("invalid ABI: %w", err)
+	}
+	var res []*Method
+	for _, ent := range entries {
+		if ent.Type != "function" && ent.Type != "" {
+			continue
+		}
+		if ent.Name == "" {
+			return nil, errors.New("invalid ABI: function without a name")
+		}
+		types, names, err := jsonParams(ent.Inputs)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ABI for %s: %w", ent.Name, err)
+		}
+		res = append(res, newMethod(ent.Name, types, names))
+	}
+	return res, nil
+}
+
+func jsonParams(params []*jsonParam) ([]*Type, []string, error) {
+	types := make([]*Type, 0, len(params))
+	names := make([]string, 0, len(params))
+	for _, prm := range params {
+		t, err := prm.parse()
+		if err != nil {
+			return nil, nil, err
+		}
+		types = append(types, t)
+		names = append(names, prm.Name)
+	}
+	return types, names, nil
+}
+
+func (prm *jsonParam) parse() (*Type, error) {
+	dims, ok := strings.CutPrefix(prm.Type, "tuple")
+	if !ok {
+		return ParseType(prm.Type)
+	}
+	comps, names, err := jsonParams(prm.Components)
+	if err != nil {
+		return nil, err
+	}
+	p := &typeParser{s: dims}
+	t, err := p.parseDims(&Type{kind: kindTuple, components: comps, names: names})
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(dims) {
+		return nil, fmt.Errorf("invalid type %s", prm.Type)
+	}
+	return t, nil
+}
@@ -0,0 +1,282 @@
+package wltabi
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type kind int
+
+const (
+	kindUint kind = iota
+	kindInt
+	kindAddress
+	kindBool
+	kindFixedBytes
+	kindBytes
+	kindString
+	kindArray
+	kindTuple
+)
+
+// Type is a solidity ABI type
+type Type struct {
+	kind       kind
+	size       int      // bits for int/uint, length for fixed bytes
+	elem       *Type    // array element
+	length     int      // array length, -1 for dynamic arrays
+	components []*Type  // tuple components
+	names      []string // tuple component names, may be empty
+}
+
+// ParseType parses a type such as uint256, bytes32[], or (address to,uint256 amount)[]
+func ParseType(s string) (*Type, error) {
+	p := &typeParser{s: s}
+	t, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.s) {
+		return nil, fmt.Errorf("unexpected %q in type %s", p.s[p.pos:], s)
+	}
+	return t, nil
+}
+
+// String returns the canonical form of the type, as used to compute selectors
+func (t *Type) String() string {
+	switch t.kind {
+	case kindUint:
+		return "uint" + strconv.Itoa(t.size)
+	case kindInt:
+		return "int" + strconv.Itoa(t.size)
+	case kindAddress:
+		return "address"
+	case kindBool:
+		return "bool"
+	case kindFixedBytes:
+		return "bytes" + strconv.Itoa(t.size)
+	case kindBytes:
+		return "bytes"
+	case kindString:
+		return "string"
+	case kindArray:
+		if t.length < 0 {
+			return t.elem.String() + "[]"
+		}
+		return t.elem.String() + "[" + strconv.Itoa(t.length) + "]"
+	case kindTuple:
+		return "(" + joinTypes(t.components) + ")"
+	}
+	return "?"
+}
+
+func joinTypes(types []*Type) string {
+	s := make([]string, len(types))
+	for i, c := range types {
+		s[i] = c.String()
+	}
+	return strings.Join(s, ",")
+}
+
+// dynamic returns true if the type is encoded in the tail of its enclosing tuple
+func (t *Type) dynamic() bool {
+	switch t.kind {
+	case kindBytes, kindString:
+		return true
+	case kindArray:
+		return t.length < 0 || t.elem.dynamic()
+	case kindTuple:
+		for _, c := range t.components {
+			if c.dynamic() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// headSize returns the number of bytes taken by the type in the head of its enclosing tuple
+func (t *Type) headSize() int {
+	if t.dynamic() {
+		return 32
+	}
+	switch t.kind {
+	case kindArray:
+		return t.length * t.elem.headSize()
+	case kindTuple:
+		res := 0
+		for _, c := range t.components {
+			res += c.headSize()
+		}
+		return res
+	}
+	return 32
+}
+
+func elementaryType(name string) (*Type, error) {
+	switch name {
+	case "address":
+		return &Type{kind: kindAddress}, nil
+	case "bool":
+		return &Type{kind: kindBool}, nil
+	case "string":
+		return &Type{kind: kindString}, nil
+	case "bytes":
+		return &Type{kind: kindBytes}, nil
+	case "uint":
+		return &Type{kind: kindUint, size: 256}, nil
+	case "int":
+		return &Type{kind: kindInt, size: 256}, nil
+	case "function":
+		// address + selector
+		return &Type{kind: kindFixedBytes, size: 24}, nil
+	}
+	for prefix, k := range map[string]kind{"uint": kindUint, "int": kindInt, "bytes": kindFixedBytes} {
+		v, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			continue
+		}
+		size, err := strconv.Atoi(v)
+		if err != nil {
+			break
+		}
+		switch k {
+		case kindFixedBytes:
+			if size < 1 || size > 32 {
+				return nil, fmt.Errorf("invalid type %s", name)
+			}
+		default:
+			if size < 8 || size > 256 || size%8 != 0 {
+				return nil, fmt.Errorf("invalid type %s", name)
+			}
+		}
+		return &Type{kind: k, size: size}, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", name)
+}
+
+type typeParser struct {
+	s   string
+	pos int
+}
+
+func (p *typeParser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t' || p.s[p.pos] == '\n') {
+		p.pos += 1
+	}
+}
+
+func (p *typeParser) peek() byte {
+	p.skipSpace()
+	if p.pos >= len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *typeParser) word() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '$' {
+			p.pos += 1
+			continue
+		}
+		break
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *typeParser) parseType() (*Type, error) {
+	var t *Type
+	if p.peek() == '(' {
+		comps, names, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		t = &Type{kind: kindTuple, components: comps, names: names}
+	} else {
+		name := p.word()
+		if name == "tuple" && p.peek() == '(' {
+			// tuple(...) form
+			return p.parseType()
+		}
+		var err error
+		t, err = elementaryType(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return p.parseDims(t)
+}
+
+// parseDims parses any array suffix following a type
+func (p *typeParser) parseDims(t *Type) (*Type, error) {
+	for p.peek() == '[' {
+		end := strings.IndexByte(p.s[p.pos:], ']')
+		if end == -1 {
+			return nil, errors.New("unterminated array type")
+		}
+		v := p.s[p.pos+1 : p.pos+end]
+		p.pos += end + 1
+		if v == "" {
+			t = &Type{kind: kindArray, elem: t, length: -1}
+			continue
+		}
+		ln, err := strconv.Atoi(v)
+		if err != nil || ln < 0 {
+			return nil, fmt.Errorf("invalid array length %s", v)
+		}
+		t = &Type{kind: kindArray, elem: t, length: ln}
+	}
+	return t, nil
+}
+
+// parseList parses a parenthesized list of types with optional names
+func (p *typeParser) parseList() ([]*Type, []string, error) {
+	if p.peek() != '(' {
+		return nil, nil, errors.New("expected (")
+	}
+	p.pos += 1
+	var types []*Type
+	var names []string
+	if p.peek() == ')' {
+		p.pos += 1
+		return types, names, nil
+	}
+	for {
+		t, err := p.parseType()
+		if err != nil {
+			return nil, nil, err
+		}
+		name := ""
+		for {
+			w := p.word()
+			switch w {
+			case "indexed", "memory", "calldata", "storage", "payable":
+				continue
+			}
+			if w != "" {
+				name = w
+				continue
+			}
+			break
+		}
+		types = append(types, t)
+		names = append(names, name)
+
+		switch p.peek() {
+		case ',':
+			p.pos += 1
+		case ')':
+			p.pos += 1
+			return types, names, nil
+		default:
+			return nil, nil, fmt.Errorf("unexpected character at position %d in %s", p.pos, p.s)
+		}
+	}
+}
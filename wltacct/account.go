@@ -3,8 +3,10 @@ package wltacct
 import (
 	"context"
 	"crypto"
+	"crypto/ed25519"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"io"
 	"math/big"
 	"strconv"
@@ -36,8 +38,10 @@ type Account struct {
 	Type      string     // "ethereum", "bitcoin", etc *deprecated* we don't care about the account type, only the wallet curve
 	Path      string     // Derivation path, e.g. m/44/60/0/0 (note: no hardened keys since we only have public keys)
 	Address   string     // Blockchain address in the appropriate format
+	Taproot   string     // Taproot (p2tr) address on bitcoin, empty on other networks
 	URI       string     // URI for sending to this account (e.g. ethereum:0x...)
 	Pubkey    string     // Base64 encoded public key
+	Curve     string     // Curve of the parent wallet, empty for secp256k1
 	Chaincode string     // Base64 encoded chaincode for HD derivation
 	IL        *big.Int   `json:"IL,string" gorm:"serializer:json"` // Intermediate value used in derivation
 	Created   time.Time  `gorm:"autoCreateTime"`                   // Creation timestamp
@@ -70,7 +74,30 @@ func (a *Account) check(e wltintf.Env) error {
 		return err
 	}
 
+	a.Taproot = ""
+	if net.Curve() != a.curve() {
+		// this account's key cannot be used on this network
+		a.Address = "N/A"
+		a.URI = ""
+		return nil
+	}
+
 	switch net.Type {
+	case "solana", "aptos", "sui":
+		pub, err := a.Ed25519PublicKey()
+		if err != nil {
+			return err
+		}
+		addr, err := ed25519Address(net.Type, pub)
+		if err != nil {
+			return err
+		}
+		a.Address = addr
+		a.URI = ""
+		if net.Type == "solana" {
+			a.URI = "solana:" + addr
+		}
+		return nil
 	case "evm":
 		// Format Ethereum address
 		addr, err := outscript.New(a.PublicKey()).Out("eth").Address()
@@ -98,7 +125,14 @@ func (a *Account) check(e wltintf.Env) error {
 			}
 			a.Address = addr
 			a.URI = "bitcoin:" + addr
-			return nil
+
+			// Taproot address, with its own internal key
+			internal, err := a.DerivePublic(taprootPath)
+			if err != nil {
+				return err
+			}
+			a.Taproot, err = taprootAddress("bc", internal)
+			return err
 		case "bitcoin-cash":
 			// Bitcoin Cash uses legacy format (p2pkh)
 			addr, err := s.Out("p2pkh").Address("bitcoincash")
@@ -144,9 +178,12 @@ func (a *Account) setCurrent(e wltintf.Env) error {
 
 // init initializes a new account with a specified wallet and index
 // Derives the account's public key and addresses from the wallet's master key
-// Uses the BIP44 path format: m/44/60/0/{index} (Ethereum-like for now)
+// Uses the BIP44 path format: m/44/60/0/{index} (Ethereum-like for now), or m/44/501/0/{index} for ed25519 wallets
 // Returns any error encountered during the initialization
 func (a *Account) init(wallet *wltwallet.Wallet) error {
+	if wallet.IsEdDSA() {
+		return a.initEd25519(wallet)
+	}
 	// Set up derivation path for Ethereum (hardcoded for now)
 	a.Path = "m/44/60/0/" + strconv.Itoa(a.Index)
 	a.Chaincode = wallet.Chaincode
@@ -184,6 +221,44 @@ func (a *Account) init(wallet *wltwallet.Wallet) error {
 	return nil
 }
 
+// initEd25519 initializes an account of an ed25519 wallet, defaulting to the Solana address format
+func (a *Account) initEd25519(wallet *wltwallet.Wallet) error {
+	a.Path = "m/44/501/0/" + strconv.Itoa(a.Index)
+	a.Chaincode = wallet.Chaincode
+	a.Curve = wallet.Curve
+
+	wpubkey, err := wallet.Ed25519Pubkey()
+	if err != nil {
+		return err
+	}
+	chainCode, err := base64.RawURLEncoding.DecodeString(wallet.Chaincode)
+	if err != nil {
+		return err
+	}
+	IL, pubkey, err := DeriveEd25519PublicKey(wpubkey, chainCode, a.Path)
+	if err != nil {
+		return err
+	}
+	a.IL = IL
+	a.Pubkey = base64.RawURLEncoding.EncodeToString(pubkey)
+
+	addr, err := ed25519Address("solana", pubkey)
+	if err != nil {
+		return err
+	}
+	a.Address = addr
+	a.URI = "solana:" + addr
+	return nil
+}
+
+// curve returns the curve of this account's key
+func (a *Account) curve() string {
+	if a.Curve == "" {
+		return "secp256k1"
+	}
+	return a.Curve
+}
+
 // getWallet retrieves the parent wallet of this account
 // Returns the wallet object and any error encountered
 func (a *Account) getWallet(e wltintf.Env) (*wltwallet.Wallet, error) {
@@ -192,8 +267,11 @@ func (a *Account) getWallet(e wltintf.Env) (*wltwallet.Wallet, error) {
 
 // PublicKey returns the account's public key as a secp256k1.PublicKey object
 // Decodes the base64-encoded public key stored in the account
-// Returns nil if there's an error during decoding
+// Returns nil if there's an error during decoding, or if this is an ed25519 account
 func (a *Account) PublicKey() *secp256k1.PublicKey {
+	if a.curve() != "secp256k1" {
+		return nil
+	}
 	k, err := base64.RawURLEncoding.DecodeString(a.Pubkey)
 	if err != nil {
 		return nil
@@ -205,9 +283,28 @@ func (a *Account) PublicKey() *secp256k1.PublicKey {
 	return obj
 }
 
+// Ed25519PublicKey returns the account's public key for ed25519 accounts
+func (a *Account) Ed25519PublicKey() (ed25519.PublicKey, error) {
+	if a.curve() != "ed25519" {
+		return nil, fmt.Errorf("account uses curve %s, not ed25519", a.curve())
+	}
+	k, err := base64.RawURLEncoding.DecodeString(a.Pubkey)
+	if err != nil {
+		return nil, err
+	}
+	if len(k) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid ed25519 public key length")
+	}
+	return ed25519.PublicKey(k), nil
+}
+
 // Public implements the crypto.Signer interface
-// Returns the account's public key as a crypto.PublicKey interface
+// Returns the account's public key as a crypto.PublicKey interface, a ed25519.PublicKey for ed25519 accounts
 func (a *Account) Public() crypto.PublicKey {
+	if a.curve() == "ed25519" {
+		pub, _ := a.Ed25519PublicKey()
+		return pub
+	}
 	return a.PublicKey()
 }
 
@@ -215,6 +312,9 @@ func (a *Account) Public() crypto.PublicKey {
 // Uses HD wallet derivation with the provided subpath
 // Returns the derived public key and any error encountered
 func (a *Account) DerivePublic(subpath string) (*secp256k1.PublicKey, error) {
+	if a.curve() != "secp256k1" {
+		return nil, errors.New("derivation of secp256k1 keys is not available on ed25519 accounts")
+	}
 	if a.Chaincode == "" {
 		return nil, errors.New("need chaincode")
 	}
@@ -341,12 +441,17 @@ func (a *Account) accountDelete(e wltintf.Env) error {
 //
 // Returns the signature and any error encountered
 func (a *Account) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return a.signWithIL(a.IL, rand, digest, opts)
+}
+
+// signWithIL signs a digest using the account's parent wallet, with the key tweaked by the given IL
+func (a *Account) signWithIL(il *big.Int, rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
 	aopt, ok := opts.(*wltsign.Opts)
 	if !ok {
 		return nil, errors.New("sign requires appropriate options")
 	}
-	// Add the account's IL (intermediate value) to the options
-	aopt.IL = a.IL
+	// Add the IL (intermediate value) to the options
+	aopt.IL = il
 
 	// Get the parent wallet
 	w, err := pobj.ById[wltwallet.Wallet](aopt.Context, a.Wallet.String())
@@ -357,3 +462,133 @@ func (a *Account) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([
 	// Delegate signing to the wallet
 	return w.Sign(rand, digest, aopt)
 }
+
+// BtcSigner returns a crypto.Signer for the key used on bitcoin-based networks
+// Bitcoin addresses are derived from the account's key at m/0 (see check)
+func (a *Account) BtcSigner() (crypto.Signer, error) {
+	return a.DeriveSigner("m/0")
+}
+
+// DeriveSigner returns a crypto.Signer for a child key of this account
+// Signatures are performed by the parent wallet with the combined IL of the account and subpath
+func (a *Account) DeriveSigner(subpath string) (crypto.Signer, error) {
+	if a.curve() != "secp256k1" {
+		return nil, errors.New("derivation of secp256k1 keys is not available on ed25519 accounts")
+	}
+	if a.Chaincode == "" {
+		return nil, errors.New("need chaincode")
+	}
+	chainCode, err := base64.RawURLEncoding.DecodeString(a.Chaincode)
+	if err != nil {
+		return nil, err
+	}
+	il, pub, err := DerivePublicKey(a.PublicKey(), chainCode, subpath)
+	if err != nil {
+		return nil, err
+	}
+	if a.IL != nil {
+		il = new(big.Int).Add(il, a.IL)
+		il.Mod(il, secp256k1.Params().N)
+	}
+	return &derivedSigner{acct: a, il: il, pub: pub}, nil
+}
+
+// derivedSigner signs using a child key of an account
+type derivedSigner struct {
+	acct *Account
+	il   *big.Int
+	pub  *secp256k1.PublicKey
+}
+
+// Public implements the crypto.Signer interface
+func (d *derivedSigner) Public() crypto.PublicKey {
+	return d.pub
+}
+
+// Sign implements the crypto.Signer interface
+func (d *derivedSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return d.acct.signWithIL(d.il, rand, digest, opts)
+}
+
+// SignBatch signs digests[i] with signers[i], which must be the account itself or signers returned by its
+// DeriveSigner and TaprootSigner methods. The wallet keys are decrypted only once for all the digests (see
+// wltwallet.Wallet.SignBatch). If some digests could not be signed, the signatures of the others are returned with
+// a *wltwallet.BatchError.
+func (a *Account) SignBatch(rand io.Reader, signers []crypto.Signer, digests [][]byte, opts crypto.SignerOpts) ([][]byte, error) {
+	aopt, ok := opts.(*wltsign.Opts)
+	if !ok {
+		return nil, errors.New("sign requires appropriate options")
+	}
+	if len(signers) != len(digests) {
+		return nil, fmt.Errorf("got %d signers for %d digests", len(signers), len(digests))
+	}
+	w, err := pobj.ById[wltwallet.Wallet](aopt.Context, a.Wallet.String())
+	if err != nil {
+		return nil, err
+	}
+
+	// taproot inputs are signed with a different method, run one batch per type of signature
+	res := make([][]byte, len(digests))
+	errs := make([]error, len(digests))
+	failed := false
+	for _, taproot := range []bool{false, true} {
+		var idx []int
+		var batch [][]byte
+		var ils []*big.Int
+		for i, s := range signers {
+			il, tr, err := a.batchParams(s)
+			if err != nil {
+				return nil, err
+			}
+			if tr == taproot {
+				idx = append(idx, i)
+				batch = append(batch, digests[i])
+				ils = append(ils, il)
+			}
+		}
+		if len(idx) == 0 {
+			continue
+		}
+		sign := w.SignBatch
+		if taproot {
+			sign = w.SignTaprootLocal
+		}
+		sigs, err := sign(rand, batch, ils, aopt)
+		var berr *wltwallet.BatchError
+		switch {
+		case errors.As(err, &berr):
+			for j, i := range idx {
+				errs[i] = berr.Errors[j]
+			}
+			failed = true
+		case err != nil:
+			return nil, err
+		}
+		for j, i := range idx {
+			res[i] = sigs[j]
+		}
+	}
+	if failed {
+		return res, &wltwallet.BatchError{Errors: errs}
+	}
+	return res, nil
+}
+
+// batchParams returns the derivation offset of a signer of this account, and whether it signs for a Taproot key
+func (a *Account) batchParams(s crypto.Signer) (*big.Int, bool, error) {
+	switch s := s.(type) {
+	case *Account:
+		if s.Id.String() == a.Id.String() {
+			return a.IL, false, nil
+		}
+	case *derivedSigner:
+		if s.acct.Id.String() == a.Id.String() {
+			return s.il, false, nil
+		}
+	case *taprootSigner:
+		if s.acct.Id.String() == a.Id.String() {
+			return s.il, true, nil
+		}
+	}
+	return nil, false, errors.New("signer does not belong to this account")
+}
@@ -0,0 +1,96 @@
+package wltacct
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ModChain/base58"
+	"github.com/ModChain/edwards25519"
+	tsscrypto "github.com/ModChain/tss-lib/v2/crypto"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
+)
+
+// DeriveEd25519PublicKey derives a child ed25519 public key, the same way DerivePublicKey does for secp256k1
+//
+// Standard ed25519 derivation (SLIP-10) only allows hardened keys, which cannot be computed from the public key
+// alone. Instead, for each index, I = HMAC-SHA512(chainCode, pubkey || index) and the child key is pubkey + IL*G,
+// with the chaincode for the next level being IR. Signing with the returned IL produces signatures for the child
+// key (see wltwallet).
+func DeriveEd25519PublicKey(pubkey []byte, chainCode []byte, path string) (*big.Int, []byte, error) {
+	pathA := strings.Split(path, "/")
+	if pathA[0] != "m" {
+		return nil, nil, errors.New("path must start with m/")
+	}
+	pathA = pathA[1:]
+	if len(pathA) < 1 {
+		return nil, nil, errors.New("path cannot be empty, must have at least a derivation")
+	}
+
+	pk, err := edwards25519.ParsePubKey(pubkey)
+	if err != nil {
+		return nil, nil, err
+	}
+	curve := edwards25519.Edwards()
+	point, err := tsscrypto.NewECPoint(curve, pk.X, pk.Y)
+	if err != nil {
+		return nil, nil, err
+	}
+	n := curve.Params().N
+	il := new(big.Int)
+
+	for _, v := range pathA {
+		x, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return nil, nil, err
+		}
+		if x >= 0x80000000 {
+			return nil, nil, errors.New("hardened keys not supported in here")
+		}
+
+		mac := hmac.New(sha512.New, chainCode)
+		mac.Write(point.ToEd25519PubKey().Serialize())
+		binary.Write(mac, binary.BigEndian, uint32(x))
+		I := mac.Sum(nil)
+
+		delta := new(big.Int).SetBytes(I[:32])
+		delta.Mod(delta, n)
+		if delta.Sign() == 0 {
+			return nil, nil, fmt.Errorf("invalid child at index %d", x)
+		}
+		point, err = point.Add(tsscrypto.ScalarBaseMult(curve, delta))
+		if err != nil {
+			return nil, nil, err
+		}
+		il.Add(il, delta)
+		il.Mod(il, n)
+		chainCode = I[32:]
+	}
+
+	return il, point.ToEd25519PubKey().Serialize(), nil
+}
+
+// ed25519Address returns the address of an ed25519 public key on the given network type
+func ed25519Address(typ string, pub []byte) (string, error) {
+	switch typ {
+	case "solana":
+		return base58.Bitcoin.Encode(pub), nil
+	case "aptos":
+		// sha3-256 of the key followed by the single signature scheme id (0)
+		h := sha3.Sum256(append(append([]byte{}, pub...), 0x00))
+		return "0x" + hex.EncodeToString(h[:]), nil
+	case "sui":
+		// blake2b-256 of the ed25519 scheme flag (0) followed by the key
+		h := blake2b.Sum256(append([]byte{0x00}, pub...))
+		return "0x" + hex.EncodeToString(h[:]), nil
+	default:
+		return "", fmt.Errorf("unsupported network type %s for ed25519", typ)
+	}
+}
@@ -0,0 +1,48 @@
+package wltacct
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ModChain/edwards25519"
+	tsscrypto "github.com/ModChain/tss-lib/v2/crypto"
+)
+
+func TestDeriveEd25519PublicKey(t *testing.T) {
+	curve := edwards25519.Edwards()
+	k := big.NewInt(0x1234567890)
+	pub := tsscrypto.ScalarBaseMult(curve, k).ToEd25519PubKey().Serialize()
+	chainCode := bytes.Repeat([]byte{0x42}, 32)
+
+	il, child, err := DeriveEd25519PublicKey(pub, chainCode, "m/44/501/0/1")
+	if err != nil {
+		t.Fatalf("failed to derive: %s", err)
+	}
+
+	// the child key must be the one of the parent private key plus IL
+	childK := new(big.Int).Add(k, il)
+	childK.Mod(childK, curve.Params().N)
+	if expected := tsscrypto.ScalarBaseMult(curve, childK).ToEd25519PubKey().Serialize(); !bytes.Equal(child, expected) {
+		t.Errorf("derived key does not match IL")
+	}
+
+	if _, other, _ := DeriveEd25519PublicKey(pub, chainCode, "m/44/501/0/2"); bytes.Equal(child, other) {
+		t.Errorf("different indexes must give different keys")
+	}
+	if _, _, err := DeriveEd25519PublicKey(pub, chainCode, "m/2147483648"); err == nil {
+		t.Errorf("hardened derivation should fail")
+	}
+
+	for _, typ := range []string{"solana", "aptos", "sui"} {
+		addr, err := ed25519Address(typ, child)
+		if err != nil {
+			t.Errorf("%s: %s", typ, err)
+			continue
+		}
+		if typ != "solana" && (!strings.HasPrefix(addr, "0x") || len(addr) != 66) {
+			t.Errorf("%s: unexpected address %s", typ, addr)
+		}
+	}
+}
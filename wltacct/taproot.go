@@ -0,0 +1,76 @@
+package wltacct
+
+import (
+	"crypto"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/EllipX/libwallet/wltschnorr"
+	"github.com/EllipX/libwallet/wltsign"
+	"github.com/EllipX/libwallet/wltwallet"
+	"github.com/KarpelesLab/pobj"
+	"github.com/ModChain/bech32m"
+	"github.com/ModChain/secp256k1"
+)
+
+// taprootPath is the subpath of the account key used as Taproot internal key, distinct from the m/0 key used for
+// segwit v0 addresses so both address types do not share a key
+const taprootPath = "m/86"
+
+// taprootAddress returns the p2tr address of the output key for the given internal key, with no script tree (BIP86)
+func taprootAddress(hrp string, internal *secp256k1.PublicKey) (string, error) {
+	_, output, err := wltschnorr.TapTweak(internal)
+	if err != nil {
+		return "", err
+	}
+	return bech32m.SegwitAddrEncode(hrp, 1, wltschnorr.XOnly(output))
+}
+
+// TaprootSigner returns a crypto.Signer for the Taproot address of the account (see check)
+// Signatures are 64 bytes BIP340 Schnorr signatures for the tweaked output key, as used in key path spends. They
+// are computed by wltwallet.Wallet.SignTaprootLocal, which requires all the signing keys to be local
+func (a *Account) TaprootSigner() (crypto.Signer, error) {
+	s, err := a.DeriveSigner(taprootPath)
+	if err != nil {
+		return nil, err
+	}
+	d := s.(*derivedSigner)
+	_, output, err := wltschnorr.TapTweak(d.pub)
+	if err != nil {
+		return nil, err
+	}
+	return &taprootSigner{derivedSigner: d, output: output}, nil
+}
+
+// taprootSigner signs for the Taproot output key of a child key of an account
+type taprootSigner struct {
+	*derivedSigner
+	output *secp256k1.PublicKey
+}
+
+// Public implements the crypto.Signer interface, returning the tweaked output key
+func (t *taprootSigner) Public() crypto.PublicKey {
+	return t.output
+}
+
+// Sign implements the crypto.Signer interface. This is not threshold signing, see wltwallet.Wallet.SignTaprootLocal
+func (t *taprootSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	aopt, ok := opts.(*wltsign.Opts)
+	if !ok {
+		return nil, errors.New("sign requires appropriate options")
+	}
+	w, err := pobj.ById[wltwallet.Wallet](aopt.Context, t.acct.Wallet.String())
+	if err != nil {
+		return nil, err
+	}
+	sigs, err := w.SignTaprootLocal(rand, [][]byte{digest}, []*big.Int{t.il}, aopt)
+	var berr *wltwallet.BatchError
+	if errors.As(err, &berr) {
+		return nil, berr.Errors[0]
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sigs[0], nil
+}
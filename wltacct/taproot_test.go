@@ -0,0 +1,24 @@
+package wltacct
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/EllipX/libwallet/wltschnorr"
+)
+
+func TestTaprootAddress(t *testing.T) {
+	// first receiving address of BIP86 test vectors
+	x, _ := hex.DecodeString("cc8a4bc64d897bddc5fbc2f670f7a8ba0b386779106cf1223c6fc5d7cd6fc115")
+	internal, err := wltschnorr.ParseXOnly(x)
+	if err != nil {
+		t.Fatalf("failed to parse key: %s", err)
+	}
+	addr, err := taprootAddress("bc", internal)
+	if err != nil {
+		t.Fatalf("failed to get address: %s", err)
+	}
+	if addr != "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr" {
+		t.Errorf("unexpected address %s", addr)
+	}
+}
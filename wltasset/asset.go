@@ -1,12 +1,16 @@
 package wltasset
 
 import (
+	"errors"
+	"fmt"
 	"io/fs"
+	"strings"
 	"time"
 
 	"github.com/EllipX/ellipxobj"
 	"github.com/EllipX/libwallet/wltintf"
 	"github.com/EllipX/libwallet/wltquote"
+	"github.com/KarpelesLab/apirouter"
 	"github.com/KarpelesLab/xuid"
 )
 
@@ -17,16 +21,54 @@ type Asset struct {
 	Symbol       string            `json:"symbol"`
 	Amount       *ellipxobj.Amount `json:"amount" gorm:"serializer:json"`
 	Info         *CoinInfo         `json:"info" gorm:"-:all"`
-	Type         string            `json:"type"`
-	Network      *xuid.XUID        `json:"network,omitempty"`
+	Type         string            `json:"type"`               // fungible | nft
+	Standard     string            `json:"standard,omitempty"` // ERC20, ERC721 or ERC1155 for tokens added with wallet_watchAsset
+	Contract     string            `json:"contract,omitempty"` // token contract address, lowercase
+	TokenId      string            `json:"token_id,omitempty"` // token id (base 10) for ERC721 & ERC1155
+	Decimals     int               `json:"decimals,omitempty"` // decimals of ERC20 tokens
+	Image        string            `json:"image,omitempty"`    // image URL supplied by the dApp
+	Network      *xuid.XUID        `json:"network,omitempty" gorm:"index"`
 	FiatAmount   *ellipxobj.Amount `json:"fiat_amount,omitempty" gorm:"-:all"`
 	FiatCurrency string            `json:"fiat_currency,omitempty" gorm:"-:all"`
 	FiatQuote    any               `json:"fiat_quote,omitempty" gorm:"-:all"`
-	TestNet      bool              `json;"testnet,omitempty" gorm:"-:all"`
+	TestNet      bool              `json:"testnet,omitempty" gorm:"-:all"`
 	Created      time.Time         `gorm:"autoCreateTime"`
 	Updated      time.Time         `gorm:"autoUpdateTime"`
 }
 
+// NativeToken is the token part of the key of a network's native asset
+const NativeToken = "NATIVE"
+
+// TokenKey returns the key of a token asset on the given network (type.chainId). Token ids are appended
+// to the contract address for ERC721 & ERC1155 tokens, as each token is a different asset.
+func TokenKey(network, contract, tokenId string) string {
+	k := network + "." + strings.ToLower(contract)
+	if tokenId != "" {
+		k += ":" + tokenId
+	}
+	return k
+}
+
+// ParseKey splits an asset key such as evm.137.NATIVE or evm.137.0x... into its network type,
+// chain id and token (NativeToken or a contract address)
+func ParseKey(key string) (typ, chainId, token string, err error) {
+	parts := strings.SplitN(key, ".", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", "", "", fmt.Errorf("invalid asset key %s", key)
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+// ApiDelete removes an asset added with wallet_watchAsset
+func (a *Asset) ApiDelete(ctx *apirouter.Context) error {
+	e := wltintf.GetEnv(ctx)
+	if e == nil {
+		return errors.New("failed to get env")
+	}
+
+	return e.Delete(a)
+}
+
 func (a *Asset) ConvertTo(e wltintf.Env, currency string) error {
 	if a.TestNet {
 		// do not perform conversion on anything related to a testnet
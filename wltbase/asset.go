@@ -2,7 +2,11 @@ package wltbase
 
 import (
 	"errors"
+	"fmt"
 	"io/fs"
+	"log"
+	"math/big"
+	"strings"
 
 	"github.com/EllipX/libwallet/wltacct"
 	"github.com/EllipX/libwallet/wltasset"
@@ -10,6 +14,8 @@ import (
 	"github.com/EllipX/libwallet/wltnet"
 	"github.com/KarpelesLab/apirouter"
 	"github.com/KarpelesLab/pobj"
+	"github.com/KarpelesLab/typutil"
+	"github.com/KarpelesLab/xuid"
 )
 
 func init() {
@@ -23,7 +29,17 @@ func init() {
 }
 
 func apiFetchAsset(ctx *apirouter.Context, in struct{ Id string }) (any, error) {
-	return nil, fs.ErrNotExist
+	e := wltintf.GetEnv(ctx)
+	if e == nil {
+		return nil, errors.New("failed to get env")
+	}
+
+	// only assets added with wallet_watchAsset are stored
+	id, err := xuid.ParsePrefix(in.Id, "asset")
+	if err != nil {
+		return nil, fs.ErrNotExist
+	}
+	return wltintf.ByPrimaryKey[wltasset.Asset](e, id)
 }
 
 func apiListAsset(ctx *apirouter.Context) (any, error) {
@@ -59,6 +75,23 @@ func apiListAsset(ctx *apirouter.Context) (any, error) {
 	}
 	assets = append(assets, nat)
 
+	// tokens added with wallet_watchAsset
+	var watched []*wltasset.Asset
+	if err := e.Find(&watched, map[string]any{"Network": n.Id}); err != nil {
+		return nil, err
+	}
+	for _, a := range watched {
+		a.TestNet = n.TestNet
+		a.Amount, err = n.AssetBalance(a, acct)
+		if err != nil {
+			log.Printf("failed to fetch balance of %s: %s", a.Key, err)
+		}
+		if a.Standard == "ERC20" {
+			a.Info, _ = wltasset.CoinInfoByAddress(e, a.Contract)
+		}
+		assets = append(assets, a)
+	}
+
 	if convert, okconv := apirouter.GetParam[string](ctx, "_convert"); okconv {
 		for _, a := range assets {
 			a.ConvertTo(e, convert)
@@ -73,3 +106,84 @@ func apiListAsset(ctx *apirouter.Context) (any, error) {
 
 	return res, nil
 }
+
+// watchAssetParams is the parameter of wallet_watchAsset, see EIP-747
+type watchAssetParams struct {
+	Type    string `json:"type"` // ERC20, ERC721 or ERC1155
+	Options struct {
+		Address  string `json:"address"`
+		Symbol   string `json:"symbol"`
+		Decimals any    `json:"decimals"`
+		Image    string `json:"image"`
+		TokenId  any    `json:"tokenId"`
+	} `json:"options"`
+}
+
+// watchAsset checks the token requested by wallet_watchAsset on network n and returns the asset to be added
+func watchAsset(n *wltnet.Network, param any) (*wltasset.Asset, error) {
+	p, err := typutil.As[*watchAssetParams](param)
+	if err != nil {
+		return nil, fmt.Errorf("wallet_watchAsset: invalid parameter: %w", err)
+	}
+	if n.Type != "evm" {
+		return nil, fmt.Errorf("wallet_watchAsset: not supported on network type %s", n.Type)
+	}
+	if len(p.Options.Symbol) > 11 {
+		return nil, errors.New("wallet_watchAsset: symbol must be at most 11 characters")
+	}
+
+	res := &wltasset.Asset{
+		Standard: strings.ToUpper(p.Type),
+		Contract: strings.ToLower(p.Options.Address),
+		Symbol:   p.Options.Symbol,
+		Image:    p.Options.Image,
+		Network:  n.Id,
+	}
+	switch res.Standard {
+	case "ERC20":
+		info, err := n.TokenInfo(res.Contract)
+		if err != nil {
+			return nil, fmt.Errorf("wallet_watchAsset: %w", err)
+		}
+		if p.Options.Decimals != nil {
+			dec, err := typutil.As[int](p.Options.Decimals)
+			if err != nil || dec != info.Decimals {
+				return nil, fmt.Errorf("wallet_watchAsset: decimals %v do not match the token's decimals (%d)", p.Options.Decimals, info.Decimals)
+			}
+		}
+		if res.Symbol == "" {
+			res.Symbol = info.Symbol
+		}
+		res.Name = info.Name
+		res.Decimals = info.Decimals
+		res.Type = "fungible"
+	case "ERC721", "ERC1155":
+		// token ids can be passed as a number or a string
+		tokenId, ok := new(big.Int).SetString(fmt.Sprint(p.Options.TokenId), 0)
+		if p.Options.TokenId == nil || !ok || tokenId.Sign() < 0 {
+			return nil, fmt.Errorf("wallet_watchAsset: invalid tokenId %v", p.Options.TokenId)
+		}
+		res.TokenId = tokenId.String()
+		if ok, err := n.SupportsStandard(res.Contract, res.Standard); err != nil {
+			return nil, fmt.Errorf("wallet_watchAsset: %w", err)
+		} else if !ok {
+			return nil, fmt.Errorf("wallet_watchAsset: contract %s does not implement %s", res.Contract, res.Standard)
+		}
+		if res.Symbol == "" {
+			res.Symbol = res.Standard
+		}
+		res.Name = res.Symbol + " #" + res.TokenId
+		res.Type = "nft"
+	default:
+		return nil, fmt.Errorf("wallet_watchAsset: unsupported asset type %s", p.Type)
+	}
+	res.Key = wltasset.TokenKey(n.String(), res.Contract, res.TokenId)
+	return res, nil
+}
+
+// saveWatchedAsset stores an asset approved by the user
+func saveWatchedAsset(e wltintf.Env, a *wltasset.Asset) error {
+	a.Id = xuid.Must(xuid.NewRandom("asset"))
+	a.Amount = nil
+	return e.Save(a)
+}
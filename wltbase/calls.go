@@ -0,0 +1,155 @@
+package wltbase
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/EllipX/libwallet/wltacct"
+	"github.com/EllipX/libwallet/wltnet"
+	"github.com/EllipX/libwallet/wlttx"
+	"github.com/KarpelesLab/apirouter"
+	"github.com/KarpelesLab/typutil"
+)
+
+// sendCallsParams is the parameter of wallet_sendCalls (EIP-5792)
+type sendCallsParams struct {
+	Version        string                     `json:"version"`
+	Id             string                     `json:"id"` // optional, generated if empty
+	From           string                     `json:"from"`
+	ChainId        string                     `json:"chainId"`
+	AtomicRequired bool                       `json:"atomicRequired"`
+	Calls          []map[string]any           `json:"calls"`
+	Capabilities   map[string]*callCapability `json:"capabilities"`
+}
+
+type callCapability struct {
+	Optional bool `json:"optional"`
+}
+
+// checkCapabilities returns error 5700 if caps contains a capability the dApp requires and we do not support
+func checkCapabilities(caps map[string]*callCapability) error {
+	for k, c := range caps {
+		if c == nil || !c.Optional {
+			return &apirouter.Error{Code: 5700, Message: fmt.Sprintf("Unsupported non-optional capability %s.", k)}
+		}
+	}
+	return nil
+}
+
+// walletCapabilities returns the EIP-5792 capabilities of addr for the given chain ids (0x prefixed hex), or
+// for all evm networks if chainIds is empty
+func (e *env) walletCapabilities(conn []*connectedSite, addr string, chainIds []string) (map[string]any, error) {
+	found := false
+	for _, c := range conn {
+		if a, err := wltacct.AccountById(e, c.Account); err == nil && strings.EqualFold(a.Address, addr) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, &apirouter.Error{Code: 4100, Message: fmt.Sprintf("Account %s has not been authorized by the user.", addr)}
+	}
+
+	var list []*wltnet.Network
+	if err := e.Find(&list, map[string]any{"Type": "evm"}); err != nil {
+		return nil, err
+	}
+	res := make(map[string]any)
+	for _, n := range list {
+		v, ok := new(big.Int).SetString(n.ChainId, 10)
+		if !ok {
+			continue
+		}
+		id := "0x" + v.Text(16)
+		if len(chainIds) > 0 && !containsChainId(chainIds, id) {
+			continue
+		}
+		// calls are sent one by one, accounts cannot execute batches atomically
+		res[id] = map[string]any{"atomic": map[string]any{"status": "unsupported"}}
+	}
+	return res, nil
+}
+
+func containsChainId(list []string, id string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// sendCallsBatch validates a wallet_sendCalls request from the site key on network n and returns the batch to be
+// approved by the user
+func (e *env) sendCallsBatch(key string, n *wltnet.Network, conn []*connectedSite, param any) (*wlttx.Batch, error) {
+	in, err := typutil.As[*sendCallsParams](param)
+	if err != nil {
+		return nil, err
+	}
+	if n.Type != "evm" {
+		return nil, &apirouter.Error{Code: 5710, Message: "Unsupported chain id."}
+	}
+	if chainId, ok := new(big.Int).SetString(in.ChainId, 0); !ok || chainId.Text(10) != n.ChainId {
+		return nil, &apirouter.Error{Code: 5710, Message: fmt.Sprintf("Unsupported chain id %s, the current chain is %s.", in.ChainId, n.ChainId)}
+	}
+	if in.AtomicRequired {
+		return nil, &apirouter.Error{Code: 5760, Message: "Atomic execution of calls is not supported."}
+	}
+	if err := checkCapabilities(in.Capabilities); err != nil {
+		return nil, err
+	}
+	if len(in.Calls) == 0 {
+		return nil, errors.New("wallet_sendCalls requires at least one call")
+	}
+	if in.Id != "" {
+		if _, err := wlttx.BatchById(e, key, in.Id); err == nil {
+			return nil, &apirouter.Error{Code: 5720, Message: fmt.Sprintf("Duplicate batch id %s.", in.Id)}
+		}
+	}
+
+	from := in.From
+	if from == "" {
+		// use the first account of the site, which is the current account if it is connected
+		if len(conn) == 0 {
+			return nil, &apirouter.Error{Code: 4100, Message: "The site has not been authorized by the user."}
+		}
+		a, err := wltacct.AccountById(e, conn[0].Account)
+		if err != nil {
+			return nil, err
+		}
+		from = a.Address
+	}
+	if err := checkCanTransact(e, conn, from); err != nil {
+		return nil, err
+	}
+
+	calls := make([]*wlttx.Transaction, 0, len(in.Calls))
+	for i, c := range in.Calls {
+		if caps, ok := c["capabilities"]; ok {
+			callCaps, err := typutil.As[map[string]*callCapability](caps)
+			if err != nil {
+				return nil, err
+			}
+			if err := checkCapabilities(callCaps); err != nil {
+				return nil, err
+			}
+		}
+		tx, err := typutil.As[*wlttx.Transaction](c)
+		if err != nil {
+			return nil, fmt.Errorf("call %d: %w", i, err)
+		}
+		tx.From = from
+		calls = append(calls, tx)
+	}
+
+	b, err := wlttx.NewBatch(e, n, key, calls)
+	if err != nil {
+		return nil, err
+	}
+	if in.Id != "" {
+		b.Id = in.Id
+	}
+	return b, nil
+}
@@ -0,0 +1,45 @@
+package wltbase
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/EllipX/libwallet/wltnet"
+	"github.com/EllipX/libwallet/wlttx"
+	"github.com/KarpelesLab/apirouter"
+)
+
+func TestBatchOrigin(t *testing.T) {
+	tempEnv, err := InitTempEnv()
+	if err != nil {
+		t.Fatalf("Failed to initialize temporary environment: %v", err)
+	}
+	defer CleanupTempEnv(tempEnv)
+	e := tempEnv.(*env)
+
+	n, err := wltnet.CurrentNetwork(e)
+	if err != nil {
+		t.Fatalf("failed to get current network: %s", err)
+	}
+
+	// the same id can be used by different sites
+	for _, host := range []string{"https://a.example.com", "https://b.example.com"} {
+		if err := e.Save(&wlttx.Batch{Id: "batch1", Host: host, Network: n.Id}); err != nil {
+			t.Fatalf("failed to save batch: %s", err)
+		}
+	}
+	b, err := wlttx.BatchById(e, "https://a.example.com", "batch1")
+	if err != nil || b.Host != "https://a.example.com" {
+		t.Fatalf("failed to find batch: %v", err)
+	}
+
+	// batches of other sites cannot be read
+	if err := e.Save(&wlttx.Batch{Id: "batch2", Host: "https://a.example.com", Network: n.Id}); err != nil {
+		t.Fatalf("failed to save batch: %s", err)
+	}
+	_, err = e.web3Call("https://b.example.com", n, "wallet_getCallsStatus", []any{"batch2"})
+	var apiErr *apirouter.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != 5730 {
+		t.Errorf("expected error 5730, got %v", err)
+	}
+}
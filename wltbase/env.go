@@ -10,16 +10,20 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/EllipX/ellipxobj"
+	"github.com/EllipX/libwallet/wltabi"
 	"github.com/EllipX/libwallet/wltacct"
 	"github.com/EllipX/libwallet/wltasset"
 	"github.com/EllipX/libwallet/wltcontact"
 	"github.com/EllipX/libwallet/wltcrash"
 	"github.com/EllipX/libwallet/wltnet"
 	"github.com/EllipX/libwallet/wltnft"
+	"github.com/EllipX/libwallet/wltphish"
 	"github.com/EllipX/libwallet/wlttx"
 	"github.com/EllipX/libwallet/wltwallet"
+	"github.com/EllipX/libwallet/wltwc"
 	"github.com/KarpelesLab/apirouter"
 	"github.com/KarpelesLab/emitter"
 	"github.com/KarpelesLab/rest"
@@ -39,6 +43,11 @@ type env struct {
 	sql     *gorm.DB
 	spot    *spotlib.Client
 	em      *emitter.Hub
+	wc      *wltwc.Client // WalletConnect client, connected on demand
+	wcLk    sync.Mutex
+	phish   *wltphish.Detector // phishing list, loaded on demand
+	phishAt time.Time          // last time the phishing list was loaded
+	phishLk sync.Mutex
 }
 
 type client struct {
@@ -156,6 +165,7 @@ func (e *env) init() error {
 	// create tables
 	wltasset.InitEnv(e)
 	e.sql.AutoMigrate(&request{})
+	e.expireOrphanRequests()
 	e.sql.AutoMigrate(&currentItem{})
 	e.sql.AutoMigrate(&connectedSite{})
 	wltnet.InitEnv(e)
@@ -163,8 +173,13 @@ func (e *env) init() error {
 	wltacct.InitEnv(e)
 	wltwallet.InitEnv(e)
 	wltcontact.InitEnv(e)
+	wltabi.InitEnv(e)
 	wltnft.InitEnv(e)
 	wltcrash.InitEnv(e)
+	wltwc.InitEnv(e)
+
+	// reconnect WalletConnect sessions from the previous run
+	go e.resumeWalletConnect()
 
 	return nil
 }
@@ -225,6 +240,7 @@ func (e *env) initTemp() error {
 	// create tables
 	wltasset.InitEnv(e)
 	e.sql.AutoMigrate(&request{})
+	e.expireOrphanRequests()
 	e.sql.AutoMigrate(&currentItem{})
 	e.sql.AutoMigrate(&connectedSite{})
 	wltnet.InitEnv(e)
@@ -232,8 +248,10 @@ func (e *env) initTemp() error {
 	wltacct.InitEnv(e)
 	wltwallet.InitEnv(e)
 	wltcontact.InitEnv(e)
+	wltabi.InitEnv(e)
 	wltnft.InitEnv(e)
 	wltcrash.InitEnv(e)
+	wltwc.InitEnv(e)
 
 	return nil
 }
@@ -15,13 +15,16 @@ func (e *env) CacheGet(ctx context.Context, u string, timeout, refresh time.Dura
 
 	// check if in cache
 	cachebuf, err := e.DBSimpleGet([]byte("http_cache"), cacheKey[:])
-	if err == nil {
+	if err == nil && len(cachebuf) >= 8 {
 		// found, return it
 		cacheTime := time.Unix(int64(binary.BigEndian.Uint64(cachebuf[:8])), 0)
+		cachebuf = cachebuf[8:]
 		if time.Since(cacheTime) <= refresh {
 			// still fresh enough
-			return cachebuf[8:], nil
+			return cachebuf, nil
 		}
+	} else {
+		cachebuf = nil
 	}
 
 	if timeout > 0 {
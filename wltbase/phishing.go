@@ -0,0 +1,203 @@
+package wltbase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/EllipX/libwallet/wltphish"
+	"github.com/KarpelesLab/apirouter"
+	"github.com/KarpelesLab/pobj"
+)
+
+const (
+	// phishingRefresh is how often the phishing list is reloaded, unless configured
+	phishingRefresh = 24 * time.Hour
+	// phishingRetry is how long to wait before trying again to load a list that failed to load
+	phishingRetry = 5 * time.Minute
+)
+
+func init() {
+	pobj.RegisterStatic("Web3:checkOrigin", web3CheckOrigin)
+	pobj.RegisterStatic("Web3:setOriginOverride", web3SetOriginOverride)
+	pobj.RegisterStatic("Web3:configurePhishing", web3ConfigurePhishing)
+}
+
+// riskVerdict is the result of checking the origin of a request against the phishing list
+type riskVerdict struct {
+	Host     string `json:"host"`
+	Phishing bool   `json:"phishing"`           // true if the site should be considered malicious
+	Type     string `json:"type"`               // allowlist, blocklist, fuzzy, all (not in any list) or unknown (list not available)
+	Match    string `json:"match,omitempty"`    // list entry that matched, for fuzzy the domain being imitated
+	Override string `json:"override,omitempty"` // allow or block if the user chose to override the verdict for this host
+}
+
+// originKey returns the key identifying the site of a URL: only scheme and host (including the port if any)
+func originKey(u string) (string, error) {
+	p, err := url.Parse(u)
+	if err != nil {
+		return "", err
+	}
+	if p.Host == "" {
+		return "", errors.New("url: host is missing")
+	}
+	return (&url.URL{Scheme: p.Scheme, Host: p.Host}).String(), nil
+}
+
+// originRisk checks the site identified by key against the phishing list and the user's overrides. Failure to
+// load the list is not fatal, the verdict is then unknown.
+func (e *env) originRisk(key string) *riskVerdict {
+	res := &riskVerdict{Host: key, Type: "unknown"}
+	if d, err := e.phishingDetector(); err != nil {
+		log.Printf("phishing: list not available: %s", err)
+	} else if u, err := url.Parse(key); err == nil && u.Hostname() != "" {
+		r := d.Check(u.Hostname())
+		res.Type = r.Type
+		res.Phishing = r.Phishing
+		res.Match = r.Match
+	}
+	if v, err := e.DBSimpleGet([]byte("phishing_override"), []byte(key)); err == nil && len(v) > 0 {
+		res.Override = string(v)
+		res.Phishing = res.Override == "block"
+	}
+	return res
+}
+
+// phishingDetector returns the phishing list, loading it if it was not loaded yet or is older than the refresh
+// interval. If reloading fails, the previous list is kept.
+func (e *env) phishingDetector() (*wltphish.Detector, error) {
+	e.phishLk.Lock()
+	defer e.phishLk.Unlock()
+
+	refresh := phishingRefresh
+	if v, err := e.DBSimpleGet([]byte("phishing"), []byte("refresh")); err == nil {
+		if d, err := time.ParseDuration(string(v)); err == nil && d > 0 {
+			refresh = d
+		}
+	}
+	switch {
+	case e.phish != nil && time.Since(e.phishAt) < refresh:
+		return e.phish, nil
+	case e.phish == nil && !e.phishAt.IsZero() && time.Since(e.phishAt) < phishingRetry:
+		return nil, errors.New("failed to load phishing list, will retry later")
+	}
+	e.phishAt = time.Now()
+
+	buf, err := e.loadPhishingList(refresh)
+	var d *wltphish.Detector
+	if err == nil {
+		d, err = wltphish.Parse(buf)
+	}
+	if err != nil {
+		if e.phish != nil {
+			log.Printf("phishing: failed to reload list, keeping the previous one: %s", err)
+			return e.phish, nil
+		}
+		return nil, err
+	}
+	e.phish = d
+	return d, nil
+}
+
+// loadPhishingList reads the configured list, which can be a local file (absolute path or file:// URL) or a URL
+// fetched through the HTTP cache
+func (e *env) loadPhishingList(refresh time.Duration) ([]byte, error) {
+	source := wltphish.DefaultListURL
+	if v, err := e.DBSimpleGet([]byte("phishing"), []byte("source")); err == nil && len(v) > 0 {
+		source = string(v)
+	}
+	if p, ok := strings.CutPrefix(source, "file://"); ok {
+		return os.ReadFile(p)
+	}
+	if filepath.IsAbs(source) {
+		return os.ReadFile(source)
+	}
+	return e.CacheGet(context.Background(), source, 10*time.Second, refresh)
+}
+
+func web3CheckOrigin(ctx context.Context, in struct {
+	URL string
+}) (any, error) {
+	e := apirouter.GetObject[env](ctx, "@env")
+	if e == nil {
+		return nil, errors.New("failed to get env")
+	}
+	key, err := originKey(in.URL)
+	if err != nil {
+		return nil, err
+	}
+	return e.originRisk(key), nil
+}
+
+func web3SetOriginOverride(ctx context.Context, in struct {
+	URL      string
+	Override string // allow, block, or empty to remove the override
+}) (any, error) {
+	e := apirouter.GetObject[env](ctx, "@env")
+	if e == nil {
+		return nil, errors.New("failed to get env")
+	}
+	key, err := originKey(in.URL)
+	if err != nil {
+		return nil, err
+	}
+	switch in.Override {
+	case "":
+		err = e.DBSimpleDel([]byte("phishing_override"), []byte(key))
+	case "allow", "block":
+		err = e.DBSimpleSet([]byte("phishing_override"), []byte(key), []byte(in.Override))
+	default:
+		return nil, fmt.Errorf("invalid override %s, must be allow or block", in.Override)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return e.originRisk(key), nil
+}
+
+func web3ConfigurePhishing(ctx context.Context, in struct {
+	Source  string // file path or URL of the list, empty for the default list
+	Refresh int    // refresh interval in seconds, 0 for the default
+}) (any, error) {
+	e := apirouter.GetObject[env](ctx, "@env")
+	if e == nil {
+		return nil, errors.New("failed to get env")
+	}
+	if in.Refresh < 0 {
+		return nil, errors.New("refresh must be positive")
+	}
+
+	settings := map[string]string{"source": in.Source}
+	if in.Refresh > 0 {
+		settings["refresh"] = (time.Duration(in.Refresh) * time.Second).String()
+	} else {
+		settings["refresh"] = ""
+	}
+	for k, v := range settings {
+		var err error
+		if v == "" {
+			err = e.DBSimpleDel([]byte("phishing"), []byte(k))
+		} else {
+			err = e.DBSimpleSet([]byte("phishing"), []byte(k), []byte(v))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// load the new list now so errors are reported
+	e.phishLk.Lock()
+	e.phish = nil
+	e.phishAt = time.Time{}
+	e.phishLk.Unlock()
+	if _, err := e.phishingDetector(); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
@@ -0,0 +1,53 @@
+package wltbase
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOriginRisk(t *testing.T) {
+	tempEnv, err := InitTempEnv()
+	if err != nil {
+		t.Fatalf("Failed to initialize temporary environment: %v", err)
+	}
+	defer CleanupTempEnv(tempEnv)
+	e := tempEnv.(*env)
+
+	list := filepath.Join(e.dataDir, "phishing.json")
+	err = os.WriteFile(list, []byte(`{"tolerance":1,"fuzzylist":["uniswap.org"],"whitelist":["uniswap.org"],"blacklist":["drainer.example"]}`), 0600)
+	if err != nil {
+		t.Fatalf("failed to write list: %s", err)
+	}
+	if err := e.DBSimpleSet([]byte("phishing"), []byte("source"), []byte(list)); err != nil {
+		t.Fatalf("failed to configure list: %s", err)
+	}
+
+	tests := []struct {
+		key      string
+		typ      string
+		phishing bool
+	}{
+		{"https://app.uniswap.org", "allowlist", false},
+		{"https://drainer.example", "blocklist", true},
+		{"https://app.unlswap.org", "fuzzy", true},
+		{"http://localhost:3000", "all", false},
+	}
+	for _, test := range tests {
+		r := e.originRisk(test.key)
+		if r.Type != test.typ || r.Phishing != test.phishing {
+			t.Errorf("%s: expected %s/%v, got %+v", test.key, test.typ, test.phishing, r)
+		}
+	}
+
+	// the user can override the verdict per host
+	if err := e.DBSimpleSet([]byte("phishing_override"), []byte("https://app.unlswap.org"), []byte("allow")); err != nil {
+		t.Fatalf("failed to set override: %s", err)
+	}
+	if r := e.originRisk("https://app.unlswap.org"); r.Phishing || r.Override != "allow" || r.Type != "fuzzy" {
+		t.Errorf("expected override to allow the site, got %+v", r)
+	}
+	if r := e.originRisk("http://app.unlswap.org"); !r.Phishing {
+		t.Errorf("override should only apply to the same origin, got %+v", r)
+	}
+}
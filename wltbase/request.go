@@ -10,6 +10,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/EllipX/libwallet/wltabi"
 	"github.com/EllipX/libwallet/wltacct"
 	"github.com/EllipX/libwallet/wltsign"
 	"github.com/EllipX/libwallet/wlttx"
@@ -32,6 +33,8 @@ func init() {
 	pobj.RegisterStatic("Request:test", requestTestReq)
 	pobj.RegisterStatic("Request:approve", requestDoApprove)
 	pobj.RegisterStatic("Request:reject", requestDoReject)
+	pobj.RegisterStatic("Request:timeouts", requestTimeouts)
+	pobj.RegisterStatic("Request:setTimeout", requestSetTimeout)
 }
 
 var (
@@ -39,13 +42,50 @@ var (
 	pendingReqsLk sync.Mutex
 )
 
+// defaultRequestTimeouts is how long requests wait for the user by type, unless configured with Request:setTimeout
+var defaultRequestTimeouts = map[string]time.Duration{
+	"connect":         5 * time.Minute,
+	"sign":            10 * time.Minute,
+	"personal_sign":   10 * time.Minute,
+	"sign_typed_data": 10 * time.Minute,
+	"add_network":     5 * time.Minute,
+	"change_network":  5 * time.Minute,
+	"watch_asset":     5 * time.Minute,
+	"send_calls":      10 * time.Minute,
+	"test":            time.Minute,
+}
+
+// requestTimeout returns the configured timeout for a given request type
+func (e *env) requestTimeout(typ string) time.Duration {
+	if v, err := e.DBSimpleGet([]byte("request_timeout"), []byte(typ)); err == nil {
+		if d, err := time.ParseDuration(string(v)); err == nil && d > 0 {
+			return d
+		}
+	}
+	if d, ok := defaultRequestTimeouts[typ]; ok {
+		return d
+	}
+	return 5 * time.Minute
+}
+
+// expireOrphanRequests marks requests left pending by a previous run as timed out, since nothing is waiting for them anymore
+func (e *env) expireOrphanRequests() error {
+	res := e.sql.Model(&request{}).Where(map[string]any{"Status": "pending"}).Update("Status", "timedout")
+	return res.Error
+}
+
 type request struct {
 	Id          *xuid.XUID         `gorm:"primaryKey"`
-	Type        string             // connect | sign | add_network | change_network | test
+	Type        string             // connect | sign | send_calls | personal_sign | sign_typed_data | add_network | change_network | watch_asset | test
 	Host        string             // URL of requesting site
 	Status      string             // pending | accepted | rejected | timedout
+	Expires     *time.Time         // time after which the request times out if still pending
 	Account     *string            // account used for signature, if specified
 	Transaction *wlttx.Transaction `json:",omitempty" gorm:"serializer:json"` // if Type=sign, contains the transaction to be signed
+	Simulation  *wlttx.Simulation  `json:",omitempty" gorm:"serializer:json"` // if Type=sign, result of the transaction simulation
+	TypedData   *wltabi.TypedData  `json:",omitempty" gorm:"serializer:json"` // if Type=sign_typed_data, EIP-712 data to be signed
+	Batch       *wlttx.Batch       `json:",omitempty" gorm:"serializer:json"` // if Type=send_calls, calls to be signed and sent in order
+	Risk        *riskVerdict       `json:",omitempty" gorm:"serializer:json"` // for connect & sign requests, result of checking Host against the phishing list
 	Value       any                `json:",omitempty" gorm:"serializer:json"` // generic value
 	Result      any                `json:",omitempty" gorm:"serializer:json"` // generic response
 	Created     time.Time          `gorm:"autoCreateTime"`
@@ -82,8 +122,20 @@ func takePendingRequestChan(id string) chan string {
 	return nil
 }
 
+// releasePendingRequestChan gives back a channel taken with takePendingRequestChan, after failing to respond
+func releasePendingRequestChan(id string, ch chan string) {
+	pendingReqsLk.Lock()
+	defer pendingReqsLk.Unlock()
+	if _, ok := pendingReqs[id]; !ok {
+		pendingReqs[id] = ch
+	}
+}
+
 func (r *request) run(e *env) error {
+	timeout := e.requestTimeout(r.Type)
+	exp := time.Now().Add(timeout)
 	r.Status = "pending"
+	r.Expires = &exp
 	err := r.save(e)
 	if err != nil {
 		return fmt.Errorf("failed initial request save: %w", err)
@@ -93,7 +145,32 @@ func (r *request) run(e *env) error {
 	// send event
 	go wltutil.BroadcastMsg("request", map[string]any{"request_id": r.Id.String()})
 
-	result, ok := <-ch
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+
+	var result string
+	var ok bool
+wait:
+	for {
+		select {
+		case result, ok = <-ch:
+			break wait
+		case <-t.C:
+		}
+		if takePendingRequestChan(r.Id.String()) == nil {
+			// the request was claimed and is being responded to (signing can take a while), check again later
+			// in case the response fails
+			t.Reset(time.Second)
+			continue
+		}
+		r.Status = "timedout"
+		r.save(e)
+		go wltutil.BroadcastMsg("request:timedout", map[string]any{"request_id": r.Id.String()})
+		if r.Type == "connect" {
+			return &apirouter.Error{Code: 4100, Message: "The request timed out before the user authorized it."}
+		}
+		return &apirouter.Error{Code: 4001, Message: "The request timed out before the user approved it."}
+	}
 	if !ok {
 		r.Status = "rejected"
 		r.save(e)
@@ -105,25 +182,22 @@ func (r *request) run(e *env) error {
 	return nil
 }
 
-func (r *request) respond(e *env, resp string) error {
+// respond sets the final status of a request claimed with claim, and sends it to the waiting run()
+func (r *request) respond(e *env, ch chan string, resp string) error {
 	r.Status = resp
 	err := r.save(e)
 	if err != nil {
 		return err
 	}
 
-	ch := takePendingRequestChan(r.Id.String())
-	if ch != nil {
-		to := time.NewTimer(2 * time.Second)
-		defer to.Stop()
-		select {
-		case ch <- resp:
-			return nil
-		case <-to.C:
-			return errors.New("timed out while sending response")
-		}
+	to := time.NewTimer(2 * time.Second)
+	defer to.Stop()
+	select {
+	case ch <- resp:
+		return nil
+	case <-to.C:
+		return errors.New("timed out while sending response")
 	}
-	return nil
 }
 
 func requestTestReq(ctx context.Context) (any, error) {
@@ -170,6 +244,9 @@ func apiListRequest(ctx *apirouter.Context) (any, error) {
 	tx := e.sql
 	tx = tx.Scopes(ctx.Paginate(50))
 	tx = tx.Order("Created ASC")
+	if v, ok := apirouter.GetParam[string](ctx, "Status"); ok && v != "" {
+		tx = tx.Where(map[string]any{"Status": v})
+	}
 
 	tx = tx.Find(&res)
 	return res, tx.Error
@@ -178,6 +255,9 @@ func apiListRequest(ctx *apirouter.Context) (any, error) {
 func requestDoApprove(ctx *apirouter.Context, in struct {
 	Accounts []string
 	Keys     []*wltsign.KeyDescription
+	Chains   []string // connect: networks (e.g. evm.137) the accounts can be used on, all if empty
+	Lifetime int      // connect: seconds after which the connection expires, 0 for no expiry
+	SignOnly bool     // connect: if true the site can request signatures but not send transactions
 }) (any, error) {
 	e := apirouter.GetObject[env](ctx, "@env")
 	if e == nil {
@@ -188,6 +268,18 @@ func requestDoApprove(ctx *apirouter.Context, in struct {
 	if req == nil {
 		return nil, errors.New("request is required")
 	}
+	// claim the request first, so it cannot time out while being signed
+	ch, err := req.claim(e)
+	if err != nil {
+		return nil, err
+	}
+	responded := false
+	defer func() {
+		if !responded {
+			// the request stays pending, so approving can be retried
+			releasePendingRequestChan(req.Id.String(), ch)
+		}
+	}()
 
 	switch req.Type {
 	case "connect":
@@ -203,11 +295,23 @@ func requestDoApprove(ctx *apirouter.Context, in struct {
 			}
 			accts[a.Id.String()] = a
 		}
+		// the site keeps using the network it was using when it connected
+		n, err := e.siteNetwork(req.Host)
+		if err != nil {
+			return nil, err
+		}
+		// approved accounts get the scope chosen by the user, including those already connected
 		connAccts, _ := e.connectedAccounts(req.Host)
-		for _, a := range connAccts {
-			s := a.Account.String()
-			if _, f := accts[s]; f {
-				delete(accts, s)
+		for _, c := range connAccts {
+			if _, f := accts[c.Account.String()]; f {
+				delete(accts, c.Account.String())
+				c.Chains = in.Chains
+				c.setLifetime(in.Lifetime)
+				c.SignOnly = in.SignOnly
+				c.Network = n.Id
+				if err := c.save(e); err != nil {
+					return nil, err
+				}
 			}
 		}
 		for _, acct := range accts {
@@ -215,22 +319,19 @@ func requestDoApprove(ctx *apirouter.Context, in struct {
 			conn := &connectedSite{
 				Host:        req.Host,
 				Account:     acct.Id,
+				Chains:      in.Chains,
+				SignOnly:    in.SignOnly,
+				Network:     n.Id,
 				AccountInfo: acct,
 			}
+			conn.setLifetime(in.Lifetime)
 			err := conn.save(e)
 			if err != nil {
 				return nil, err
 			}
-			connAccts = append(connAccts, conn)
-		}
-		if len(accts) > 0 {
-			// send event
-			var list []string
-			for _, c := range connAccts {
-				list = append(list, c.AccountInfo.Address)
-			}
-			go wltutil.BroadcastMsg("js:accountsChanged", map[string]any{"accounts": list})
 		}
+		// send event
+		go e.emitAccountsChanged(req.Host)
 	case "sign":
 		if len(in.Keys) == 0 {
 			return nil, errors.New("no keys in approve sign, keys are required to sign the transaction")
@@ -239,11 +340,23 @@ func requestDoApprove(ctx *apirouter.Context, in struct {
 		if err != nil {
 			return nil, err
 		}
+	case "send_calls":
+		if len(in.Keys) == 0 {
+			return nil, errors.New("no keys in approve send_calls, keys are required to sign the transactions")
+		}
+		// calls already sent by a previous failed attempt are skipped
+		err := req.Batch.SignAndSend(e, in.Keys)
+		if err != nil {
+			return nil, err
+		}
 	case "personal_sign":
 		if len(in.Keys) == 0 {
 			return nil, errors.New("no keys in approve sign, keys are required to sign the transaction")
 		}
-		signStr := req.Value.(string) // 0x...
+		signStr, err := personalSignMessage(req.Value) // 0x...
+		if err != nil {
+			return nil, err
+		}
 		signBin, err := hex.DecodeString(signStr[2:])
 		if err != nil {
 			return nil, err
@@ -256,6 +369,30 @@ func requestDoApprove(ctx *apirouter.Context, in struct {
 			return nil, fmt.Errorf("could not find account for signature: %w", err)
 		}
 
+		signOpt := &wltsign.Opts{
+			Context: ctx,
+			IL:      a.IL,
+			Keys:    in.Keys,
+		}
+		sig, err := a.Sign(rand.Reader, messageHash, signOpt)
+		if err != nil {
+			return nil, fmt.Errorf("signature failed: %w", err)
+		}
+		str := "0x" + hex.EncodeToString(sig)
+		req.Result = &str
+	case "sign_typed_data":
+		if len(in.Keys) == 0 {
+			return nil, errors.New("no keys in approve sign, keys are required to sign the typed data")
+		}
+		messageHash, err := req.TypedData.Hash()
+		if err != nil {
+			return nil, err
+		}
+		a, err := wltacct.FindAccount(e, *req.Account)
+		if err != nil {
+			return nil, fmt.Errorf("could not find account for signature: %w", err)
+		}
+
 		signOpt := &wltsign.Opts{
 			Context: ctx,
 			IL:      a.IL,
@@ -269,7 +406,8 @@ func requestDoApprove(ctx *apirouter.Context, in struct {
 		req.Result = &str
 	}
 
-	return req, req.respond(e, "accepted")
+	responded = true
+	return req, req.respond(e, ch, "accepted")
 }
 
 func requestDoReject(ctx *apirouter.Context) (any, error) {
@@ -282,6 +420,79 @@ func requestDoReject(ctx *apirouter.Context) (any, error) {
 	if req == nil {
 		return nil, errors.New("request is required")
 	}
+	ch, err := req.claim(e)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, req.respond(e, ch, "rejected")
+}
+
+// checkPending returns an error if the request cannot be responded to anymore
+func (r *request) checkPending(e *env) error {
+	if r.Status == "pending" && r.Expires != nil && time.Now().After(*r.Expires) {
+		// should have been handled by run(), but the process may have been restarted
+		r.Status = "timedout"
+		r.save(e)
+	}
+	if r.Status != "pending" {
+		return fmt.Errorf("request is %s and cannot be responded to", r.Status)
+	}
+	return nil
+}
+
+// claim takes the pending channel of the request so only one response can be sent, and the request does not time
+// out while the response is being prepared. The channel must be passed to respond, or given back with
+// releasePendingRequestChan if the response failed.
+func (r *request) claim(e *env) (chan string, error) {
+	if err := r.checkPending(e); err != nil {
+		return nil, err
+	}
+	ch := takePendingRequestChan(r.Id.String())
+	if ch == nil {
+		// timed out right now, or being responded to
+		return nil, errors.New("request cannot be responded to anymore")
+	}
+	return ch, nil
+}
+
+func requestTimeouts(ctx context.Context) (any, error) {
+	e := apirouter.GetObject[env](ctx, "@env")
+	if e == nil {
+		return nil, errors.New("failed to get env")
+	}
+
+	res := make(map[string]int)
+	for typ := range defaultRequestTimeouts {
+		res[typ] = int(e.requestTimeout(typ) / time.Second)
+	}
+	return res, nil
+}
 
-	return req, req.respond(e, "rejected")
+func requestSetTimeout(ctx context.Context, in struct {
+	Type    string
+	Timeout int // in seconds, 0 to reset to default
+}) (any, error) {
+	e := apirouter.GetObject[env](ctx, "@env")
+	if e == nil {
+		return nil, errors.New("failed to get env")
+	}
+
+	if _, ok := defaultRequestTimeouts[in.Type]; !ok {
+		return nil, fmt.Errorf("unsupported request type %s", in.Type)
+	}
+	switch {
+	case in.Timeout < 0:
+		return nil, errors.New("timeout must be positive")
+	case in.Timeout == 0:
+		if err := e.DBSimpleDel([]byte("request_timeout"), []byte(in.Type)); err != nil {
+			return nil, err
+		}
+	default:
+		d := time.Duration(in.Timeout) * time.Second
+		if err := e.DBSimpleSet([]byte("request_timeout"), []byte(in.Type), []byte(d.String())); err != nil {
+			return nil, err
+		}
+	}
+	return requestTimeouts(ctx)
 }
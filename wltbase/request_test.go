@@ -0,0 +1,123 @@
+package wltbase
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/KarpelesLab/apirouter"
+	"github.com/KarpelesLab/xuid"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	tempEnv, err := InitTempEnv()
+	if err != nil {
+		t.Fatalf("Failed to initialize temporary environment: %v", err)
+	}
+	defer CleanupTempEnv(tempEnv)
+	e := tempEnv.(*env)
+
+	if d := e.requestTimeout("sign"); d != defaultRequestTimeouts["sign"] {
+		t.Errorf("unexpected default timeout %s", d)
+	}
+	if err := e.DBSimpleSet([]byte("request_timeout"), []byte("test"), []byte("100ms")); err != nil {
+		t.Fatalf("failed to set timeout: %s", err)
+	}
+
+	req := &request{Type: "test", Host: "www.example.com"}
+	start := time.Now()
+	err = req.run(e)
+	var apiErr *apirouter.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != 4001 {
+		t.Fatalf("expected error 4001, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("request took too long to time out")
+	}
+
+	var saved *request
+	if err := e.FirstId(&saved, req.Id); err != nil {
+		t.Fatalf("failed to load request: %s", err)
+	}
+	if saved.Status != "timedout" {
+		t.Errorf("expected request to be timedout, got %s", saved.Status)
+	}
+	if err := saved.checkPending(e); err == nil {
+		t.Errorf("expected timed out request to not accept a response")
+	}
+
+	// requests left pending by a previous run are expired at startup
+	orphan := &request{Type: "sign", Host: "www.example.com", Status: "pending"}
+	if err := orphan.save(e); err != nil {
+		t.Fatalf("failed to save request: %s", err)
+	}
+	if err := e.expireOrphanRequests(); err != nil {
+		t.Fatalf("failed to expire requests: %s", err)
+	}
+	var reloaded *request
+	if err := e.FirstId(&reloaded, orphan.Id); err != nil {
+		t.Fatalf("failed to load request: %s", err)
+	}
+	if reloaded.Status != "timedout" {
+		t.Errorf("expected orphan request to be timedout, got %s", reloaded.Status)
+	}
+}
+
+func TestRequestClaim(t *testing.T) {
+	tempEnv, err := InitTempEnv()
+	if err != nil {
+		t.Fatalf("Failed to initialize temporary environment: %v", err)
+	}
+	defer CleanupTempEnv(tempEnv)
+	e := tempEnv.(*env)
+
+	if err := e.DBSimpleSet([]byte("request_timeout"), []byte("test"), []byte("100ms")); err != nil {
+		t.Fatalf("failed to set timeout: %s", err)
+	}
+
+	// start a request and return it once it is waiting for a response
+	start := func() (*request, chan error) {
+		req := &request{Id: xuid.Must(xuid.NewRandom("req")), Type: "test", Host: "www.example.com"}
+		res := make(chan error, 1)
+		go func() { res <- req.run(e) }()
+		for {
+			time.Sleep(10 * time.Millisecond)
+			pendingReqsLk.Lock()
+			_, ok := pendingReqs[req.Id.String()]
+			pendingReqsLk.Unlock()
+			if ok {
+				return req, res
+			}
+		}
+	}
+
+	// a claimed request does not time out while the response is prepared
+	req, res := start()
+	ch, err := req.claim(e)
+	if err != nil {
+		t.Fatalf("failed to claim request: %s", err)
+	}
+	if _, err := req.claim(e); err == nil {
+		t.Errorf("expected a claimed request to not be claimed twice")
+	}
+	time.Sleep(300 * time.Millisecond)
+	if err := req.respond(e, ch, "accepted"); err != nil {
+		t.Fatalf("failed to respond: %s", err)
+	}
+	if err := <-res; err != nil {
+		t.Errorf("expected request to be accepted, got %s", err)
+	}
+
+	// if the response fails, the request times out
+	req, res = start()
+	ch, err = req.claim(e)
+	if err != nil {
+		t.Fatalf("failed to claim request: %s", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	releasePendingRequestChan(req.Id.String(), ch)
+	var apiErr *apirouter.Error
+	if err := <-res; !errors.As(err, &apiErr) || apiErr.Code != 4001 {
+		t.Errorf("expected error 4001, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package wltbase
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/EllipX/libwallet/wltacct"
+	"github.com/EllipX/libwallet/wltnet"
+	"github.com/EllipX/libwallet/wltsiwe"
+	"github.com/KarpelesLab/apirouter"
+)
+
+// siweRequestValue is the Value of personal_sign requests for Sign-In with Ethereum messages
+type siweRequestValue struct {
+	Message  string           `json:"message"` // message to sign, 0x prefixed hex
+	SIWE     *wltsiwe.Message `json:"siwe,omitempty"`
+	Warnings []string         `json:"warnings,omitempty"` // issues the user should be made aware of before signing
+}
+
+// siweRequestValue parses a SIWE message sent by the site identified by key, and returns the request value
+// along with the connection of the account named in the message. Messages naming an account that is not
+// connected to the site are refused, other inconsistencies are returned as warnings.
+func (e *env) siweRequestValue(key string, n *wltnet.Network, conn []*connectedSite, msg []byte) (*siweRequestValue, *connectedSite, error) {
+	res := &siweRequestValue{Message: "0x" + hex.EncodeToString(msg)}
+
+	m, err := wltsiwe.Parse(string(msg))
+	if err != nil {
+		res.Warnings = append(res.Warnings, fmt.Sprintf("invalid sign-in message: %s", err))
+		return res, nil, nil
+	}
+	res.SIWE = m
+
+	var addr *connectedSite
+	for _, c := range conn {
+		a, err := wltacct.FindAccount(e, c.Account.String())
+		if err == nil && strings.EqualFold(a.Address, m.Address) {
+			addr = c
+			break
+		}
+	}
+	if addr == nil {
+		return nil, nil, &apirouter.Error{Code: 4100, Message: fmt.Sprintf("personal_sign: SIWE address %s has not been authorized by the user.", m.Address)}
+	}
+
+	if !m.MatchesOrigin(key) {
+		res.Warnings = append(res.Warnings, fmt.Sprintf("sign-in domain %s does not match the requesting site %s", m.Domain, key))
+	}
+	if n != nil && n.Type == "evm" && strconv.FormatUint(m.ChainId, 10) != n.ChainId {
+		res.Warnings = append(res.Warnings, fmt.Sprintf("sign-in chain id %d does not match the current network (%s)", m.ChainId, n.ChainId))
+	}
+	if err := m.CheckTime(time.Now()); err != nil {
+		res.Warnings = append(res.Warnings, err.Error())
+	}
+	return res, addr, nil
+}
+
+// personalSignMessage returns the hex encoded message of a personal_sign request value
+func personalSignMessage(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case *siweRequestValue:
+		return v.Message, nil
+	case map[string]any:
+		// siweRequestValue after being loaded from the database
+		if s, ok := v["message"].(string); ok {
+			return s, nil
+		}
+	}
+	return "", errors.New("personal_sign: request has no message to sign")
+}
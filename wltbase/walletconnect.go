@@ -0,0 +1,321 @@
+package wltbase
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/EllipX/libwallet/wltacct"
+	"github.com/EllipX/libwallet/wltnet"
+	"github.com/EllipX/libwallet/wltutil"
+	"github.com/EllipX/libwallet/wltwc"
+	"github.com/KarpelesLab/apirouter"
+	"github.com/KarpelesLab/pobj"
+)
+
+func init() {
+	pobj.RegisterStatic("WalletConnect:configure", walletConnectConfigure)
+	pobj.RegisterStatic("WalletConnect:pair", walletConnectPair)
+	pobj.RegisterStatic("WalletConnect:sessions", walletConnectSessions)
+	pobj.RegisterStatic("WalletConnect:disconnect", walletConnectDisconnect)
+}
+
+// wcMetadata describes the wallet to dApps
+var wcMetadata = &wltwc.Metadata{
+	Name:        "EllipX",
+	Description: "EllipX Wallet",
+	URL:         "https://ellipx.com",
+	Icons:       []string{},
+}
+
+// wcEvents are the session events we can emit
+var wcEvents = []string{"accountsChanged", "chainChanged"}
+
+// walletConnect returns the WalletConnect client, connecting to the relay if needed
+func (e *env) walletConnect(ctx context.Context) (*wltwc.Client, error) {
+	e.wcLk.Lock()
+	defer e.wcLk.Unlock()
+
+	if e.wc != nil {
+		return e.wc, nil
+	}
+
+	projectId, _ := e.DBSimpleGet([]byte("walletconnect"), []byte("project_id"))
+	relayURL := wltwc.DefaultRelayURL
+	if v, err := e.DBSimpleGet([]byte("walletconnect"), []byte("relay_url")); err == nil && len(v) > 0 {
+		relayURL = string(v)
+	}
+	if len(projectId) == 0 && relayURL == wltwc.DefaultRelayURL {
+		return nil, errors.New("walletconnect: a project id is required, see WalletConnect:configure")
+	}
+
+	// the relay identifies clients by an ed25519 key, keep the same one across runs
+	seed, err := e.DBSimpleGet([]byte("walletconnect"), []byte("client_seed"))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		seed = make([]byte, ed25519.SeedSize)
+		if _, err := rand.Read(seed); err != nil {
+			return nil, err
+		}
+		if err := e.DBSimpleSet([]byte("walletconnect"), []byte("client_seed"), seed); err != nil {
+			return nil, err
+		}
+	}
+
+	relay, err := wltwc.DialRelay(ctx, relayURL, string(projectId), ed25519.NewKeyFromSeed(seed))
+	if err != nil {
+		return nil, err
+	}
+	c, err := wltwc.NewClient(ctx, e, relay, wcMetadata, &wcHandler{e: e})
+	if err != nil {
+		relay.Close()
+		return nil, err
+	}
+	e.wc = c
+	return c, nil
+}
+
+// resumeWalletConnect connects to the relay at startup if sessions are active, so dApps can reach us
+func (e *env) resumeWalletConnect() {
+	if e.Count(&wltwc.Session{}) == 0 {
+		return
+	}
+	if _, err := e.walletConnect(e); err != nil {
+		log.Printf("walletconnect: failed to resume sessions: %s", err)
+	}
+}
+
+// wcHandler maps WalletConnect proposals & requests onto the request/connectedSite flow used by Web3:request
+type wcHandler struct {
+	e *env
+}
+
+func (h *wcHandler) SessionProposal(p *wltwc.Proposal) (map[string]*wltwc.Namespace, error) {
+	e := h.e
+	host := p.Host()
+	if host == "" {
+		return nil, errors.New("walletconnect: proposer url is missing")
+	}
+
+	// only eip155 (evm) chains are supported, and all required chains must be known
+	var chains, methods, events []string
+	for family, ns := range p.RequiredNamespaces {
+		if family != "eip155" && !strings.HasPrefix(family, "eip155:") {
+			return nil, wltwc.ErrUnsupportedChains
+		}
+		for _, c := range wcNamespaceChains(family, ns) {
+			if _, err := wcNetwork(e, c); err != nil {
+				return nil, wltwc.ErrUnsupportedChains
+			}
+			chains = wcAppend(chains, c)
+		}
+		for _, m := range ns.Methods {
+			if !wcSupportedMethod(m) {
+				return nil, wltwc.ErrUnsupportedMethods
+			}
+			methods = wcAppend(methods, m)
+		}
+		for _, ev := range ns.Events {
+			if slices.Contains(wcEvents, ev) {
+				events = wcAppend(events, ev)
+			}
+		}
+	}
+	for family, ns := range p.OptionalNamespaces {
+		if family != "eip155" && !strings.HasPrefix(family, "eip155:") {
+			continue
+		}
+		for _, c := range wcNamespaceChains(family, ns) {
+			if _, err := wcNetwork(e, c); err == nil {
+				chains = wcAppend(chains, c)
+			}
+		}
+		for _, m := range ns.Methods {
+			if wcSupportedMethod(m) {
+				methods = wcAppend(methods, m)
+			}
+		}
+		for _, ev := range ns.Events {
+			if slices.Contains(wcEvents, ev) {
+				events = wcAppend(events, ev)
+			}
+		}
+	}
+	if len(chains) == 0 {
+		// dApp did not specify any chain, offer the current one
+		n, err := wltnet.CurrentNetwork(e)
+		if err != nil || n.Type != "evm" {
+			return nil, wltwc.ErrUnsupportedChains
+		}
+		chains = append(chains, "eip155:"+n.ChainId)
+	}
+
+	req := &request{
+		Type:  "connect",
+		Host:  host,
+		Value: p,
+		Risk:  e.originRisk(host),
+	}
+	if err := req.run(e); err != nil {
+		return nil, wcError(err)
+	}
+	conn, err := e.connectedAccounts(host)
+	if err != nil || len(conn) == 0 {
+		return nil, wltwc.ErrUserRejected
+	}
+
+	var accounts []string
+	for _, c := range chains {
+		for _, cnx := range conn {
+			if a, err := wltacct.FindAccount(e, cnx.Account.String()); err == nil {
+				accounts = append(accounts, c+":"+a.Address)
+			}
+		}
+	}
+	res := map[string]*wltwc.Namespace{
+		"eip155": {
+			Chains:   chains,
+			Methods:  methods,
+			Events:   events,
+			Accounts: accounts,
+		},
+	}
+	go wltutil.BroadcastMsg("walletconnect:session", map[string]any{"host": host})
+	return res, nil
+}
+
+func (h *wcHandler) SessionRequest(s *wltwc.Session, chainId, method string, params any) (any, error) {
+	n, err := wcNetwork(h.e, chainId)
+	if err != nil {
+		return nil, wltwc.ErrUnsupportedChains
+	}
+	res, err := h.e.web3Call(s.Host(), n, method, web3Params(params))
+	if err != nil {
+		return nil, wcError(err)
+	}
+	return res, nil
+}
+
+func (h *wcHandler) SessionDeleted(s *wltwc.Session) {
+	go wltutil.BroadcastMsg("walletconnect:session_deleted", map[string]any{"topic": s.Topic, "host": s.Host()})
+}
+
+// wcNamespaceChains returns the chains of a proposal namespace, which can be given as keys (eip155:1)
+func wcNamespaceChains(family string, ns *wltwc.Namespace) []string {
+	if strings.Contains(family, ":") {
+		return []string{family}
+	}
+	return ns.Chains
+}
+
+// wcNetwork returns the network matching a CAIP-2 chain id such as eip155:1
+func wcNetwork(e *env, chainId string) (*wltnet.Network, error) {
+	ref, ok := strings.CutPrefix(chainId, "eip155:")
+	if !ok || ref == "" {
+		return nil, fmt.Errorf("unsupported chain %s", chainId)
+	}
+	return wltnet.NetworkById(e, wltnet.NetworkIdForTypeAndChainId("evm", ref))
+}
+
+// wcSupportedMethod returns true if method can be handled by web3Call
+func wcSupportedMethod(method string) bool {
+	switch method {
+	case "personal_sign", "eth_signTypedData_v4", "eth_sendTransaction", "wallet_addEthereumChain", "wallet_switchEthereumChain",
+		"wallet_requestPermissions", "wallet_getPermissions", "wallet_watchAsset", "wallet_getCapabilities",
+		"wallet_sendCalls", "wallet_getCallsStatus", "web3_clientVersion", "web3_sha3":
+		return true
+	}
+	return wltnet.RPCCategory(method) == wltnet.RPCRead
+}
+
+func wcAppend(list []string, v string) []string {
+	if slices.Contains(list, v) {
+		return list
+	}
+	return append(list, v)
+}
+
+// wcError converts errors returned by requests (which use EIP-1193 codes) to JSON-RPC errors for the dApp
+func wcError(err error) error {
+	var apiErr *apirouter.Error
+	if errors.As(err, &apiErr) {
+		return &wltwc.RPCError{Code: apiErr.Code, Message: apiErr.Message}
+	}
+	return err
+}
+
+func walletConnectConfigure(ctx context.Context, in struct {
+	ProjectId string
+	RelayURL  string
+}) (any, error) {
+	e := apirouter.GetObject[env](ctx, "@env")
+	if e == nil {
+		return nil, errors.New("failed to get env")
+	}
+
+	for k, v := range map[string]string{"project_id": in.ProjectId, "relay_url": in.RelayURL} {
+		var err error
+		if v == "" {
+			err = e.DBSimpleDel([]byte("walletconnect"), []byte(k))
+		} else {
+			err = e.DBSimpleSet([]byte("walletconnect"), []byte(k), []byte(v))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// reconnect with the new settings next time the client is needed
+	e.wcLk.Lock()
+	if e.wc != nil {
+		e.wc.Close()
+		e.wc = nil
+	}
+	e.wcLk.Unlock()
+	return nil, nil
+}
+
+func walletConnectPair(ctx context.Context, in struct {
+	URI string
+}) (any, error) {
+	e := apirouter.GetObject[env](ctx, "@env")
+	if e == nil {
+		return nil, errors.New("failed to get env")
+	}
+
+	c, err := e.walletConnect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.Pair(ctx, in.URI)
+}
+
+func walletConnectSessions(ctx context.Context) (any, error) {
+	e := apirouter.GetObject[env](ctx, "@env")
+	if e == nil {
+		return nil, errors.New("failed to get env")
+	}
+
+	var res []*wltwc.Session
+	err := e.Find(&res, map[string]any{})
+	return res, err
+}
+
+func walletConnectDisconnect(ctx context.Context, in struct {
+	Topic string
+}) (any, error) {
+	e := apirouter.GetObject[env](ctx, "@env")
+	if e == nil {
+		return nil, errors.New("failed to get env")
+	}
+
+	c, err := e.walletConnect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return nil, c.Disconnect(ctx, in.Topic)
+}
@@ -5,12 +5,15 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"log"
 	"math/big"
-	"net/url"
 	"strings"
 
+	"github.com/EllipX/libwallet/wltabi"
 	"github.com/EllipX/libwallet/wltacct"
+	"github.com/EllipX/libwallet/wltasset"
 	"github.com/EllipX/libwallet/wltnet"
+	"github.com/EllipX/libwallet/wltsiwe"
 	"github.com/EllipX/libwallet/wlttx"
 	"github.com/KarpelesLab/apirouter"
 	"github.com/KarpelesLab/pobj"
@@ -26,21 +29,71 @@ func init() {
 // Implement JSON-RPC methods from ethereum
 
 type eip2255caveat struct {
-	Type  string   `json:"type"`
-	Value []string `json:"value"`
+	Type  string `json:"type"`
+	Value any    `json:"value"`
 }
 
 type eip2255perm struct {
-	Id      string           `json:"id"`
-	Invoker string           `json:"invoker"`
-	Caveats []*eip2255caveat `json:"caveats"`
+	Id               string           `json:"id"`
+	Invoker          string           `json:"invoker"`
+	ParentCapability string           `json:"parentCapability"`
+	Caveats          []*eip2255caveat `json:"caveats"`
+	Date             int64            `json:"date"` // unix time in ms
+}
+
+// eip2255permission returns the permission object describing a connection
+func (c *connectedSite) eip2255permission(a *wltacct.Account) *eip2255perm {
+	res := &eip2255perm{
+		Id:               c.Id.String(),
+		Invoker:          c.Host,
+		ParentCapability: "eth_accounts",
+		Caveats: []*eip2255caveat{
+			&eip2255caveat{
+				Type:  "restrictReturnedAccounts",
+				Value: []string{a.Address},
+			},
+		},
+		Date: c.Created.UnixMilli(),
+	}
+	if len(c.Chains) > 0 {
+		res.Caveats = append(res.Caveats, &eip2255caveat{Type: "restrictNetworks", Value: c.Chains})
+	}
+	if c.Expires != nil {
+		res.Caveats = append(res.Caveats, &eip2255caveat{Type: "expiresAt", Value: c.Expires.UnixMilli()})
+	}
+	if c.SignOnly {
+		res.Caveats = append(res.Caveats, &eip2255caveat{Type: "signOnly", Value: true})
+	}
+	return res
+}
+
+// eip2255permissionNames returns the names of permissions in the parameter of wallet_requestPermissions
+// and wallet_revokePermissions, such as [{ eth_accounts: {} }]
+func eip2255permissionNames(method string, params []any) ([]string, error) {
+	if len(params) != 1 {
+		return nil, fmt.Errorf("%s requires one param", method)
+	}
+	pmap, ok := params[0].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s requires param[0] to be an object", method)
+	}
+	var perms []string
+	for k := range pmap {
+		switch k {
+		case "eth_accounts":
+			perms = append(perms, k)
+		default:
+			return nil, fmt.Errorf("unsupported permission %s", k)
+		}
+	}
+	return perms, nil
 }
 
 func web3Req(ctx context.Context, in struct {
 	URL   string `json:"url"`
 	Query struct {
 		Method string `json:"method"`
-		Params []any  `json:"params"`
+		Params any    `json:"params"`
 	} `json:"query"`
 }) (any, error) {
 	e := apirouter.GetObject[env](ctx, "@env")
@@ -48,35 +101,49 @@ func web3Req(ctx context.Context, in struct {
 		return nil, errors.New("failed to get env")
 	}
 
-	// parse host from url
-	u, err := url.Parse(in.URL)
+	// key is only scheme and host (Host includes the port in url if any was specified)
+	key, err := originKey(in.URL)
 	if err != nil {
 		return nil, err
 	}
-	if u.Host == "" {
-		return nil, errors.New("url: host is missing")
-	}
-	// key is only scheme and host (Host includes the port in url if any was specified)
-	key := (&url.URL{Scheme: u.Scheme, Host: u.Host}).String()
-
-	conn, _ := e.connectedAccounts(key)
 
-	// fetch current network
-	n, err := wltnet.CurrentNetwork(e)
+	// fetch the network selected by the site
+	n, err := e.siteNetwork(key)
 	if err != nil {
 		return nil, err
 	}
 
+	return e.web3Call(key, n, in.Query.Method, web3Params(in.Query.Params))
+}
+
+// web3Params returns the params of a JSON-RPC request as an array. EIP-1193 allows params to be an object, in which
+// case it is returned as the only element.
+func web3Params(params any) []any {
+	switch v := params.(type) {
+	case nil:
+		return nil
+	case []any:
+		return v
+	default:
+		return []any{v}
+	}
+}
+
+// web3Call handles a JSON-RPC request from the site identified by key (scheme://host) on network n
+func (e *env) web3Call(key string, n *wltnet.Network, method string, params []any) (any, error) {
+	// connections restricted to other networks are ignored
+	conn, _ := e.permittedAccounts(key, n)
+
 	// See: https://docs.metamask.io/wallet/reference/wallet_addethereumchain/
 
-	switch in.Query.Method {
+	switch method {
 	case "web3_clientVersion":
 		return "libwallet/" + dateTag + "-" + gitTag, nil
 	case "web3_sha3":
-		if len(in.Query.Params) != 1 {
+		if len(params) != 1 {
 			return nil, errors.New("web3_sha3 expects exactly 1 param")
 		}
-		v := web3HexValue(in.Query.Params[0])
+		v := web3HexValue(params[0])
 		if v == nil {
 			return nil, errors.New("invalid parameter")
 		}
@@ -88,14 +155,14 @@ func web3Req(ctx context.Context, in struct {
 		req := &request{
 			Type: "connect",
 			Host: key,
+			Risk: e.originRisk(key),
 		}
 		err := req.run(e)
 		if err != nil {
 			return nil, err
 		}
 		// approved
-		conn = nil
-		e.sql.Where(map[string]any{"Host": key}).Find(&conn)
+		conn, _ = e.permittedAccounts(key, n)
 
 		if len(conn) == 0 {
 			return nil, nil
@@ -112,63 +179,54 @@ func web3Req(ctx context.Context, in struct {
 		return res, nil
 	case "wallet_requestPermissions":
 		// params: [{ eth_accounts: {} }],
-		if len(in.Query.Params) != 1 {
-			return nil, errors.New("wallet_requestPermissions requires one param")
-		}
-		// this is crappy, but we need to check if params[0] is indeed a map[string]any{"eth_accounts":map[string]any{}}
-		pmap, ok := in.Query.Params[0].(map[string]any)
-		if !ok {
-			return nil, errors.New("wallet_requestPermissions requires param[0] to be an object")
-		}
-		var perms []string
-		for k, _ := range pmap {
-			switch k {
-			case "eth_accounts":
-				perms = append(perms, k)
-			default:
-				return nil, fmt.Errorf("unsupported permission %s", k)
-			}
+		perms, err := eip2255permissionNames(method, params)
+		if err != nil {
+			return nil, err
 		}
 		if len(perms) > 0 {
 			// can only be eth_accounts
 			req := &request{
 				Type: "connect",
 				Host: key,
+				Risk: e.originRisk(key),
 			}
 			err := req.run(e)
 			if err != nil {
 				return nil, err
 			}
 			// approved
-			conn = nil
-			e.sql.Where(map[string]any{"Host": key}).Find(&conn)
-
-			if len(conn) == 0 {
-				return nil, nil
-			}
 		}
 		fallthrough
 	case "wallet_getPermissions":
-		res := make([]*eip2255perm, 0, len(conn))
-		for _, c := range conn {
+		// return all connections, including those restricted to other networks
+		all, err := e.connectedAccounts(key)
+		if err != nil {
+			return nil, err
+		}
+		res := make([]*eip2255perm, 0, len(all))
+		for _, c := range all {
 			a, err := wltacct.FindAccount(e, c.Account.String())
 			if err == nil {
-				tmp := &eip2255perm{
-					Id:      c.Id.String(),
-					Invoker: c.Host,
-					Caveats: []*eip2255caveat{
-						&eip2255caveat{
-							Type:  "restrictReturnedAccounts",
-							Value: []string{a.Address},
-						},
-					},
-				}
-				res = append(res, tmp)
+				res = append(res, c.eip2255permission(a))
 			}
 		}
 		return res, nil
+	case "wallet_revokePermissions":
+		// params: [{ eth_accounts: {} }],
+		perms, err := eip2255permissionNames(method, params)
+		if err != nil {
+			return nil, err
+		}
+		if len(perms) > 0 {
+			// can only be eth_accounts, disconnect all accounts
+			if err := e.DeleteWhere(&connectedSite{}, map[string]any{"Host": key}); err != nil {
+				return nil, err
+			}
+			go e.emitAccountsChanged(key)
+		}
+		return nil, nil
 	case "personal_sign":
-		if len(in.Query.Params) < 1 {
+		if len(params) < 1 {
 			return nil, errors.New("personal_sign requires at least one parameter")
 		}
 		// params: [0xhex_msg, 0xoptional_sign_addr]
@@ -176,8 +234,8 @@ func web3Req(ctx context.Context, in struct {
 			return nil, errors.New("no addr available")
 		}
 		addr := conn[0]
-		if len(in.Query.Params) >= 2 {
-			signAddr := strings.ToLower(in.Query.Params[1].(string))
+		if len(params) >= 2 {
+			signAddr := strings.ToLower(params[1].(string))
 			// addr in params[1], format is 0x...
 			addr = nil
 			for _, c := range conn {
@@ -190,7 +248,7 @@ func web3Req(ctx context.Context, in struct {
 				}
 			}
 		}
-		val, ok := in.Query.Params[0].(string)
+		val, ok := params[0].(string)
 		if !ok {
 			return nil, errors.New("invalid string for signature")
 		}
@@ -201,6 +259,24 @@ func web3Req(ctx context.Context, in struct {
 		if err != nil {
 			return nil, fmt.Errorf("personal_sign: invalid value: %w", err)
 		}
+		var value any = "0x" + hex.EncodeToString(valBin)
+		if wltsiwe.IsSIWE(string(valBin)) {
+			// sign-in request, the message must be signed by the account it names
+			v, siweAddr, err := e.siweRequestValue(key, n, conn, valBin)
+			if err != nil {
+				return nil, err
+			}
+			if siweAddr != nil {
+				if len(params) >= 2 && (addr == nil || addr.Account.String() != siweAddr.Account.String()) {
+					return nil, &apirouter.Error{Code: 4100, Message: "personal_sign: the SIWE message address does not match the signing address."}
+				}
+				addr = siweAddr
+			}
+			value = v
+		}
+		if addr == nil {
+			return nil, &apirouter.Error{Code: 4100, Message: "The requested account has not been authorized by the user."}
+		}
 		a, err := wltacct.FindAccount(e, addr.Account.String())
 		if err != nil {
 			return nil, fmt.Errorf("failed to load account: %w", err)
@@ -210,7 +286,55 @@ func web3Req(ctx context.Context, in struct {
 			Type:    "personal_sign",
 			Host:    key,
 			Account: &a.Address,
-			Value:   "0x" + hex.EncodeToString(valBin),
+			Value:   value,
+			Risk:    e.originRisk(key),
+		}
+		err = req.run(e)
+		if err != nil {
+			return nil, err
+		}
+		// approved
+		return req.Result, nil
+	case "eth_signTypedData_v4":
+		// params: [0xsign_addr, typed_data]
+		if len(params) < 2 {
+			return nil, errors.New("eth_signTypedData_v4 requires 2 parameters")
+		}
+		signAddr, ok := params[0].(string)
+		if !ok {
+			return nil, errors.New("eth_signTypedData_v4: invalid address")
+		}
+		var a *wltacct.Account
+		for _, c := range conn {
+			acct, err := wltacct.FindAccount(e, c.Account.String())
+			if err == nil && strings.EqualFold(acct.Address, signAddr) {
+				a = acct
+				break
+			}
+		}
+		if a == nil {
+			return nil, &apirouter.Error{Code: 4100, Message: "The requested account has not been authorized by the user."}
+		}
+		td, err := wltabi.ParseTypedData(params[1])
+		if err != nil {
+			return nil, err
+		}
+		if chainId, err := td.ChainId(); err != nil {
+			return nil, fmt.Errorf("eth_signTypedData_v4: invalid chainId: %w", err)
+		} else if chainId != nil && n.Type == "evm" && chainId.String() != n.ChainId {
+			return nil, fmt.Errorf("eth_signTypedData_v4: chainId %s does not match the current network (%s)", chainId, n.ChainId)
+		}
+		// make sure the data can be hashed before asking the user
+		if _, err := td.Hash(); err != nil {
+			return nil, err
+		}
+
+		req := &request{
+			Type:      "sign_typed_data",
+			Host:      key,
+			Account:   &a.Address,
+			TypedData: td,
+			Risk:      e.originRisk(key),
 		}
 		err = req.run(e)
 		if err != nil {
@@ -219,22 +343,33 @@ func web3Req(ctx context.Context, in struct {
 		// approved
 		return req.Result, nil
 	case "eth_sendTransaction":
-		if len(in.Query.Params) < 1 {
+		if len(params) < 1 {
 			return nil, errors.New("eth_sendTransaction requires a transaction to sign")
 		}
-		tx, err := typutil.As[*wlttx.Transaction](in.Query.Params[0])
+		tx, err := typutil.As[*wlttx.Transaction](params[0])
 		if err != nil {
 			return nil, err
 		}
 		tx.Type = "evm"
+		tx.Network = n.Id
 		err = tx.Validate(e)
 		if err != nil {
 			return nil, err
 		}
+		if err := checkCanTransact(e, conn, tx.From); err != nil {
+			return nil, err
+		}
+		// simulate so the user can see what will happen, failure to simulate is not fatal
+		sim, err := tx.Simulate(e)
+		if err != nil {
+			log.Printf("failed to simulate transaction: %s", err)
+		}
 		req := &request{
 			Type:        "sign",
 			Host:        key,
 			Transaction: tx,
+			Simulation:  sim,
+			Risk:        e.originRisk(key),
 		}
 		err = req.run(e)
 		if err != nil {
@@ -242,11 +377,62 @@ func web3Req(ctx context.Context, in struct {
 		}
 		// approved
 		return req.Transaction.Hash, nil
+	case "wallet_getCapabilities":
+		// params: [address, [chainId, ...]]
+		if len(params) < 1 {
+			return nil, errors.New("wallet_getCapabilities requires an address")
+		}
+		addr, err := typutil.As[string](params[0])
+		if err != nil {
+			return nil, err
+		}
+		var chainIds []string
+		if len(params) > 1 {
+			chainIds, err = typutil.As[[]string](params[1])
+			if err != nil {
+				return nil, err
+			}
+		}
+		return e.walletCapabilities(conn, addr, chainIds)
+	case "wallet_sendCalls":
+		if len(params) < 1 {
+			return nil, errors.New("wallet_sendCalls requires 1 parameter")
+		}
+		b, err := e.sendCallsBatch(key, n, conn, params[0])
+		if err != nil {
+			return nil, err
+		}
+		req := &request{
+			Type:  "send_calls",
+			Host:  key,
+			Batch: b,
+			Risk:  e.originRisk(key),
+		}
+		err = req.run(e)
+		if err != nil {
+			return nil, err
+		}
+		// approved
+		return map[string]any{"id": b.Id}, nil
+	case "wallet_getCallsStatus":
+		if len(params) < 1 {
+			return nil, errors.New("wallet_getCallsStatus requires 1 parameter")
+		}
+		id, err := typutil.As[string](params[0])
+		if err != nil {
+			return nil, err
+		}
+		// batches of other sites are unknown to this one
+		b, err := wlttx.BatchById(e, key, id)
+		if err != nil {
+			return nil, &apirouter.Error{Code: 5730, Message: fmt.Sprintf("Unknown batch id %s.", id)}
+		}
+		return b.Status(e)
 	case "wallet_addEthereumChain":
-		if len(in.Query.Params) < 1 {
+		if len(params) < 1 {
 			return nil, errors.New("wallet_addEthereumChain requires 1 parameter")
 		}
-		obj, err := typutil.As[*wltnet.AddEthereumChainParameter](in.Query.Params[0])
+		obj, err := typutil.As[*wltnet.AddEthereumChainParameter](params[0])
 		if err != nil {
 			return nil, err
 		}
@@ -277,10 +463,10 @@ func web3Req(ctx context.Context, in struct {
 		}
 		return nil, nil
 	case "wallet_switchEthereumChain":
-		if len(in.Query.Params) < 1 {
+		if len(params) < 1 {
 			return nil, errors.New("wallet_switchEthereumChain requires 1 parameter")
 		}
-		s, err := typutil.As[string](in.Query.Params[0])
+		s, err := typutil.As[string](params[0])
 		if err != nil {
 			return nil, err
 		}
@@ -305,17 +491,51 @@ func web3Req(ctx context.Context, in struct {
 		if err != nil {
 			return nil, err
 		}
-		// approved
-		err = net.SetCurrent(e)
+		// approved, only change the network of this site
+		err = e.setSiteNetwork(key, net)
 		if err != nil {
 			return nil, err
 		}
 		return nil, nil
+	case "wallet_watchAsset":
+		if len(params) < 1 {
+			return nil, errors.New("wallet_watchAsset requires 1 parameter")
+		}
+		asset, err := watchAsset(n, params[0])
+		if err != nil {
+			return nil, err
+		}
+		var existing *wltasset.Asset
+		if e.FirstWhere(&existing, map[string]any{"Key": asset.Key}) == nil {
+			// already watched
+			return true, nil
+		}
+
+		req := &request{
+			Type:  "watch_asset",
+			Host:  key,
+			Value: asset,
+			Risk:  e.originRisk(key),
+		}
+		err = req.run(e)
+		if err != nil {
+			return nil, err
+		}
+		// approved
+		err = saveWatchedAsset(e, asset)
+		if err != nil {
+			return nil, err
+		}
+		return true, nil
 	case "wallet_registerOnboarding":
 		return false, nil
 	default:
-		// relay to current network
-		return n.DoRPC(in.Query.Method, in.Query.Params...)
+		// relay to current network if allowed
+		if pol := n.RPCPolicy(method); pol != wltnet.RPCRead {
+			log.Printf("web3: rejected %s method %s from %s on %s", pol, method, key, n)
+			return nil, &apirouter.Error{Code: 4200, Message: "The requested method is not supported by this Ethereum provider."}
+		}
+		return n.DoRPC(method, params...)
 	}
 }
 
@@ -2,10 +2,15 @@ package wltbase
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/EllipX/libwallet/wltacct"
+	"github.com/EllipX/libwallet/wltnet"
+	"github.com/EllipX/libwallet/wltutil"
 	"github.com/KarpelesLab/apirouter"
 	"github.com/KarpelesLab/pobj"
 	"github.com/KarpelesLab/xuid"
@@ -25,17 +30,40 @@ type connectedSite struct {
 	Id          *xuid.XUID       `gorm:"primaryKey"`
 	Host        string           `gorm:"index:Host_Account,unique"`
 	Account     *xuid.XUID       `gorm:"index:Host_Account,unique"`
+	Chains      []string         `gorm:"serializer:json"` // networks (e.g. evm.137) the account can be used on, all if empty
+	Expires     *time.Time       // time after which the connection is removed, if set
+	SignOnly    bool             // if true, the site can request signatures but cannot send transactions
+	Network     *xuid.XUID       // network selected by the site, the wallet's current network if nil
 	Created     time.Time        `gorm:"autoCreateTime"`
 	Updated     time.Time        `gorm:"autoUpdateTime"`
 	AccountInfo *wltacct.Account `gorm:"-:all"`
 }
 
+// allows returns true if the connection is valid on network n
+func (c *connectedSite) allows(n *wltnet.Network) bool {
+	return len(c.Chains) == 0 || slices.Contains(c.Chains, n.String())
+}
+
+// connectedAccounts returns the connections of a site, starting with the current account. Expired connections are removed.
 func (e *env) connectedAccounts(key string) ([]*connectedSite, error) {
 	var conn []*connectedSite
 	res := e.sql.Where(map[string]any{"Host": key}).Find(&conn)
 	if res.Error != nil {
 		return nil, res.Error
 	}
+	now := time.Now()
+	expired := false
+	conn = slices.DeleteFunc(conn, func(c *connectedSite) bool {
+		if c.Expires == nil || c.Expires.After(now) {
+			return false
+		}
+		e.Delete(c)
+		expired = true
+		return true
+	})
+	if expired {
+		go e.emitAccountsChanged(key)
+	}
 	if len(conn) <= 1 {
 		// no point changing the order if only 1 account
 		return conn, nil
@@ -58,6 +86,78 @@ func (e *env) connectedAccounts(key string) ([]*connectedSite, error) {
 	return conn, nil
 }
 
+// permittedAccounts returns the connections of a site that can be used on network n
+func (e *env) permittedAccounts(key string, n *wltnet.Network) ([]*connectedSite, error) {
+	conn, err := e.connectedAccounts(key)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(conn, func(c *connectedSite) bool { return !c.allows(n) }), nil
+}
+
+// siteNetwork returns the network selected by the site identified by key. Sites that are not connected, or
+// have not selected a network, use the wallet's current network.
+func (e *env) siteNetwork(key string) (*wltnet.Network, error) {
+	var conn []*connectedSite
+	e.sql.Where(map[string]any{"Host": key}).Find(&conn)
+	for _, c := range conn {
+		if c.Network == nil {
+			continue
+		}
+		n, err := wltnet.NetworkById(e, c.Network)
+		if err == nil {
+			return n, nil
+		}
+		// network was likely removed, fallback to the current network
+		log.Printf("web3: network %s of %s not found: %s", c.Network, key, err)
+		break
+	}
+	return wltnet.CurrentNetwork(e)
+}
+
+// setSiteNetwork changes the network selected by the site identified by key, and notifies the site. If the
+// site is not connected, the wallet's current network is changed instead.
+func (e *env) setSiteNetwork(key string, n *wltnet.Network) error {
+	res := e.sql.Model(&connectedSite{}).Where(map[string]any{"Host": key}).Update("Network", n.Id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return n.SetCurrent(e)
+	}
+	go wltutil.BroadcastMsg("js:chainChanged", map[string]any{"host": key, "chainId": n.ChainId})
+	return nil
+}
+
+// checkCanTransact returns an error unless addr is connected and allowed to send transactions
+func checkCanTransact(e *env, conn []*connectedSite, addr string) error {
+	for _, c := range conn {
+		a, err := wltacct.AccountById(e, c.Account)
+		if err != nil || !strings.EqualFold(a.Address, addr) {
+			continue
+		}
+		if c.SignOnly {
+			return &apirouter.Error{Code: 4100, Message: fmt.Sprintf("Account %s is only authorized to sign messages.", addr)}
+		}
+		return nil
+	}
+	return &apirouter.Error{Code: 4100, Message: fmt.Sprintf("Account %s has not been authorized by the user.", addr)}
+}
+
+// emitAccountsChanged notifies the site identified by key of its accounts on the current network
+func (e *env) emitAccountsChanged(key string) {
+	list := []string{}
+	if n, err := e.siteNetwork(key); err == nil {
+		conn, _ := e.permittedAccounts(key, n)
+		for _, c := range conn {
+			if a, err := wltacct.AccountById(e, c.Account); err == nil {
+				list = append(list, a.Address)
+			}
+		}
+	}
+	wltutil.BroadcastMsg("js:accountsChanged", map[string]any{"host": key, "accounts": list})
+}
+
 func (c *connectedSite) save(e *env) error {
 	if c.Id == nil {
 		c.Id = xuid.Must(xuid.NewRandom("cnx"))
@@ -127,7 +227,57 @@ func (c *connectedSite) ApiDelete(ctx *apirouter.Context) error {
 	}
 
 	tx := e.sql.Delete(c)
-	return tx.Error
+	if tx.Error != nil {
+		return tx.Error
+	}
+	go e.emitAccountsChanged(c.Host)
+	return nil
+}
+
+func (c *connectedSite) ApiUpdate(ctx *apirouter.Context) error {
+	e := apirouter.GetObject[env](ctx, "@env")
+	if e == nil {
+		return errors.New("failed to get env")
+	}
+
+	if v, ok := apirouter.GetParam[[]string](ctx, "Chains"); ok {
+		c.Chains = v
+	}
+	if v, ok := apirouter.GetParam[int](ctx, "Lifetime"); ok {
+		c.setLifetime(v)
+	}
+	if v, ok := apirouter.GetParam[bool](ctx, "SignOnly"); ok {
+		c.SignOnly = v
+	}
+	if err := c.save(e); err != nil {
+		return err
+	}
+	if v, ok := apirouter.GetParam[string](ctx, "Network"); ok {
+		id, err := xuid.Parse(v)
+		if err != nil {
+			return err
+		}
+		n, err := wltnet.NetworkById(e, id)
+		if err != nil {
+			return err
+		}
+		// the network is shared by all the connections of the site
+		if err := e.setSiteNetwork(c.Host, n); err != nil {
+			return err
+		}
+	}
+	go e.emitAccountsChanged(c.Host)
+	return nil
+}
+
+// setLifetime sets the connection to expire after the given number of seconds, or never if 0
+func (c *connectedSite) setLifetime(seconds int) {
+	if seconds <= 0 {
+		c.Expires = nil
+		return
+	}
+	exp := time.Now().Add(time.Duration(seconds) * time.Second)
+	c.Expires = &exp
 }
 
 func apiCreateWeb3Connection(ctx *apirouter.Context, ct *connectedSite) (any, error) {
@@ -145,6 +295,17 @@ func apiCreateWeb3Connection(ctx *apirouter.Context, ct *connectedSite) (any, er
 		return nil, err
 	}
 	ct.AccountInfo = acct
+	if ct.Network == nil {
+		n, err := e.siteNetwork(ct.Host)
+		if err != nil {
+			return nil, err
+		}
+		ct.Network = n.Id
+	}
 
-	return ct, ct.save(e)
+	if err := ct.save(e); err != nil {
+		return nil, err
+	}
+	go e.emitAccountsChanged(ct.Host)
+	return ct, nil
 }
@@ -0,0 +1,58 @@
+package wltbase
+
+import (
+	"testing"
+
+	"github.com/EllipX/libwallet/wltnet"
+	"github.com/KarpelesLab/xuid"
+)
+
+func TestSiteNetwork(t *testing.T) {
+	tempEnv, err := InitTempEnv()
+	if err != nil {
+		t.Fatalf("Failed to initialize temporary environment: %v", err)
+	}
+	defer CleanupTempEnv(tempEnv)
+	e := tempEnv.(*env)
+
+	cur, err := wltnet.CurrentNetwork(e)
+	if err != nil {
+		t.Fatalf("failed to get current network: %s", err)
+	}
+	other := &wltnet.Network{Type: "evm", ChainId: "137", Name: "Polygon"}
+	if err := other.Save(e); err != nil {
+		t.Fatalf("failed to save network: %s", err)
+	}
+
+	for _, host := range []string{"https://a.example.com", "https://b.example.com"} {
+		c := &connectedSite{Host: host, Account: xuid.Must(xuid.NewRandom("acct")), Network: cur.Id}
+		if err := c.save(e); err != nil {
+			t.Fatalf("failed to save connection: %s", err)
+		}
+	}
+
+	// switching on a connected site only affects that site
+	if err := e.setSiteNetwork("https://a.example.com", other); err != nil {
+		t.Fatalf("failed to set site network: %s", err)
+	}
+	if n, err := e.siteNetwork("https://a.example.com"); err != nil || n.Id.String() != other.Id.String() {
+		t.Errorf("expected a.example.com to use %s, got %v (%v)", other.Id, n, err)
+	}
+	if n, err := e.siteNetwork("https://b.example.com"); err != nil || n.Id.String() != cur.Id.String() {
+		t.Errorf("expected b.example.com to use %s, got %v (%v)", cur.Id, n, err)
+	}
+	if id, _ := wltnet.CurrentNetworkId(e); id != "" && id != cur.Id.String() {
+		t.Errorf("current network should not have changed, got %s", id)
+	}
+
+	// sites that are not connected use and change the wallet's current network
+	if err := e.setSiteNetwork("https://c.example.com", other); err != nil {
+		t.Fatalf("failed to set site network: %s", err)
+	}
+	if id, _ := wltnet.CurrentNetworkId(e); id != other.Id.String() {
+		t.Errorf("expected current network to be %s, got %s", other.Id, id)
+	}
+	if n, err := e.siteNetwork("https://b.example.com"); err != nil || n.Id.String() != cur.Id.String() {
+		t.Errorf("expected b.example.com to still use %s, got %v (%v)", cur.Id, n, err)
+	}
+}
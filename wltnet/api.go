@@ -144,6 +144,14 @@ func (n *Network) ApiUpdate(ctx *apirouter.Context) error {
 		n.Priority = v
 		updated = true
 	}
+	if v, ok := apirouter.GetParam[[]string](ctx, "RPCAllow"); ok {
+		n.RPCAllow = v
+		updated = true
+	}
+	if v, ok := apirouter.GetParam[[]string](ctx, "RPCDeny"); ok {
+		n.RPCDeny = v
+		updated = true
+	}
 
 	if !updated {
 		return nil
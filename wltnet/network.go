@@ -32,7 +32,7 @@ const ModChainApiKey = "crapi-nx4p6j-ifez-cjli-p5wj-uml43cte"
 
 type Network struct {
 	Id               *xuid.XUID     `gorm:"primaryKey"`
-	Type             string         `gorm:"index:typeChain,unique"` // evm | bitcoin
+	Type             string         `gorm:"index:typeChain,unique"` // evm | bitcoin | solana | aptos | sui
 	ChainId          string         `gorm:"index:typeChain,unique"` // for Type=evm, the chain id from chainlist. For Type=bitcoin, chain key is included here
 	Name             string         // name, automatic if empty
 	RPC              string         // rpc url, automatic if empty
@@ -42,6 +42,8 @@ type Network struct {
 	BlockExplorer    string         // explorer, automatic if empty
 	TestNet          bool           // is this a testnet?
 	Priority         int            // display priority
+	RPCAllow         []string       `gorm:"serializer:json"` // extra JSON-RPC methods dApps are allowed to call, see RPCPolicy
+	RPCDeny          []string       `gorm:"serializer:json"` // JSON-RPC methods dApps are not allowed to call, see RPCPolicy
 	Created          time.Time      `gorm:"autoCreateTime"`
 	Updated          time.Time      `gorm:"autoUpdateTime"`
 }
@@ -164,6 +166,24 @@ func (n *Network) check() error {
 		default:
 			return fmt.Errorf("invalid network type %s/%s", n.Type, n.ChainId)
 		}
+	case "solana", "aptos", "sui":
+		// ed25519 chains, ChainId is mainnet, testnet or devnet
+		switch n.ChainId {
+		case "mainnet":
+		case "testnet", "devnet":
+			n.TestNet = true
+		default:
+			return fmt.Errorf("invalid network type %s/%s", n.Type, n.ChainId)
+		}
+		if n.Name == "" {
+			n.Name = ed25519Chains[n.Type][0]
+			if n.TestNet {
+				n.Name += " " + strings.ToUpper(n.ChainId[:1]) + n.ChainId[1:]
+			}
+		}
+		if n.CurrencySymbol == "" {
+			n.CurrencySymbol = ed25519Chains[n.Type][1]
+		}
 	default:
 		return fmt.Errorf("invalid network type %s", n.Type)
 	}
@@ -235,6 +255,8 @@ func (n *Network) MarshalJSON() ([]byte, error) {
 		"CurrencySymbol": n.CurrencySymbol,
 		"BlockExplorer":  n.BlockExplorer,
 		"TestNet":        n.TestNet,
+		"RPCAllow":       n.RPCAllow,
+		"RPCDeny":        n.RPCDeny,
 		"Created":        n.Created,
 		"Updated":        n.Updated,
 	}
@@ -248,6 +270,21 @@ func (n *Network) MarshalJSON() ([]byte, error) {
 	}
 }
 
+// ed25519Chains lists name and currency symbol of supported chains using ed25519 keys
+var ed25519Chains = map[string][2]string{
+	"solana": {"Solana", "SOL"},
+	"aptos":  {"Aptos", "APT"},
+	"sui":    {"Sui", "SUI"},
+}
+
+// Curve returns the curve used for keys on this network, ed25519 or secp256k1
+func (n *Network) Curve() string {
+	if _, ok := ed25519Chains[n.Type]; ok {
+		return "ed25519"
+	}
+	return "secp256k1"
+}
+
 func (n *Network) String() string {
 	return n.Type + "." + n.ChainId
 }
@@ -288,6 +325,8 @@ func (n *Network) NativeSymbol() (string, error) {
 		default:
 			return "", fmt.Errorf("unsupported bitcoin chain type %s", n.ChainId)
 		}
+	case "solana", "aptos", "sui":
+		return ed25519Chains[n.Type][1], nil
 	default:
 		return "", errors.New("symbol not available (not supported type)")
 	}
@@ -297,6 +336,9 @@ func (n *Network) TransactionUrl(txHash string) string {
 	if e := n.BlockExplorer; e != "" && e != "auto" {
 		return fmt.Sprintf("%s/tx/%s", e, txHash)
 	}
+	if n.Type == "bitcoin" {
+		return fmt.Sprintf("https://blockchair.com/%s/transaction/%s", n.ChainId, txHash)
+	}
 	info, err := n.GetChainInfo()
 	if err != nil {
 		return ""
@@ -306,6 +348,9 @@ func (n *Network) TransactionUrl(txHash string) string {
 
 func (n *Network) getRPC() (ethrpc.Handler, error) {
 	if n.Type == "bitcoin" {
+		if n.RPC != "" && n.RPC != "auto" {
+			return ethrpc.New(n.RPC), nil
+		}
 		if n.validRPC != nil {
 			return n.validRPC, nil
 		}
@@ -393,11 +438,32 @@ func (n *Network) nativeBalance(acct AddressProvider) (*ellipxobj.Amount, error)
 			decimals = info.NativeCurrency.Decimals
 		}
 		return ellipxobj.NewAmountRaw(i, decimals), nil
+	case "bitcoin":
+		return n.utxoBalance(acct.GetAddress())
 	default:
 		return nil, fmt.Errorf("unsupporte type %s", n.Type)
 	}
 }
 
+// NativeDecimals returns the number of decimals of the network's native currency
+func (n *Network) NativeDecimals() (int, error) {
+	switch n.Type {
+	case "evm":
+		if n.CurrencyDecimals != 0 {
+			return n.CurrencyDecimals, nil
+		}
+		info, err := n.GetChainInfo()
+		if err != nil {
+			return 0, err
+		}
+		return info.NativeCurrency.Decimals, nil
+	case "bitcoin":
+		return 8, nil
+	default:
+		return 0, fmt.Errorf("unsupporte type %s", n.Type)
+	}
+}
+
 func (n *Network) NativeAsset(e wltintf.Env, acct AddressProvider) (*wltasset.Asset, error) {
 	switch n.Type {
 	case "evm":
@@ -0,0 +1,113 @@
+package wltnet
+
+import (
+	"strings"
+)
+
+// categories of JSON-RPC methods requested by dApps
+const (
+	RPCRead   = "read"   // read-only methods, relayed to the network's RPC
+	RPCSign   = "sign"   // methods involving the user's keys, must be handled by the wallet and never relayed
+	RPCDenied = "denied" // anything else
+)
+
+// rpcReadMethods are the methods relayed by default
+var rpcReadMethods = map[string]bool{
+	"eth_blockNumber":                         true,
+	"eth_call":                                true,
+	"eth_chainId":                             true,
+	"eth_estimateGas":                         true,
+	"eth_feeHistory":                          true,
+	"eth_gasPrice":                            true,
+	"eth_maxPriorityFeePerGas":                true,
+	"eth_blobBaseFee":                         true,
+	"eth_getBalance":                          true,
+	"eth_getBlockByHash":                      true,
+	"eth_getBlockByNumber":                    true,
+	"eth_getBlockReceipts":                    true,
+	"eth_getBlockTransactionCountByHash":      true,
+	"eth_getBlockTransactionCountByNumber":    true,
+	"eth_getCode":                             true,
+	"eth_getFilterChanges":                    true,
+	"eth_getFilterLogs":                       true,
+	"eth_getLogs":                             true,
+	"eth_getProof":                            true,
+	"eth_getStorageAt":                        true,
+	"eth_getTransactionByBlockHashAndIndex":   true,
+	"eth_getTransactionByBlockNumberAndIndex": true,
+	"eth_getTransactionByHash":                true,
+	"eth_getTransactionCount":                 true,
+	"eth_getTransactionReceipt":               true,
+	"eth_getUncleByBlockHashAndIndex":         true,
+	"eth_getUncleByBlockNumberAndIndex":       true,
+	"eth_getUncleCountByBlockHash":            true,
+	"eth_getUncleCountByBlockNumber":          true,
+	"eth_newBlockFilter":                      true,
+	"eth_newFilter":                           true,
+	"eth_newPendingTransactionFilter":         true,
+	"eth_uninstallFilter":                     true,
+	"eth_protocolVersion":                     true,
+	"eth_syncing":                             true,
+	"eth_sendRawTransaction":                  true, // already signed, relaying is harmless
+	"net_version":                             true,
+	"net_listening":                           true,
+}
+
+// rpcSignMethods are methods that would use keys if relayed to a node
+var rpcSignMethods = map[string]bool{
+	"eth_sign":                 true,
+	"eth_signTransaction":      true,
+	"eth_sendTransaction":      true,
+	"eth_signTypedData":        true,
+	"eth_signTypedData_v1":     true,
+	"eth_signTypedData_v3":     true,
+	"eth_signTypedData_v4":     true,
+	"personal_sign":            true,
+	"personal_ecRecover":       true,
+	"personal_sendTransaction": true,
+	"personal_unlockAccount":   true,
+	"personal_importRawKey":    true,
+	"eth_accounts":             true,
+	"eth_requestAccounts":      true,
+	"eth_coinbase":             true,
+}
+
+// RPCCategory returns the default category of a JSON-RPC method
+func RPCCategory(method string) string {
+	switch {
+	case rpcSignMethods[method], strings.HasPrefix(method, "wallet_"), strings.HasPrefix(method, "personal_"):
+		return RPCSign
+	case rpcReadMethods[method]:
+		return RPCRead
+	}
+	return RPCDenied
+}
+
+// RPCPolicy returns the category of a JSON-RPC method for this network, taking into account the network's
+// RPCAllow and RPCDeny lists. Entries of those lists are method names, or prefixes ending with * (eg. "debug_*").
+// Methods in the sign category can never be allowed.
+func (n *Network) RPCPolicy(method string) string {
+	cat := RPCCategory(method)
+	switch {
+	case cat == RPCSign:
+		return RPCSign
+	case matchRPCMethod(n.RPCDeny, method):
+		return RPCDenied
+	case matchRPCMethod(n.RPCAllow, method):
+		return RPCRead
+	}
+	return cat
+}
+
+func matchRPCMethod(list []string, method string) bool {
+	for _, m := range list {
+		if prefix, ok := strings.CutSuffix(m, "*"); ok {
+			if strings.HasPrefix(method, prefix) {
+				return true
+			}
+		} else if m == method {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package wltnet
+
+import "testing"
+
+func TestRPCPolicy(t *testing.T) {
+	n := &Network{Type: "evm", ChainId: "1"}
+	tests := map[string]string{
+		"eth_call":               RPCRead,
+		"eth_getBalance":         RPCRead,
+		"eth_sign":               RPCSign,
+		"personal_unlockAccount": RPCSign,
+		"wallet_foo":             RPCSign,
+		"debug_traceCall":        RPCDenied,
+		"admin_peers":            RPCDenied,
+		"modchain_stats":         RPCDenied,
+	}
+	for method, expect := range tests {
+		if v := n.RPCPolicy(method); v != expect {
+			t.Errorf("unexpected policy %s for %s, expected %s", v, method, expect)
+		}
+	}
+
+	n.RPCAllow = []string{"debug_*", "eth_sign"}
+	n.RPCDeny = []string{"eth_getLogs"}
+	tests = map[string]string{
+		"debug_traceCall": RPCRead,
+		"eth_sign":        RPCSign, // sign methods can never be relayed
+		"eth_getLogs":     RPCDenied,
+		"admin_peers":     RPCDenied,
+	}
+	for method, expect := range tests {
+		if v := n.RPCPolicy(method); v != expect {
+			t.Errorf("unexpected policy %s for %s with overrides, expected %s", v, method, expect)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package wltnet
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/EllipX/ellipxobj"
+	"github.com/EllipX/libwallet/wltasset"
+	"github.com/EllipX/libwallet/wltutil"
+	"github.com/ModChain/ethrpc"
+)
+
+const (
+	erc20NameSelector      = "06fdde03" // name()
+	erc20SymbolSelector    = "95d89b41" // symbol()
+	erc20DecimalsSelector  = "313ce567" // decimals()
+	erc20BalanceOfSelector = "70a08231" // balanceOf(address)
+
+	erc721OwnerOfSelector    = "6352211e" // ownerOf(uint256)
+	erc1155BalanceOfSelector = "00fdd58e" // balanceOf(address,uint256)
+	erc165SupportsIfSelector = "01ffc9a7" // supportsInterface(bytes4)
+	erc721InterfaceId        = "80ac58cd"
+	erc1155InterfaceId       = "d9b67a26"
+)
+
+// TokenInfo contains the on-chain metadata of a ERC-20 token
+type TokenInfo struct {
+	Contract string `json:"contract"`
+	Name     string `json:"name"`
+	Symbol   string `json:"symbol"`
+	Decimals int    `json:"decimals"`
+}
+
+var (
+	tokenInfoCache   = make(map[string]*TokenInfo)
+	tokenInfoCacheLk sync.Mutex
+)
+
+// TokenInfo returns the name, symbol and decimals of the given ERC-20 token contract. Values are
+// cached in memory since they are not expected to change.
+func (n *Network) TokenInfo(contract string) (*TokenInfo, error) {
+	if n.Type != "evm" {
+		return nil, fmt.Errorf("tokens not supported on network type %s", n.Type)
+	}
+	if !isEvmAddress(contract) {
+		return nil, fmt.Errorf("invalid token contract address %s", contract)
+	}
+	contract = strings.ToLower(contract)
+	cacheKey := n.String() + "." + contract
+
+	tokenInfoCacheLk.Lock()
+	info, ok := tokenInfoCache[cacheKey]
+	tokenInfoCacheLk.Unlock()
+	if ok {
+		return info, nil
+	}
+
+	decBin, err := n.ethCall(contract, erc20DecimalsSelector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch token decimals: %w", err)
+	}
+	if len(decBin) != 32 {
+		return nil, fmt.Errorf("contract %s does not look like a ERC-20 token", contract)
+	}
+	dec := new(big.Int).SetBytes(decBin)
+	if !dec.IsUint64() || dec.Uint64() > 77 {
+		return nil, fmt.Errorf("invalid token decimals %s", dec)
+	}
+
+	info = &TokenInfo{
+		Contract: contract,
+		Decimals: int(dec.Uint64()),
+	}
+
+	// symbol is required, name is optional
+	if buf, err := n.ethCall(contract, erc20SymbolSelector); err != nil {
+		return nil, fmt.Errorf("failed to fetch token symbol: %w", err)
+	} else if info.Symbol, err = decodeTokenString(buf); err != nil {
+		return nil, fmt.Errorf("failed to decode token symbol: %w", err)
+	}
+	if buf, err := n.ethCall(contract, erc20NameSelector); err == nil {
+		info.Name, _ = decodeTokenString(buf)
+	}
+	if info.Name == "" {
+		info.Name = info.Symbol
+	}
+
+	tokenInfoCacheLk.Lock()
+	tokenInfoCache[cacheKey] = info
+	tokenInfoCacheLk.Unlock()
+
+	return info, nil
+}
+
+// TokenBalance returns the balance of the given ERC-20 token held by acct
+func (n *Network) TokenBalance(contract string, acct AddressProvider) (*ellipxobj.Amount, error) {
+	info, err := n.TokenInfo(contract)
+	if err != nil {
+		return nil, err
+	}
+	addr := acct.GetAddress()
+	if !isEvmAddress(addr) {
+		return nil, fmt.Errorf("invalid account address %s", addr)
+	}
+
+	buf, err := n.ethCall(info.Contract, erc20BalanceOfSelector+fmt.Sprintf("%064s", strings.ToLower(addr[2:])))
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) < 32 {
+		return nil, errors.New("invalid balanceOf response")
+	}
+	return ellipxobj.NewAmountRaw(new(big.Int).SetBytes(buf[:32]), info.Decimals), nil
+}
+
+// SupportsStandard checks using ERC-165 that contract implements the ERC721 or ERC1155 standard
+func (n *Network) SupportsStandard(contract, standard string) (bool, error) {
+	var iface string
+	switch standard {
+	case "ERC721":
+		iface = erc721InterfaceId
+	case "ERC1155":
+		iface = erc1155InterfaceId
+	default:
+		return false, fmt.Errorf("unsupported standard %s", standard)
+	}
+	if !isEvmAddress(contract) {
+		return false, fmt.Errorf("invalid contract address %s", contract)
+	}
+	buf, err := n.ethCall(contract, erc165SupportsIfSelector+iface+strings.Repeat("0", 56))
+	if err != nil {
+		return false, err
+	}
+	return len(buf) == 32 && buf[31] == 1, nil
+}
+
+// AssetBalance returns the balance held by acct of a token asset added with wallet_watchAsset
+func (n *Network) AssetBalance(a *wltasset.Asset, acct AddressProvider) (*ellipxobj.Amount, error) {
+	addr := acct.GetAddress()
+	if !isEvmAddress(addr) {
+		return nil, fmt.Errorf("invalid account address %s", addr)
+	}
+	addrArg := fmt.Sprintf("%064s", strings.ToLower(addr[2:]))
+
+	switch a.Standard {
+	case "ERC20":
+		return n.TokenBalance(a.Contract, acct)
+	case "ERC721":
+		id, err := tokenIdArg(a.TokenId)
+		if err != nil {
+			return nil, err
+		}
+		buf, err := n.ethCall(a.Contract, erc721OwnerOfSelector+id)
+		if err != nil {
+			return nil, err
+		}
+		if len(buf) == 32 && hex.EncodeToString(buf) == addrArg {
+			return ellipxobj.NewAmount(1, 0), nil
+		}
+		return ellipxobj.NewAmount(0, 0), nil
+	case "ERC1155":
+		id, err := tokenIdArg(a.TokenId)
+		if err != nil {
+			return nil, err
+		}
+		buf, err := n.ethCall(a.Contract, erc1155BalanceOfSelector+addrArg+id)
+		if err != nil {
+			return nil, err
+		}
+		if len(buf) < 32 {
+			return nil, errors.New("invalid balanceOf response")
+		}
+		return ellipxobj.NewAmountRaw(new(big.Int).SetBytes(buf[:32]), 0), nil
+	default:
+		return nil, fmt.Errorf("unsupported asset standard %s", a.Standard)
+	}
+}
+
+// tokenIdArg encodes a base 10 token id as a uint256 call argument
+func tokenIdArg(tokenId string) (string, error) {
+	v, ok := new(big.Int).SetString(tokenId, 10)
+	if !ok || v.Sign() < 0 || v.BitLen() > 256 {
+		return "", fmt.Errorf("invalid token id %s", tokenId)
+	}
+	return fmt.Sprintf("%064x", v), nil
+}
+
+// ethCall runs eth_call on the latest block with the given hex-encoded calldata (without 0x) and returns the decoded result
+func (n *Network) ethCall(to, data string) ([]byte, error) {
+	param := map[string]string{
+		"to":   to,
+		"data": "0x" + data,
+	}
+	res, err := ethrpc.ReadString(n.DoRPC("eth_call", param, "latest"))
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(strings.TrimPrefix(res, "0x"))
+}
+
+// decodeTokenString decodes a string returned by name() or symbol(). Some older tokens (MKR, SAI, etc)
+// return a bytes32 instead of a string, so handle both.
+func decodeTokenString(buf []byte) (string, error) {
+	if len(buf) == 32 {
+		return strings.TrimRight(string(buf), "\x00"), nil
+	}
+	return wltutil.DecodeEVMEthCallString(buf)
+}
+
+func isEvmAddress(addr string) bool {
+	v, ok := strings.CutPrefix(addr, "0x")
+	if !ok || len(v) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(v)
+	return err == nil
+}
@@ -0,0 +1,122 @@
+package wltnet
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/EllipX/libwallet/wltasset"
+)
+
+type testRPCRequest struct {
+	Id     any               `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+// newTestNetwork returns a network using a local RPC server answering with the given handlers
+func newTestNetwork(t *testing.T, handlers map[string]func(params []json.RawMessage) any) *Network {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req *testRPCRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		res := map[string]any{"jsonrpc": "2.0", "id": req.Id}
+		if h, ok := handlers[req.Method]; ok {
+			res["result"] = h(req.Params)
+		} else {
+			res["error"] = map[string]any{"code": -32601, "message": "method not found: " + req.Method}
+		}
+		json.NewEncoder(w).Encode(res)
+	}))
+	t.Cleanup(srv.Close)
+
+	return &Network{Type: "evm", ChainId: "1", RPC: srv.URL}
+}
+
+func TestTokenInfo(t *testing.T) {
+	n := newTestNetwork(t, map[string]func([]json.RawMessage) any{
+		"eth_call": func(params []json.RawMessage) any {
+			var call map[string]string
+			json.Unmarshal(params[0], &call)
+			switch call["data"] {
+			case "0x313ce567": // decimals
+				return "0x0000000000000000000000000000000000000000000000000000000000000006"
+			case "0x95d89b41": // symbol
+				return "0x" +
+					"0000000000000000000000000000000000000000000000000000000000000020" +
+					"0000000000000000000000000000000000000000000000000000000000000004" +
+					"5553445400000000000000000000000000000000000000000000000000000000"
+			default:
+				return "0x"
+			}
+		},
+	})
+
+	info, err := n.TokenInfo("0xdac17f958d2ee523a2206206994597c13d831ec7")
+	if err != nil {
+		t.Fatalf("TokenInfo failed: %s", err)
+	}
+	if info.Symbol != "USDT" || info.Decimals != 6 {
+		t.Errorf("unexpected token info %+v", info)
+	}
+}
+
+type testAddress string
+
+func (a testAddress) GetAddress() string { return string(a) }
+
+func TestWatchedAssetBalance(t *testing.T) {
+	const owner = "0x2222222222222222222222222222222222222222"
+	word := func(v string) string { return "0x" + fmt.Sprintf("%064s", v) }
+	n := newTestNetwork(t, map[string]func([]json.RawMessage) any{
+		"eth_call": func(params []json.RawMessage) any {
+			var call map[string]string
+			json.Unmarshal(params[0], &call)
+			switch {
+			case call["data"] == "0x01ffc9a780ac58cd00000000000000000000000000000000000000000000000000000000": // supportsInterface(ERC721)
+				return word("1")
+			case strings.HasPrefix(call["data"], "0x01ffc9a7"):
+				return word("0")
+			case call["data"] == "0x6352211e"+fmt.Sprintf("%064x", 42): // ownerOf(42)
+				return word(owner[2:])
+			case strings.HasPrefix(call["data"], "0x6352211e"):
+				return word("1111111111111111111111111111111111111111")
+			case call["data"] == "0x00fdd58e"+fmt.Sprintf("%064s%064x", owner[2:], 7): // balanceOf(owner, 7)
+				return word("3")
+			default:
+				return "0x"
+			}
+		},
+	})
+
+	if ok, err := n.SupportsStandard("0x3333333333333333333333333333333333333333", "ERC721"); err != nil || !ok {
+		t.Errorf("contract should support ERC721 (err=%v)", err)
+	}
+	if ok, err := n.SupportsStandard("0x3333333333333333333333333333333333333333", "ERC1155"); err != nil || ok {
+		t.Errorf("contract should not support ERC1155 (err=%v)", err)
+	}
+
+	tests := []struct {
+		asset  *wltasset.Asset
+		expect string
+	}{
+		{&wltasset.Asset{Standard: "ERC721", Contract: "0x3333333333333333333333333333333333333333", TokenId: "42"}, "1"},
+		{&wltasset.Asset{Standard: "ERC721", Contract: "0x3333333333333333333333333333333333333333", TokenId: "43"}, "0"},
+		{&wltasset.Asset{Standard: "ERC1155", Contract: "0x3333333333333333333333333333333333333333", TokenId: "7"}, "3"},
+	}
+	for _, test := range tests {
+		amt, err := n.AssetBalance(test.asset, testAddress(owner))
+		if err != nil {
+			t.Errorf("AssetBalance(%s %s) failed: %s", test.asset.Standard, test.asset.TokenId, err)
+			continue
+		}
+		if amt.String() != test.expect {
+			t.Errorf("AssetBalance(%s %s) = %s, expected %s", test.asset.Standard, test.asset.TokenId, amt, test.expect)
+		}
+	}
+}
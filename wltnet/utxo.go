@@ -0,0 +1,139 @@
+package wltnet
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/EllipX/ellipxobj"
+	"github.com/ModChain/ethrpc"
+	"github.com/ModChain/outscript"
+)
+
+// Utxo is an unspent transaction output on a bitcoin-based network
+type Utxo struct {
+	TxId   string `json:"tx_hash"` // transaction id, as displayed in explorers
+	Vout   uint32 `json:"tx_pos"`
+	Height int64  `json:"height"` // block height, zero or negative if unconfirmed
+	Value  uint64 `json:"value"`  // amount in satoshis
+}
+
+type utxoBalance struct {
+	Confirmed   int64 `json:"confirmed"`
+	Unconfirmed int64 `json:"unconfirmed"`
+}
+
+// BtcFormat returns the output scheme used for accounts on this bitcoin-based network as well as the
+// network name to use when generating addresses
+func (n *Network) BtcFormat() (scheme, addrNet string, err error) {
+	if n.Type != "bitcoin" {
+		return "", "", fmt.Errorf("not a bitcoin-based network: %s", n)
+	}
+	switch n.ChainId {
+	case "bitcoin", "litecoin":
+		return "p2wpkh", n.ChainId, nil
+	case "bitcoin-cash":
+		return "p2pkh", "bitcoincash", nil
+	case "dogecoin":
+		return "p2pkh", "dogecoin", nil
+	default:
+		return "", "", fmt.Errorf("unsupported bitcoin chain type %s", n.ChainId)
+	}
+}
+
+// BtcSigHash returns the sighash type to use when signing transactions on this network. Bitcoin
+// Cash requires SIGHASH_FORKID (0x40) on all signatures.
+func (n *Network) BtcSigHash() uint32 {
+	if n.ChainId == "bitcoin-cash" {
+		return 0x41 // SIGHASH_ALL|SIGHASH_FORKID
+	}
+	return 0x01 // SIGHASH_ALL
+}
+
+// BtcDustLimit returns the minimum value an output can have to be relayed on this network
+func (n *Network) BtcDustLimit() uint64 {
+	if n.ChainId == "dogecoin" {
+		return 1_000_000 // 0.01 DOGE
+	}
+	return 546
+}
+
+// BtcScript returns the output script for the given address on this network
+func (n *Network) BtcScript(address string) ([]byte, error) {
+	out, err := outscript.ParseBitcoinBasedAddress(n.ChainId, address)
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// scriptHash returns the electrum-style hash of an output script (sha256, reversed, in hex)
+func scriptHash(script []byte) string {
+	h := sha256.Sum256(script)
+	slices.Reverse(h[:])
+	return hex.EncodeToString(h[:])
+}
+
+// ListUnspent returns the unspent outputs for the given output script, including unconfirmed outputs
+func (n *Network) ListUnspent(script []byte) ([]*Utxo, error) {
+	if n.Type != "bitcoin" {
+		return nil, fmt.Errorf("unsupported type %s", n.Type)
+	}
+	var res []*Utxo
+	err := ethrpc.ReadTo(&res)(n.DoRPC("blockchain.scripthash.listunspent", scriptHash(script)))
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// BtcFeeRate returns the fee rate in satoshis per virtual byte needed for a transaction to be
+// confirmed within a few blocks
+func (n *Network) BtcFeeRate() (uint64, error) {
+	var perKb float64
+	err := ethrpc.ReadTo(&perKb)(n.DoRPC("blockchain.estimatefee", 6))
+	if err != nil {
+		return 0, err
+	}
+	minRate := uint64(1)
+	if n.ChainId == "dogecoin" {
+		minRate = 1000 // 0.01 DOGE/kB
+	}
+	if perKb <= 0 {
+		// -1 means the server does not have enough data
+		return minRate, nil
+	}
+	// value is in coins per kB
+	rate := uint64(perKb*1e8/1000 + 0.5)
+	if rate < minRate {
+		rate = minRate
+	}
+	return rate, nil
+}
+
+// BroadcastBtcTx sends a signed transaction to the network and returns its txid
+func (n *Network) BroadcastBtcTx(raw []byte) (string, error) {
+	if n.Type != "bitcoin" {
+		return "", fmt.Errorf("unsupported type %s", n.Type)
+	}
+	return ethrpc.ReadString(n.DoRPC("blockchain.transaction.broadcast", hex.EncodeToString(raw)))
+}
+
+// utxoBalance returns the balance of the given address, including unconfirmed amounts
+func (n *Network) utxoBalance(address string) (*ellipxobj.Amount, error) {
+	script, err := n.BtcScript(address)
+	if err != nil {
+		return nil, fmt.Errorf("account address is not valid on %s: %w", n.ChainId, err)
+	}
+	var bal *utxoBalance
+	err = ethrpc.ReadTo(&bal)(n.DoRPC("blockchain.scripthash.get_balance", scriptHash(script)))
+	if err != nil {
+		return nil, err
+	}
+	if bal == nil {
+		return nil, errors.New("invalid balance obtained from rpc")
+	}
+	return ellipxobj.NewAmount(bal.Confirmed+bal.Unconfirmed, 8), nil
+}
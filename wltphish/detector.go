@@ -0,0 +1,140 @@
+// Package wltphish checks origins against phishing lists in the MetaMask eth-phishing-detect format
+package wltphish
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// DefaultListURL is the list maintained by MetaMask
+const DefaultListURL = "https://raw.githubusercontent.com/MetaMask/eth-phishing-detect/main/src/config.json"
+
+// Config is a phishing list. Both the original names (whitelist, blacklist) and the newer ones (allowlist,
+// blocklist) are accepted.
+type Config struct {
+	Version   int      `json:"version"`
+	Tolerance int      `json:"tolerance"` // maximum edit distance for a domain to be considered a lookalike of a fuzzylist entry
+	Fuzzylist []string `json:"fuzzylist"`
+	Whitelist []string `json:"whitelist"`
+	Blacklist []string `json:"blacklist"`
+	Allowlist []string `json:"allowlist"`
+	Blocklist []string `json:"blocklist"`
+}
+
+// Result is the outcome of a check
+type Result struct {
+	Type     string `json:"type"`            // allowlist, blocklist, fuzzy or all (no match)
+	Phishing bool   `json:"phishing"`        // true if the domain is considered malicious
+	Match    string `json:"match,omitempty"` // entry that matched, for fuzzy the domain being imitated
+}
+
+// Detector checks domains against a list
+type Detector struct {
+	tolerance int
+	fuzzy     []string
+	allow     map[string]bool
+	block     map[string]bool
+}
+
+// Parse parses a list in the eth-phishing-detect format and returns a detector
+func Parse(buf []byte) (*Detector, error) {
+	var cfg *Config
+	if err := json.Unmarshal(buf, &cfg); err != nil {
+		return nil, err
+	}
+	return New(cfg), nil
+}
+
+// New returns a detector for the given list
+func New(cfg *Config) *Detector {
+	d := &Detector{
+		tolerance: cfg.Tolerance,
+		allow:     make(map[string]bool),
+		block:     make(map[string]bool),
+	}
+	for _, l := range [][]string{cfg.Whitelist, cfg.Allowlist} {
+		for _, v := range l {
+			d.allow[normalize(v)] = true
+		}
+	}
+	for _, l := range [][]string{cfg.Blacklist, cfg.Blocklist} {
+		for _, v := range l {
+			d.block[normalize(v)] = true
+		}
+	}
+	for _, v := range cfg.Fuzzylist {
+		d.fuzzy = append(d.fuzzy, normalize(v))
+	}
+	return d
+}
+
+func normalize(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
+
+// Check returns the result for domain (a host name, without scheme or port). Allowed domains take
+// precedence over blocked ones, and lookalikes are only searched if the domain is in neither list.
+func (d *Detector) Check(domain string) *Result {
+	domain = normalize(domain)
+
+	if m, ok := matchParent(d.allow, domain); ok {
+		return &Result{Type: "allowlist", Match: m}
+	}
+	if m, ok := matchParent(d.block, domain); ok {
+		return &Result{Type: "blocklist", Phishing: true, Match: m}
+	}
+	if d.tolerance > 0 {
+		// compare the registered domain (last two labels) with fuzzylist entries
+		parts := strings.Split(domain, ".")
+		if len(parts) > 2 {
+			parts = parts[len(parts)-2:]
+		}
+		root := strings.Join(parts, ".")
+		for _, f := range d.fuzzy {
+			if root == f {
+				// this is the domain itself, for example a subdomain not explicitly allowed
+				continue
+			}
+			if levenshtein(root, f) <= d.tolerance {
+				return &Result{Type: "fuzzy", Phishing: true, Match: f}
+			}
+		}
+	}
+	return &Result{Type: "all"}
+}
+
+// matchParent returns the entry of list matching domain or one of its parent domains
+func matchParent(list map[string]bool, domain string) (string, bool) {
+	for {
+		if list[domain] {
+			return domain, true
+		}
+		_, parent, ok := strings.Cut(domain, ".")
+		if !ok {
+			return "", false
+		}
+		domain = parent
+	}
+}
+
+// levenshtein returns the edit distance between a and b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
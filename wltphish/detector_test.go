@@ -0,0 +1,71 @@
+package wltphish
+
+import "testing"
+
+const testList = `{
+	"version": 2,
+	"tolerance": 2,
+	"fuzzylist": ["metamask.io", "myetherwallet.com"],
+	"whitelist": ["metamask.io", "myetherwallet.com", "metmask.com"],
+	"blacklist": ["evil.example.com", "scam.org"]
+}`
+
+func TestCheck(t *testing.T) {
+	d, err := Parse([]byte(testList))
+	if err != nil {
+		t.Fatalf("failed to parse list: %s", err)
+	}
+
+	tests := []struct {
+		domain   string
+		typ      string
+		phishing bool
+		match    string
+	}{
+		{"metamask.io", "allowlist", false, "metamask.io"},
+		{"docs.MetaMask.io", "allowlist", false, "metamask.io"},
+		{"metmask.com", "allowlist", false, "metmask.com"},
+		{"scam.org", "blocklist", true, "scam.org"},
+		{"app.scam.org", "blocklist", true, "scam.org"},
+		{"evil.example.com", "blocklist", true, "evil.example.com"},
+		{"example.com", "all", false, ""},
+		{"metamask.co", "fuzzy", true, "metamask.io"},
+		{"login.metarnask.io", "fuzzy", true, "metamask.io"},
+		{"myetherwalet.com", "fuzzy", true, "myetherwallet.com"},
+		{"uniswap.org", "all", false, ""},
+	}
+	for _, test := range tests {
+		r := d.Check(test.domain)
+		if r.Type != test.typ || r.Phishing != test.phishing || r.Match != test.match {
+			t.Errorf("%s: expected %s/%v/%s, got %+v", test.domain, test.typ, test.phishing, test.match, r)
+		}
+	}
+
+	// newer list format
+	d, err = Parse([]byte(`{"tolerance":0,"allowlist":["good.com"],"blocklist":["bad.com"],"fuzzylist":["good.com"]}`))
+	if err != nil {
+		t.Fatalf("failed to parse list: %s", err)
+	}
+	if r := d.Check("bad.com"); !r.Phishing {
+		t.Errorf("expected bad.com to be blocked")
+	}
+	if r := d.Check("gooc.com"); r.Phishing {
+		t.Errorf("fuzzy matching should be disabled with a tolerance of 0")
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	for _, test := range []struct {
+		a, b string
+		d    int
+	}{
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+		{"metamask.io", "metamask.io", 0},
+		{"metamask.io", "rnetamask.io", 2},
+	} {
+		if d := levenshtein(test.a, test.b); d != test.d {
+			t.Errorf("levenshtein(%q, %q) = %d, expected %d", test.a, test.b, d, test.d)
+		}
+	}
+}
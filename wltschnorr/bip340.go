@@ -0,0 +1,105 @@
+// Package wltschnorr implements the BIP340 Schnorr signature scheme and BIP341 Taproot key tweaking used by
+// wallets to spend Taproot outputs
+package wltschnorr
+
+import (
+	"crypto/sha256"
+	"errors"
+
+	"github.com/ModChain/secp256k1"
+)
+
+// TaggedHash returns the BIP340 tagged hash SHA256(SHA256(tag) || SHA256(tag) || msg...)
+func TaggedHash(tag string, msg ...[]byte) []byte {
+	th := sha256.Sum256([]byte(tag))
+	h := sha256.New()
+	h.Write(th[:])
+	h.Write(th[:])
+	for _, m := range msg {
+		h.Write(m)
+	}
+	return h.Sum(nil)
+}
+
+// XOnly returns the 32 bytes x coordinate of pub
+func XOnly(pub *secp256k1.PublicKey) []byte {
+	return pub.SerializeCompressed()[1:]
+}
+
+// HasEvenY returns true if the y coordinate of pub is even, which is the key BIP340 uses for a given x-only key
+func HasEvenY(pub *secp256k1.PublicKey) bool {
+	return pub.SerializeCompressed()[0] == secp256k1.PubKeyFormatCompressedEven
+}
+
+// ParseXOnly parses a 32 bytes x-only public key, returning the point with an even y
+func ParseXOnly(x []byte) (*secp256k1.PublicKey, error) {
+	if len(x) != 32 {
+		return nil, errors.New("x-only public key must be 32 bytes")
+	}
+	return secp256k1.ParsePubKey(append([]byte{secp256k1.PubKeyFormatCompressedEven}, x...))
+}
+
+// TapTweak returns the BIP341 tweak of the internal key pub for a key path only output (no script tree, as in
+// BIP86), and the resulting output key. The output key is the internal key with an even y plus tweak*G.
+func TapTweak(pub *secp256k1.PublicKey) (*secp256k1.ModNScalar, *secp256k1.PublicKey, error) {
+	var t secp256k1.ModNScalar
+	if overflow := t.SetByteSlice(TaggedHash("TapTweak", XOnly(pub))); overflow {
+		return nil, nil, errors.New("taproot tweak is out of range")
+	}
+
+	var p, tG, q secp256k1.JacobianPoint
+	pub.AsJacobian(&p)
+	if !HasEvenY(pub) {
+		p.Y.Negate(1).Normalize()
+	}
+	secp256k1.ScalarBaseMultNonConst(&t, &tG)
+	secp256k1.AddNonConst(&p, &tG, &q)
+	if (q.X.IsZero() && q.Y.IsZero()) || q.Z.IsZero() {
+		return nil, nil, errors.New("taproot output key is infinity")
+	}
+	q.ToAffine()
+	return &t, secp256k1.NewPublicKey(&q.X, &q.Y), nil
+}
+
+// Challenge returns the BIP340 challenge e = H(R.x || P.x || msg) mod n
+func Challenge(rx, px, msg []byte) *secp256k1.ModNScalar {
+	var e secp256k1.ModNScalar
+	e.SetByteSlice(TaggedHash("BIP0340/challenge", rx, px, msg))
+	return &e
+}
+
+// Verify checks a 64 bytes BIP340 signature of msg for the x-only public key px
+func Verify(sig, msg, px []byte) error {
+	if len(sig) != 64 {
+		return errors.New("signature must be 64 bytes")
+	}
+	pub, err := ParseXOnly(px)
+	if err != nil {
+		return err
+	}
+	var rx secp256k1.FieldVal
+	if overflow := rx.SetByteSlice(sig[:32]); overflow {
+		return errors.New("signature r is out of range")
+	}
+	var s secp256k1.ModNScalar
+	if overflow := s.SetByteSlice(sig[32:]); overflow {
+		return errors.New("signature s is out of range")
+	}
+	e := Challenge(sig[:32], px, msg)
+
+	// R = s*G - e*P
+	var p, sG, eP, r secp256k1.JacobianPoint
+	pub.AsJacobian(&p)
+	secp256k1.ScalarBaseMultNonConst(&s, &sG)
+	e.Negate()
+	secp256k1.ScalarMultNonConst(e, &p, &eP)
+	secp256k1.AddNonConst(&sG, &eP, &r)
+	if (r.X.IsZero() && r.Y.IsZero()) || r.Z.IsZero() {
+		return errors.New("invalid signature")
+	}
+	r.ToAffine()
+	if r.Y.IsOdd() || !r.X.Equals(&rx) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package wltschnorr
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(strings.ToLower(s))
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestVerify(t *testing.T) {
+	// test vectors from BIP340
+	tests := []struct {
+		pub, msg, sig string
+		valid         bool
+	}{
+		{
+			"F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9",
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"E907831F80848D1069A5371B402410364BDF1C5F8307B0084C55F1CE2DCA821525F66A4A85EA8B71E482A74F382D2CE5EBEEE8FDB2172F477DF4900D310536C0",
+			true,
+		},
+		{
+			"DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+			"243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+			"6896BD60EEAE296DB48A229FF71DFE071BDE413E6D43F917DC8DCF8C78DE33418906D11AC976ABCCB20B091292BFF4EA897EFCB639EA871CFA95F6DE339E4B0A",
+			true,
+		},
+		{
+			// same signature for another message
+			"DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+			"243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C8A",
+			"6896BD60EEAE296DB48A229FF71DFE071BDE413E6D43F917DC8DCF8C78DE33418906D11AC976ABCCB20B091292BFF4EA897EFCB639EA871CFA95F6DE339E4B0A",
+			false,
+		},
+	}
+	for n, test := range tests {
+		err := Verify(mustHex(test.sig), mustHex(test.msg), mustHex(test.pub))
+		if (err == nil) != test.valid {
+			t.Errorf("vector %d: expected valid=%v, got %v", n, test.valid, err)
+		}
+	}
+}
+
+func TestTapTweak(t *testing.T) {
+	// first receiving address of BIP86 test vectors
+	internal, err := ParseXOnly(mustHex("cc8a4bc64d897bddc5fbc2f670f7a8ba0b386779106cf1223c6fc5d7cd6fc115"))
+	if err != nil {
+		t.Fatalf("failed to parse key: %s", err)
+	}
+	_, out, err := TapTweak(internal)
+	if err != nil {
+		t.Fatalf("failed to tweak: %s", err)
+	}
+	if x := hex.EncodeToString(XOnly(out)); x != "a60869f0dbcf1dc659c9cecbaf8050135ea9e8cdc487053f1dc6880949dc684c" {
+		t.Errorf("unexpected output key %s", x)
+	}
+}
@@ -4,12 +4,14 @@ import (
 	"context"
 	"crypto"
 	"math/big"
+	"time"
 )
 
 type Opts struct {
 	Context context.Context
 	IL      *big.Int
 	Keys    []*KeyDescription
+	Timeout time.Duration // maximum duration of the signature, if zero the deadline of Context or a default depending on the keys applies
 }
 
 func (a *Opts) HashFunc() crypto.Hash {
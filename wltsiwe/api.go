@@ -0,0 +1,31 @@
+package wltsiwe
+
+import (
+	"context"
+	"errors"
+
+	"github.com/KarpelesLab/pobj"
+)
+
+func init() {
+	pobj.RegisterStatic("SIWE:verify", apiVerify)
+}
+
+func apiVerify(ctx context.Context, in struct {
+	Message   string
+	Signature string
+	Domain    string // if set, the message's domain must match
+	Nonce     string // if set, the message's nonce must match
+}) (any, error) {
+	m, err := Verify(in.Message, in.Signature)
+	if err != nil {
+		return nil, err
+	}
+	if in.Domain != "" && in.Domain != m.Domain {
+		return nil, errors.New("siwe: domain does not match")
+	}
+	if in.Nonce != "" && in.Nonce != m.Nonce {
+		return nil, errors.New("siwe: nonce does not match")
+	}
+	return m, nil
+}
@@ -0,0 +1,220 @@
+// Package wltsiwe implements Sign-In with Ethereum (EIP-4361) messages
+package wltsiwe
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ModChain/outscript"
+)
+
+const header = " wants you to sign in with your Ethereum account:"
+
+// Message is a parsed EIP-4361 message
+type Message struct {
+	Scheme         string     `json:"scheme,omitempty"` // optional scheme of the domain
+	Domain         string     `json:"domain"`           // authority (host[:port]) requesting the signature
+	Address        string     `json:"address"`          // EIP-55 address performing the signing
+	Statement      string     `json:"statement,omitempty"`
+	URI            string     `json:"uri"`
+	Version        string     `json:"version"`
+	ChainId        uint64     `json:"chainId"`
+	Nonce          string     `json:"nonce"`
+	IssuedAt       time.Time  `json:"issuedAt"`
+	ExpirationTime *time.Time `json:"expirationTime,omitempty"`
+	NotBefore      *time.Time `json:"notBefore,omitempty"`
+	RequestId      string     `json:"requestId,omitempty"`
+	Resources      []string   `json:"resources,omitempty"`
+}
+
+// ErrNotSIWE is returned by Parse when the message is not a SIWE message
+var ErrNotSIWE = errors.New("siwe: not a sign-in with ethereum message")
+
+// IsSIWE returns true if msg looks like a SIWE message, even if it might not be valid
+func IsSIWE(msg string) bool {
+	first, _, _ := strings.Cut(msg, "\n")
+	return strings.HasSuffix(first, header)
+}
+
+// Parse parses a SIWE message. It returns ErrNotSIWE if msg does not start like a SIWE message, or
+// another error if it does but is not valid.
+func Parse(msg string) (*Message, error) {
+	if !IsSIWE(msg) {
+		return nil, ErrNotSIWE
+	}
+	lines := strings.Split(strings.TrimSuffix(msg, "\n"), "\n")
+	res := &Message{}
+
+	domain := strings.TrimSuffix(lines[0], header)
+	if scheme, rest, ok := strings.Cut(domain, "://"); ok {
+		res.Scheme = scheme
+		domain = rest
+	}
+	if domain == "" || strings.ContainsAny(domain, " /") {
+		return nil, fmt.Errorf("siwe: invalid domain %q", domain)
+	}
+	res.Domain = domain
+
+	if len(lines) < 2 {
+		return nil, errors.New("siwe: address is missing")
+	}
+	addr, err := outscript.ParseEvmAddress(lines[1])
+	if err != nil {
+		return nil, fmt.Errorf("siwe: invalid address: %w", err)
+	}
+	res.Address, err = addr.Address()
+	if err != nil {
+		return nil, fmt.Errorf("siwe: invalid address: %w", err)
+	}
+	if lines[1] != res.Address && strings.ToLower(lines[1]) != lines[1] {
+		// mixed case addresses must have a valid EIP-55 checksum
+		return nil, fmt.Errorf("siwe: invalid address checksum %s", lines[1])
+	}
+
+	// blank line, optional statement, blank line
+	i := 2
+	for i < len(lines) && lines[i] == "" {
+		i++
+	}
+	if i < len(lines) && !strings.HasPrefix(lines[i], "URI: ") {
+		res.Statement = lines[i]
+		i++
+		for i < len(lines) && lines[i] == "" {
+			i++
+		}
+	}
+
+	seen := make(map[string]bool)
+	for ; i < len(lines); i++ {
+		if lines[i] == "Resources:" {
+			for i++; i < len(lines); i++ {
+				r, ok := strings.CutPrefix(lines[i], "- ")
+				if !ok {
+					return nil, fmt.Errorf("siwe: invalid resource line %q", lines[i])
+				}
+				res.Resources = append(res.Resources, r)
+			}
+			break
+		}
+		k, v, ok := strings.Cut(lines[i], ": ")
+		if !ok {
+			return nil, fmt.Errorf("siwe: invalid line %q", lines[i])
+		}
+		if seen[k] {
+			return nil, fmt.Errorf("siwe: duplicate field %s", k)
+		}
+		seen[k] = true
+		switch k {
+		case "URI":
+			if _, err := url.Parse(v); err != nil {
+				return nil, fmt.Errorf("siwe: invalid URI: %w", err)
+			}
+			res.URI = v
+		case "Version":
+			res.Version = v
+		case "Chain ID":
+			res.ChainId, err = strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("siwe: invalid chain id %s", v)
+			}
+		case "Nonce":
+			res.Nonce = v
+		case "Issued At":
+			res.IssuedAt, err = time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, fmt.Errorf("siwe: invalid issued at: %w", err)
+			}
+		case "Expiration Time":
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, fmt.Errorf("siwe: invalid expiration time: %w", err)
+			}
+			res.ExpirationTime = &t
+		case "Not Before":
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, fmt.Errorf("siwe: invalid not before: %w", err)
+			}
+			res.NotBefore = &t
+		case "Request ID":
+			res.RequestId = v
+		default:
+			return nil, fmt.Errorf("siwe: unknown field %s", k)
+		}
+	}
+
+	switch {
+	case res.URI == "":
+		return nil, errors.New("siwe: URI is missing")
+	case res.Version != "1":
+		return nil, fmt.Errorf("siwe: unsupported version %q", res.Version)
+	case res.ChainId == 0:
+		return nil, errors.New("siwe: chain id is missing")
+	case len(res.Nonce) < 8:
+		return nil, errors.New("siwe: nonce must be at least 8 characters")
+	case res.IssuedAt.IsZero():
+		return nil, errors.New("siwe: issued at is missing")
+	}
+	return res, nil
+}
+
+// String returns the message in its EIP-4361 text form
+func (m *Message) String() string {
+	var b strings.Builder
+	if m.Scheme != "" {
+		b.WriteString(m.Scheme + "://")
+	}
+	b.WriteString(m.Domain + header + "\n")
+	b.WriteString(m.Address + "\n\n")
+	if m.Statement != "" {
+		b.WriteString(m.Statement + "\n")
+	}
+	b.WriteString("\nURI: " + m.URI)
+	b.WriteString("\nVersion: " + m.Version)
+	b.WriteString("\nChain ID: " + strconv.FormatUint(m.ChainId, 10))
+	b.WriteString("\nNonce: " + m.Nonce)
+	b.WriteString("\nIssued At: " + m.IssuedAt.Format(time.RFC3339))
+	if m.ExpirationTime != nil {
+		b.WriteString("\nExpiration Time: " + m.ExpirationTime.Format(time.RFC3339))
+	}
+	if m.NotBefore != nil {
+		b.WriteString("\nNot Before: " + m.NotBefore.Format(time.RFC3339))
+	}
+	if m.RequestId != "" {
+		b.WriteString("\nRequest ID: " + m.RequestId)
+	}
+	if len(m.Resources) > 0 {
+		b.WriteString("\nResources:")
+		for _, r := range m.Resources {
+			b.WriteString("\n- " + r)
+		}
+	}
+	return b.String()
+}
+
+// MatchesOrigin returns true if the message's domain (and scheme, if specified) matches origin, given as scheme://host
+func (m *Message) MatchesOrigin(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if m.Scheme != "" && !strings.EqualFold(m.Scheme, u.Scheme) {
+		return false
+	}
+	return strings.EqualFold(m.Domain, u.Host)
+}
+
+// CheckTime returns an error if the message is expired or not yet valid at t
+func (m *Message) CheckTime(t time.Time) error {
+	if m.ExpirationTime != nil && !t.Before(*m.ExpirationTime) {
+		return errors.New("siwe: message has expired")
+	}
+	if m.NotBefore != nil && t.Before(*m.NotBefore) {
+		return errors.New("siwe: message is not valid yet")
+	}
+	return nil
+}
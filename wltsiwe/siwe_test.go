@@ -0,0 +1,103 @@
+package wltsiwe
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ModChain/outscript"
+	"github.com/ModChain/secp256k1"
+)
+
+const testMessage = `service.org wants you to sign in with your Ethereum account:
+0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2
+
+I accept the ServiceOrg Terms of Service: https://service.org/tos
+
+URI: https://service.org/login
+Version: 1
+Chain ID: 1
+Nonce: 32891756
+Issued At: 2021-09-30T16:25:24Z
+Resources:
+- ipfs://bafybeiemxf5abjwjbikoz4mc3a3dla6ual3jsgpdr4cjr3oz3evfyavhwq/
+- https://example.com/my-web2-claim.json`
+
+func TestParse(t *testing.T) {
+	m, err := Parse(testMessage)
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+	if m.Domain != "service.org" || m.Address != "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2" || m.ChainId != 1 || m.Nonce != "32891756" {
+		t.Errorf("unexpected values %+v", m)
+	}
+	if m.Statement != "I accept the ServiceOrg Terms of Service: https://service.org/tos" {
+		t.Errorf("unexpected statement %q", m.Statement)
+	}
+	if len(m.Resources) != 2 || !m.IssuedAt.Equal(time.Date(2021, 9, 30, 16, 25, 24, 0, time.UTC)) {
+		t.Errorf("unexpected resources or issued at %+v", m)
+	}
+	if m.String() != testMessage {
+		t.Errorf("String() does not produce the original message:\n%s", m.String())
+	}
+
+	if !m.MatchesOrigin("https://service.org") || m.MatchesOrigin("https://evil.org") || m.MatchesOrigin("https://service.org:8443") {
+		t.Errorf("MatchesOrigin returned unexpected results")
+	}
+
+	// without statement
+	noStatement := strings.Replace(testMessage, "I accept the ServiceOrg Terms of Service: https://service.org/tos\n\n", "\n", 1)
+	if m, err := Parse(noStatement); err != nil || m.Statement != "" {
+		t.Errorf("failed to parse message without statement: %v", err)
+	}
+
+	if _, err := Parse("hello world"); err != ErrNotSIWE {
+		t.Errorf("expected ErrNotSIWE, got %v", err)
+	}
+	bad := map[string]string{
+		"checksum": strings.Replace(testMessage, "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", "0xC02AAA39b223FE8D0A0e5C4F27eAD9083C756Cc2", 1),
+		"version":  strings.Replace(testMessage, "Version: 1", "Version: 2", 1),
+		"nonce":    strings.Replace(testMessage, "Nonce: 32891756", "Nonce: 1234", 1),
+		"field":    strings.Replace(testMessage, "Chain ID: 1", "Chain ID: 1\nFoo: bar", 1),
+		"issued":   strings.Replace(testMessage, "Issued At: 2021-09-30T16:25:24Z", "Issued At: yesterday", 1),
+	}
+	for name, s := range bad {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("%s: expected parse error", name)
+		}
+	}
+
+	exp := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+	m.ExpirationTime = &exp
+	if m.CheckTime(exp.Add(-time.Hour)) != nil || m.CheckTime(exp) == nil {
+		t.Errorf("CheckTime does not honor expiration time")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	key, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, err := outscript.New(key.PubKey()).Out("eth").Address()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := strings.Replace(testMessage, "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", addr, 1)
+
+	sig := secp256k1.Sign(key, HashPersonalMessage([]byte(msg))).ExportCompact(false, 27)
+	m, err := Verify(msg, "0x"+hex.EncodeToString(sig))
+	if err != nil {
+		t.Fatalf("failed to verify: %s", err)
+	}
+	if m.Address != addr {
+		t.Errorf("unexpected address %s", m.Address)
+	}
+
+	// signature of another message
+	other := strings.Replace(msg, "Nonce: 32891756", "Nonce: 32891757", 1)
+	if _, err := Verify(other, "0x"+hex.EncodeToString(sig)); err == nil {
+		t.Errorf("signature of another message should not verify")
+	}
+}
@@ -0,0 +1,67 @@
+package wltsiwe
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ModChain/outscript"
+	"github.com/ModChain/secp256k1"
+	"golang.org/x/crypto/sha3"
+)
+
+// HashPersonalMessage returns the hash signed by personal_sign for msg
+func HashPersonalMessage(msg []byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write([]byte("\x19Ethereum Signed Message:\n" + strconv.Itoa(len(msg))))
+	h.Write(msg)
+	return h.Sum(nil)
+}
+
+// RecoverAddress returns the EIP-55 address that produced sig (65 bytes r, s, v) over hash
+func RecoverAddress(hash, sig []byte) (string, error) {
+	if len(sig) != 65 {
+		return "", fmt.Errorf("siwe: invalid signature length %d", len(sig))
+	}
+	v := sig[64]
+	if v >= 27 {
+		v -= 27
+	}
+	if v > 1 {
+		return "", fmt.Errorf("siwe: invalid signature recovery id %d", sig[64])
+	}
+	// compact format is v (27 + recovery id for uncompressed keys), r, s
+	compact := append([]byte{27 + v}, sig[:64]...)
+	pub, _, err := secp256k1.RecoverCompact(compact, hash)
+	if err != nil {
+		return "", fmt.Errorf("siwe: failed to recover public key: %w", err)
+	}
+	return outscript.New(pub).Out("eth").Address()
+}
+
+// Verify checks that sig (0x prefixed hex) is a valid personal_sign signature of the SIWE message msg by
+// the address it contains, and that the message is valid at the current time
+func Verify(msg, sig string) (*Message, error) {
+	m, err := Parse(msg)
+	if err != nil {
+		return nil, err
+	}
+	sigBin, err := hex.DecodeString(strings.TrimPrefix(sig, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("siwe: invalid signature: %w", err)
+	}
+	addr, err := RecoverAddress(HashPersonalMessage([]byte(msg)), sigBin)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(addr, m.Address) {
+		return nil, errors.New("siwe: signature does not match address")
+	}
+	if err := m.CheckTime(time.Now()); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
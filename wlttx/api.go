@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/EllipX/libwallet/wltintf"
+	"github.com/EllipX/libwallet/wltsign"
 	"github.com/KarpelesLab/apirouter"
 	"github.com/KarpelesLab/pobj"
 	"github.com/KarpelesLab/xuid"
@@ -21,6 +22,9 @@ func init() {
 	)
 	pobj.RegisterStatic("Transaction:validate", transactionValidate)
 	pobj.RegisterStatic("Transaction:signAndSend", transactionSignAndSend)
+	pobj.RegisterStatic("Transaction:simulate", transactionSimulate)
+	pobj.RegisterStatic("Transaction:speedUp", transactionSpeedUp)
+	pobj.RegisterStatic("Transaction:cancel", transactionCancel)
 }
 
 func TransactionById(e wltintf.Env, id *xuid.XUID) (*Transaction, error) {
@@ -41,10 +45,60 @@ func transactionValidate(ctx context.Context, tx *Transaction) (any, error) {
 	return tx, tx.Validate(e)
 }
 
+func transactionSimulate(ctx context.Context, tx *Transaction) (any, error) {
+	e := wltintf.GetEnv(ctx)
+	if e == nil {
+		return nil, errors.New("failed to get env")
+	}
+	tx.Keys = nil
+
+	return tx.Simulate(e)
+}
+
 func transactionSignAndSend(ctx context.Context, tx *Transaction) (any, error) {
 	return tx, tx.SignAndSend(ctx, nil)
 }
 
+func transactionSpeedUp(ctx *apirouter.Context, in struct{ Keys []*wltsign.KeyDescription }) (any, error) {
+	return transactionReplace(ctx, in.Keys, false)
+}
+
+func transactionCancel(ctx *apirouter.Context, in struct{ Keys []*wltsign.KeyDescription }) (any, error) {
+	return transactionReplace(ctx, in.Keys, true)
+}
+
+// transactionReplace sends a replacement for a pending transaction and links both transactions
+func transactionReplace(ctx *apirouter.Context, keys []*wltsign.KeyDescription, cancel bool) (any, error) {
+	e := wltintf.GetEnv(ctx)
+	if e == nil {
+		return nil, errors.New("failed to get env")
+	}
+
+	tx := apirouter.GetObject[Transaction](ctx, "Transaction")
+	if tx == nil {
+		return nil, errors.New("Transaction required")
+	}
+	n, err := tx.getNetwork(e)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := tx.replacement(n, cancel)
+	if err != nil {
+		return nil, err
+	}
+	if err := res.SignAndSend(ctx, keys); err != nil {
+		return nil, err
+	}
+
+	tx.ReplacedBy = res.Id
+	if err := tx.save(e); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
 func apiFetchTransaction(ctx *apirouter.Context, in struct{ Id string }) (any, error) {
 	e := wltintf.GetEnv(ctx)
 	if e == nil {
@@ -0,0 +1,346 @@
+package wlttx
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"time"
+
+	"github.com/EllipX/libwallet/wltintf"
+	"github.com/EllipX/libwallet/wltnet"
+	"github.com/EllipX/libwallet/wltsign"
+	"github.com/EllipX/libwallet/wltwallet"
+	"github.com/KarpelesLab/cryptutil"
+	"github.com/KarpelesLab/xuid"
+	"github.com/ModChain/ethrpc"
+	"golang.org/x/crypto/sha3"
+)
+
+// batch status values, as returned by wallet_getCallsStatus (EIP-5792)
+const (
+	BatchPending         = 100 // batch received, not all calls are included in a block yet
+	BatchConfirmed       = 200 // all calls included in a block and successful
+	BatchOffchainFailure = 400 // batch not included on chain, the wallet will not retry
+	BatchReverted        = 500 // batch reverted completely, only changes related to gas were included
+	BatchPartialFailure  = 600 // some calls were included and successful, others failed
+)
+
+// batchGasMargin is added (in percent) to the gas used by calls when simulated together, since gas used does
+// not account for refunds and other calls preceding it may change the cost
+const batchGasMargin = 30
+
+// Batch is a group of calls sent together by a dApp with wallet_sendCalls (EIP-5792). Calls are sent in
+// order as separate transactions with consecutive nonces, since accounts are EOAs and cannot execute them
+// atomically.
+type Batch struct {
+	Id           string         `json:"id" gorm:"primaryKey"`   // batch id, can be chosen by the dApp
+	Host         string         `json:"host" gorm:"primaryKey"` // site that sent the batch, ids are only unique per site
+	Network      *xuid.XUID     `json:"network"`
+	From         string         `json:"from"`
+	Atomic       bool           `json:"atomic"`                       // true if calls are executed atomically
+	Calls        []*Transaction `json:"calls" gorm:"serializer:json"` // calls as validated, in order
+	Transactions []*xuid.XUID   `json:"transactions,omitempty" gorm:"serializer:json"`
+	Created      time.Time      `json:"created" gorm:"autoCreateTime"`
+}
+
+// CallsStatus is the result of wallet_getCallsStatus
+type CallsStatus struct {
+	Version  string         `json:"version"`
+	Id       string         `json:"id"`
+	ChainId  string         `json:"chainId"` // 0x prefixed hex
+	Status   int            `json:"status"`
+	Atomic   bool           `json:"atomic"`
+	Receipts []*CallReceipt `json:"receipts,omitempty"`
+}
+
+// CallReceipt is the receipt of a call included in a block
+type CallReceipt struct {
+	Logs            json.RawMessage `json:"logs"`
+	Status          string          `json:"status"` // 0x1 for success, 0x0 for failure
+	BlockHash       string          `json:"blockHash"`
+	BlockNumber     string          `json:"blockNumber"`
+	GasUsed         string          `json:"gasUsed"`
+	TransactionHash string          `json:"transactionHash"`
+}
+
+// NewBatch validates calls from the site host, which must be evm transactions from the same account, and returns a
+// batch ready to be signed. The batch is not saved until it is sent.
+func NewBatch(e wltintf.Env, n *wltnet.Network, host string, calls []*Transaction) (*Batch, error) {
+	if n.Type != "evm" {
+		return nil, fmt.Errorf("batches are not supported on %s networks", n.Type)
+	}
+	if len(calls) == 0 {
+		return nil, errors.New("batch requires at least one call")
+	}
+	b := &Batch{Id: xuid.Must(xuid.NewRandom("txb")).String(), Host: host, Network: n.Id, Calls: calls}
+	for i, tx := range calls {
+		tx.Type = "evm"
+		tx.Network = n.Id
+		if i == 0 {
+			b.From = tx.From
+		} else if tx.From != b.From {
+			return nil, errors.New("all calls of a batch must be sent from the same account")
+		}
+	}
+
+	// calls may depend on the previous ones (approve then swap) and fail to estimate on their own
+	if err := b.estimateGas(n); err != nil {
+		return nil, err
+	}
+
+	var nonce uint64
+	for i, tx := range calls {
+		if i > 0 {
+			tx.Nonce = nonce + 1
+		}
+		if err := tx.Validate(e); err != nil {
+			return nil, fmt.Errorf("call %d: %w", i, err)
+		}
+		nonce = tx.Nonce
+		b.From = tx.From
+		// assign ids now so the batch can reference its transactions once sent
+		tx.Id = xuid.Must(xuid.NewRandom("tx"))
+		b.Transactions = append(b.Transactions, tx.Id)
+	}
+	return b, nil
+}
+
+// estimateGas sets the gas of calls by simulating them in order with eth_simulateV1. If the node does not
+// support simulations, gas is estimated individually for each call by Validate.
+func (b *Batch) estimateGas(n *wltnet.Network) error {
+	calls := make([]map[string]any, 0, len(b.Calls))
+	for _, tx := range b.Calls {
+		to, value, data, err := tx.evmCall(n)
+		if err != nil {
+			return err
+		}
+		v := map[string]any{"from": tx.From, "value": "0x" + value.Text(16)}
+		if to != "" {
+			v["to"] = to
+		}
+		if len(data) > 0 {
+			v["data"] = fmt.Sprintf("0x%x", data)
+		}
+		calls = append(calls, v)
+	}
+
+	raw, err := n.DoRPC("eth_simulateV1", map[string]any{"blockStateCalls": []any{map[string]any{"calls": calls}}}, "latest")
+	if err != nil {
+		return nil
+	}
+	var res []struct {
+		Calls []struct {
+			Status  string          `json:"status"`
+			GasUsed json.RawMessage `json:"gasUsed"`
+			Error   *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		} `json:"calls"`
+	}
+	if err := json.Unmarshal(raw, &res); err != nil || len(res) != 1 || len(res[0].Calls) != len(b.Calls) {
+		return nil
+	}
+	for i, c := range res[0].Calls {
+		if c.Status != "0x1" {
+			msg := "execution reverted"
+			if c.Error != nil {
+				msg = c.Error.Message
+			}
+			return fmt.Errorf("call %d would fail: %s", i, msg)
+		}
+		gas, err := ethrpc.ReadUint64(c.GasUsed, nil)
+		if err != nil {
+			return nil
+		}
+		if b.Calls[i].Gas == 0 {
+			b.Calls[i].Gas = gas + gas*batchGasMargin/100
+		}
+	}
+	return nil
+}
+
+// SignAndSend saves the batch then signs and sends its calls in order. If a call fails, the following calls
+// are not sent and calling SignAndSend again resumes from the failed call.
+func (b *Batch) SignAndSend(ctx context.Context, keys []*wltsign.KeyDescription) error {
+	e := wltintf.GetEnv(ctx)
+	if e == nil {
+		return errors.New("failed to get env")
+	}
+	if err := e.Save(b); err != nil {
+		return err
+	}
+
+	var pending []*Transaction
+	for _, tx := range b.Calls {
+		if prev, err := TransactionById(e, tx.Id); err == nil && prev.broadcast() {
+			// already sent by a previous attempt
+			continue
+		}
+		pending = append(pending, tx)
+	}
+	if err := signCalls(ctx, e, pending, keys); err != nil {
+		return err
+	}
+
+	for i, tx := range b.Calls {
+		if !slices.Contains(pending, tx) {
+			continue
+		}
+		if err := tx.SignAndSend(ctx, keys); err != nil {
+			return fmt.Errorf("call %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// signCalls signs the given calls in a single wallet batch, so SignAndSend only has to use the resulting
+// signatures. A call that could not be signed, or whose nonce changes when it is sent, is signed again by
+// SignAndSend.
+func signCalls(ctx context.Context, e wltintf.Env, calls []*Transaction, keys []*wltsign.KeyDescription) error {
+	if len(calls) == 0 || keys == nil {
+		return nil
+	}
+	acct, err := calls[0].account(e)
+	if err != nil {
+		return err
+	}
+	n, err := calls[0].getNetwork(e)
+	if err != nil {
+		return err
+	}
+
+	signers := make([]crypto.Signer, len(calls))
+	digests := make([][]byte, len(calls))
+	for i, tx := range calls {
+		t, err := tx.unsignedTx(n)
+		if err != nil {
+			return err
+		}
+		buf, err := t.SignBytes()
+		if err != nil {
+			return err
+		}
+		signers[i] = acct
+		digests[i] = cryptutil.Hash(buf, sha3.NewLegacyKeccak256)
+	}
+
+	sigs, err := acct.SignBatch(rand.Reader, signers, digests, &wltsign.Opts{Context: ctx, IL: acct.IL, Keys: keys})
+	var berr *wltwallet.BatchError
+	if err != nil && !errors.As(err, &berr) {
+		return err
+	}
+	for i, tx := range calls {
+		if sigs[i] != nil {
+			tx.batchSig = &batchSigned{digest: digests[i], sig: sigs[i]}
+		}
+	}
+	return nil
+}
+
+// broadcast returns true if the transaction was accepted by the network at some point
+func (tx *Transaction) broadcast() bool {
+	switch tx.Status {
+	case "", TxRejected:
+		return false
+	default:
+		return true
+	}
+}
+
+// BatchById returns the batch with the given id sent by the site host
+func BatchById(e wltintf.Env, host, id string) (*Batch, error) {
+	var b *Batch
+	if err := e.FirstWhere(&b, map[string]any{"Host": host, "Id": id}); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Status returns the status of the batch and the receipts of its calls included in a block
+func (b *Batch) Status(e wltintf.Env) (*CallsStatus, error) {
+	n, err := wltnet.NetworkById(e, b.Network)
+	if err != nil {
+		return nil, err
+	}
+	res := &CallsStatus{
+		Version: "2.0.0",
+		Id:      b.Id,
+		ChainId: chainIdHex(n),
+		Atomic:  b.Atomic,
+	}
+
+	var confirmed, reverted, offchain, pending int
+	for _, id := range b.Transactions {
+		tx, err := TransactionById(e, id)
+		if err != nil {
+			// not sent yet
+			pending += 1
+			continue
+		}
+		// follow speed up & cancel
+		for tx.Status == TxReplaced && tx.ReplacedBy != nil {
+			next, err := TransactionById(e, tx.ReplacedBy)
+			if err != nil {
+				break
+			}
+			tx = next
+		}
+
+		switch {
+		case tx.Status == TxConfirmed:
+			confirmed += 1
+		case tx.Status == TxFailed:
+			reverted += 1
+		case tx.Status == TxPending || tx.Status == "":
+			pending += 1
+			continue
+		default:
+			// rejected by the node, dropped or replaced
+			offchain += 1
+			continue
+		}
+
+		var r *CallReceipt
+		raw, err := n.DoRPC("eth_getTransactionReceipt", tx.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(raw, &r); err != nil {
+			return nil, err
+		}
+		if r != nil {
+			res.Receipts = append(res.Receipts, r)
+		}
+	}
+
+	switch {
+	case confirmed == len(b.Transactions):
+		res.Status = BatchConfirmed
+	case reverted+offchain == 0:
+		res.Status = BatchPending
+	case pending > 0 && offchain == 0:
+		// wait for the remaining calls before reporting a failure
+		res.Status = BatchPending
+	case confirmed > 0:
+		res.Status = BatchPartialFailure
+	case reverted > 0:
+		res.Status = BatchReverted
+	default:
+		res.Status = BatchOffchainFailure
+	}
+	return res, nil
+}
+
+// chainIdHex returns the chain id of an evm network as 0x prefixed hex
+func chainIdHex(n *wltnet.Network) string {
+	v, ok := new(big.Int).SetString(n.ChainId, 10)
+	if !ok {
+		return n.ChainId
+	}
+	return "0x" + v.Text(16)
+}
@@ -0,0 +1,57 @@
+package wlttx
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestBatchEstimateGas(t *testing.T) {
+	calls := func() []*Transaction {
+		return []*Transaction{
+			{Type: "evm", From: "0x1111111111111111111111111111111111111111", To: "0x2222222222222222222222222222222222222222", Data: "0x095ea7b3"},
+			{Type: "evm", From: "0x1111111111111111111111111111111111111111", To: "0x3333333333333333333333333333333333333333", Data: "0x38ed1739", Gas: 300000},
+		}
+	}
+	simulated := []map[string]any{
+		{"status": "0x1", "gasUsed": "0xb3b0"}, // 46000
+		{"status": "0x1", "gasUsed": "0x249f0"},
+	}
+
+	n := newTestNetwork(t, map[string]func([]json.RawMessage) any{
+		"eth_simulateV1": func(params []json.RawMessage) any {
+			var p struct {
+				BlockStateCalls []struct {
+					Calls []map[string]any `json:"calls"`
+				} `json:"blockStateCalls"`
+			}
+			if err := json.Unmarshal(params[0], &p); err != nil || len(p.BlockStateCalls) != 1 || len(p.BlockStateCalls[0].Calls) != 2 {
+				t.Errorf("unexpected eth_simulateV1 params %s", params[0])
+			}
+			return []any{map[string]any{"calls": simulated}}
+		},
+	})
+
+	b := &Batch{Calls: calls()}
+	if err := b.estimateGas(n); err != nil {
+		t.Fatalf("estimateGas failed: %s", err)
+	}
+	if b.Calls[0].Gas != 59800 {
+		t.Errorf("expected gas used plus margin (59800), got %d", b.Calls[0].Gas)
+	}
+	if b.Calls[1].Gas != 300000 {
+		t.Errorf("gas given by the dApp should be kept, got %d", b.Calls[1].Gas)
+	}
+
+	// a call that would revert fails the whole batch
+	simulated[1] = map[string]any{"status": "0x0", "gasUsed": "0x5208", "error": map[string]any{"message": "insufficient allowance"}}
+	b = &Batch{Calls: calls()}
+	if err := b.estimateGas(n); err == nil {
+		t.Errorf("expected an error for a reverting call")
+	}
+
+	// nodes without eth_simulateV1 leave the estimation to Validate
+	b = &Batch{Calls: calls()}
+	if err := b.estimateGas(newTestNetwork(t, nil)); err != nil || b.Calls[0].Gas != 0 {
+		t.Errorf("expected gas to be left unset, got %d (%v)", b.Calls[0].Gas, err)
+	}
+}
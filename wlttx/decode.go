@@ -0,0 +1,76 @@
+package wlttx
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/EllipX/ellipxobj"
+	"github.com/EllipX/libwallet/wltabi"
+	"github.com/EllipX/libwallet/wltintf"
+	"github.com/EllipX/libwallet/wltnet"
+)
+
+// unlimitedAllowance is the threshold above which an approval is displayed as unlimited. dApps typically
+// use 2**256-1, but some use 2**255 or other large values.
+var unlimitedAllowance = new(big.Int).Lsh(big.NewInt(1), 128)
+
+// decodeData decodes tx.Data into tx.Decoded. Unknown methods are not an error, Decoded is left nil.
+func (tx *Transaction) decodeData(e wltintf.Env, n *wltnet.Network) {
+	tx.Decoded = nil
+	if tx.Data == "" || tx.Data == "0x" {
+		return
+	}
+	_, _, data, err := tx.evmCall(n)
+	if err != nil {
+		return
+	}
+	call, err := wltabi.Decode(e, n.Id, tx.To, data)
+	if err != nil {
+		return
+	}
+	call.Summary = summarizeCall(n, tx.To, call)
+	tx.Decoded = call
+}
+
+// summarizeCall returns a human readable description of common token calls, or an empty string
+func summarizeCall(n *wltnet.Network, contract string, call *wltabi.Call) string {
+	arg := func(i int) string {
+		if i >= len(call.Args) {
+			return ""
+		}
+		v, _ := call.Args[i].Value.(string)
+		return v
+	}
+	// amount formats a token amount using the contract's decimals if it is an ERC-20 token
+	amount := func(s string) string {
+		v, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return s
+		}
+		info, err := n.TokenInfo(contract)
+		if err != nil {
+			return s
+		}
+		if v.Cmp(unlimitedAllowance) >= 0 {
+			return "unlimited " + info.Symbol
+		}
+		return ellipxobj.NewAmountRaw(v, info.Decimals).String() + " " + info.Symbol
+	}
+
+	switch call.Signature {
+	case "transfer(address,uint256)":
+		return fmt.Sprintf("Transfer %s to %s", amount(arg(1)), arg(0))
+	case "transferFrom(address,address,uint256)":
+		return fmt.Sprintf("Transfer %s from %s to %s", amount(arg(2)), arg(0), arg(1))
+	case "approve(address,uint256)":
+		return fmt.Sprintf("Approve %s to %s", amount(arg(1)), arg(0))
+	case "increaseAllowance(address,uint256)":
+		return fmt.Sprintf("Increase allowance of %s by %s", arg(0), amount(arg(1)))
+	case "setApprovalForAll(address,bool)":
+		if v, _ := call.Args[1].Value.(bool); v {
+			return fmt.Sprintf("Allow %s to transfer all your tokens of %s", arg(0), contract)
+		}
+		return fmt.Sprintf("Revoke permission of %s to transfer your tokens of %s", arg(0), contract)
+	}
+	return ""
+}
@@ -0,0 +1,51 @@
+package wlttx
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/EllipX/libwallet/wltabi"
+)
+
+func TestSummarizeCall(t *testing.T) {
+	const contract = "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"
+	n := newTestNetwork(t, map[string]func([]json.RawMessage) any{
+		"eth_call": func(params []json.RawMessage) any {
+			var call map[string]string
+			json.Unmarshal(params[0], &call)
+			switch call["data"] {
+			case "0x313ce567": // decimals
+				return "0x0000000000000000000000000000000000000000000000000000000000000006"
+			case "0x95d89b41": // symbol
+				return "0x" +
+					"0000000000000000000000000000000000000000000000000000000000000020" +
+					"0000000000000000000000000000000000000000000000000000000000000004" +
+					"5553444300000000000000000000000000000000000000000000000000000000"
+			default:
+				return "0x"
+			}
+		},
+	})
+
+	tests := map[string]string{
+		// approve(spender, 2**256-1)
+		"095ea7b3" +
+			"0000000000000000000000002222222222222222222222222222222222222222" +
+			"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff": "Approve unlimited USDC to 0x2222222222222222222222222222222222222222",
+		// transfer(to, 1500000)
+		"a9059cbb" +
+			"0000000000000000000000002222222222222222222222222222222222222222" +
+			"000000000000000000000000000000000000000000000000000000000016e360": "Transfer 1.500000 USDC to 0x2222222222222222222222222222222222222222",
+	}
+	for data, expect := range tests {
+		buf, _ := hex.DecodeString(data)
+		call, err := wltabi.DecodeBuiltin(buf)
+		if err != nil {
+			t.Fatalf("failed to decode %s: %s", data, err)
+		}
+		if v := summarizeCall(n, contract, call); v != expect {
+			t.Errorf("unexpected summary %q, expected %q", v, expect)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package wlttx
+
+import (
+	"errors"
+	"math/big"
+	"slices"
+
+	"github.com/EllipX/libwallet/wltnet"
+	"github.com/ModChain/ethrpc"
+)
+
+const (
+	// number of blocks to look at when computing fee suggestions
+	feeHistoryBlocks = 10
+	// reward percentile used to compute the suggested priority fee
+	feeHistoryPercentile = 50
+)
+
+// minPriorityFee is the lowest priority fee we will ever suggest (1 gwei is too much on some chains, so use 0.01 gwei)
+var minPriorityFee = big.NewInt(10_000_000)
+
+type feeHistory struct {
+	OldestBlock   string     `json:"oldestBlock"`
+	BaseFeePerGas []string   `json:"baseFeePerGas"`
+	GasUsedRatio  []float64  `json:"gasUsedRatio"`
+	Reward        [][]string `json:"reward"`
+}
+
+// dynamicFee contains a fee suggestion for EIP-1559 transactions
+type dynamicFee struct {
+	BaseFee *big.Int // base fee expected for the next block
+	Tip     *big.Int // suggested max priority fee per gas
+	MaxFee  *big.Int // suggested max fee per gas
+}
+
+// suggestDynamicFee uses eth_feeHistory to compute a fee suggestion for the next block
+func suggestDynamicFee(n *wltnet.Network) (*dynamicFee, error) {
+	var hist *feeHistory
+	err := ethrpc.ReadTo(&hist)(n.DoRPC("eth_feeHistory", feeHistoryBlocks, "pending", []int{feeHistoryPercentile}))
+	if err != nil {
+		return nil, err
+	}
+	if hist == nil || len(hist.BaseFeePerGas) == 0 {
+		return nil, errors.New("eth_feeHistory: no base fee returned, network may not support EIP-1559")
+	}
+
+	// last value of baseFeePerGas is the base fee of the block after the newest returned block
+	baseFee, ok := new(big.Int).SetString(hist.BaseFeePerGas[len(hist.BaseFeePerGas)-1], 0)
+	if !ok {
+		return nil, errors.New("eth_feeHistory: invalid base fee value")
+	}
+
+	// take median of rewards, ignoring empty blocks
+	var rewards []*big.Int
+	for _, r := range hist.Reward {
+		if len(r) == 0 {
+			continue
+		}
+		v, ok := new(big.Int).SetString(r[0], 0)
+		if !ok || v.Sign() == 0 {
+			continue
+		}
+		rewards = append(rewards, v)
+	}
+
+	var tip *big.Int
+	if len(rewards) > 0 {
+		slices.SortFunc(rewards, func(a, b *big.Int) int { return a.Cmp(b) })
+		tip = rewards[len(rewards)/2]
+	} else {
+		// no recent reward data, ask the node directly
+		tip, err = ethrpc.ReadBigInt(n.DoRPC("eth_maxPriorityFeePerGas"))
+		if err != nil {
+			tip = new(big.Int)
+		}
+	}
+	if tip.Cmp(minPriorityFee) < 0 {
+		tip = new(big.Int).Set(minPriorityFee)
+	}
+
+	// max fee allows base fee to double before the transaction becomes unincludable
+	maxFee := new(big.Int).Lsh(baseFee, 1)
+	maxFee.Add(maxFee, tip)
+
+	res := &dynamicFee{
+		BaseFee: baseFee,
+		Tip:     tip,
+		MaxFee:  maxFee,
+	}
+	return res, nil
+}
+
+// effectiveGasPrice returns the gas price that will actually be paid for a EIP-1559 transaction given the base fee
+func effectiveGasPrice(baseFee, tip, maxFee *big.Int) *big.Int {
+	if baseFee == nil {
+		return maxFee
+	}
+	res := new(big.Int).Add(baseFee, tip)
+	if res.Cmp(maxFee) > 0 {
+		return maxFee
+	}
+	return res
+}
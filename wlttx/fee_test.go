@@ -0,0 +1,96 @@
+package wlttx
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/EllipX/libwallet/wltnet"
+)
+
+type testRPCRequest struct {
+	Id     any               `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+// newTestNetwork returns a network using a local RPC server answering with the given handlers
+func newTestNetwork(t *testing.T, handlers map[string]func(params []json.RawMessage) any) *wltnet.Network {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req *testRPCRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		res := map[string]any{"jsonrpc": "2.0", "id": req.Id}
+		if h, ok := handlers[req.Method]; ok {
+			res["result"] = h(req.Params)
+		} else {
+			res["error"] = map[string]any{"code": -32601, "message": "method not found: " + req.Method}
+		}
+		json.NewEncoder(w).Encode(res)
+	}))
+	t.Cleanup(srv.Close)
+
+	return &wltnet.Network{Type: "evm", ChainId: "1", RPC: srv.URL}
+}
+
+func TestSuggestDynamicFee(t *testing.T) {
+	n := newTestNetwork(t, map[string]func([]json.RawMessage) any{
+		"eth_feeHistory": func([]json.RawMessage) any {
+			return map[string]any{
+				"oldestBlock":   "0x10",
+				"baseFeePerGas": []string{"0x3b9aca00", "0x3b9aca00", "0x77359400"}, // 1 gwei, 1 gwei, 2 gwei
+				"gasUsedRatio":  []float64{0.5, 0.5},
+				"reward":        [][]string{{"0x5f5e100"}, {"0x0"}, {"0xbebc200"}, {"0x2faf080"}}, // 0.1, 0 (ignored), 0.2, 0.05 gwei
+			}
+		},
+	})
+
+	fee, err := suggestDynamicFee(n)
+	if err != nil {
+		t.Fatalf("suggestDynamicFee failed: %s", err)
+	}
+	if fee.BaseFee.Cmp(big.NewInt(2_000_000_000)) != 0 {
+		t.Errorf("unexpected base fee %s", fee.BaseFee)
+	}
+	if fee.Tip.Cmp(big.NewInt(100_000_000)) != 0 {
+		t.Errorf("unexpected tip %s", fee.Tip)
+	}
+	if fee.MaxFee.Cmp(big.NewInt(4_100_000_000)) != 0 {
+		t.Errorf("unexpected max fee %s", fee.MaxFee)
+	}
+}
+
+func TestEip1559Fee(t *testing.T) {
+	n := newTestNetwork(t, nil)
+
+	tx := &Transaction{
+		Format:       "eip1559",
+		Gas:          21000,
+		MaxFeePerGas: "4100000000",
+		MaxPriority:  "100000000",
+		baseFee:      big.NewInt(1_000_000_000),
+	}
+	if err := tx.computeFee(n); err != nil {
+		t.Fatalf("computeFee failed: %s", err)
+	}
+	// expected = 21000 * 1.1 gwei, max = 21000 * 4.1 gwei
+	if v := tx.Fee.Value(); v.Cmp(big.NewInt(21000*1_100_000_000)) != 0 {
+		t.Errorf("unexpected fee %s", v)
+	}
+	if v := tx.MaxFee.Value(); v.Cmp(big.NewInt(21000*4_100_000_000)) != 0 {
+		t.Errorf("unexpected max fee %s", v)
+	}
+
+	// selected format follows chain features unless a legacy gas price was given
+	if f := tx.selectFormat(n); f != "eip1559" {
+		t.Errorf("expected eip1559 format on mainnet, got %s", f)
+	}
+	legacy := &Transaction{GasPrice: "1000000000"}
+	if f := legacy.selectFormat(n); f != "legacy" {
+		t.Errorf("expected legacy format when gasPrice is set, got %s", f)
+	}
+}
@@ -4,4 +4,8 @@ import "github.com/EllipX/libwallet/wltintf"
 
 func InitEnv(e wltintf.Env) {
 	e.AutoMigrate(&Transaction{})
+	e.AutoMigrate(&Batch{})
+
+	// resume watching transactions sent during a previous run
+	watchTransactions(e)
 }
@@ -0,0 +1,200 @@
+package wlttx
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/EllipX/libwallet/wltnet"
+	"github.com/ModChain/ethrpc"
+)
+
+// nonceResync is how often the local nonce state is compared with the chain
+const nonceResync = time.Minute
+
+// nonceMaxGap is how far above the next nonce a requested nonce can be, as skipped nonces are kept until used
+const nonceMaxGap = 64
+
+// nonceState tracks nonces allocated locally for a given account on a given network, so that transactions
+// signed back to back do not end up with the same nonce
+type nonceState struct {
+	lk       sync.Mutex
+	next     uint64          // next nonce to allocate
+	inflight map[uint64]bool // reserved nonces for transactions being signed/sent
+	free     map[uint64]bool // nonces released below next, to be reused first
+	synced   time.Time       // last time next was compared with the chain's pending nonce
+	lower    bool            // a nonce was rejected or a transaction dropped, next can go back to the chain's value
+}
+
+var (
+	nonces   = make(map[string]*nonceState)
+	noncesLk sync.Mutex
+)
+
+func getNonceState(n *wltnet.Network, addr string) *nonceState {
+	k := n.Id.String() + "/" + strings.ToLower(addr)
+
+	noncesLk.Lock()
+	defer noncesLk.Unlock()
+
+	st, ok := nonces[k]
+	if !ok {
+		st = &nonceState{inflight: make(map[uint64]bool), free: make(map[uint64]bool)}
+		nonces[k] = st
+	}
+	return st
+}
+
+// sync compares the local state with the chain's pending nonce if needed. Must be called with st.lk held.
+func (st *nonceState) sync(n *wltnet.Network, addr string) error {
+	if !st.synced.IsZero() && time.Since(st.synced) < nonceResync {
+		return nil
+	}
+	chain, err := ethrpc.ReadUint64(n.DoRPC("eth_getTransactionCount", addr, "pending"))
+	if err != nil {
+		return err
+	}
+	st.synced = time.Now()
+
+	switch {
+	case chain > st.next:
+		// transactions were sent from elsewhere, or our free nonces were used
+		st.next = chain
+	case chain < st.next && len(st.inflight) == 0 && st.lower:
+		// some of our transactions were rejected or dropped and left a gap, restart from the chain's value.
+		// Without this, a lower value can just be a node that did not see our last transactions yet.
+		st.next = chain
+	default:
+		if chain >= st.next {
+			st.lower = false
+		}
+		return nil
+	}
+	st.lower = false
+	for v := range st.free {
+		if v < chain || v >= st.next {
+			delete(st.free, v)
+		}
+	}
+	return nil
+}
+
+// lowestFree returns the lowest released nonce, if any
+func (st *nonceState) lowestFree() (uint64, bool) {
+	var res uint64
+	found := false
+	for v := range st.free {
+		if !found || v < res {
+			res = v
+			found = true
+		}
+	}
+	return res, found
+}
+
+// nextNonce returns the nonce that would be allocated for addr, without reserving it
+func nextNonce(n *wltnet.Network, addr string) (uint64, error) {
+	st := getNonceState(n, addr)
+	st.lk.Lock()
+	defer st.lk.Unlock()
+
+	if err := st.sync(n, addr); err != nil {
+		return 0, err
+	}
+	if v, ok := st.lowestFree(); ok {
+		return v, nil
+	}
+	return st.next, nil
+}
+
+// reserveNonce allocates a nonce for a transaction from addr. If want is not already in use it is returned
+// as is, otherwise the next available nonce is allocated. The nonce must later be passed to either
+// commitNonce or releaseNonce.
+func reserveNonce(n *wltnet.Network, addr string, want uint64) (uint64, error) {
+	st := getNonceState(n, addr)
+	st.lk.Lock()
+	defer st.lk.Unlock()
+
+	if err := st.sync(n, addr); err != nil {
+		return 0, err
+	}
+
+	if want > st.next+nonceMaxGap {
+		return 0, fmt.Errorf("nonce %d is too far above the next nonce %d", want, st.next)
+	}
+
+	var res uint64
+	switch {
+	case want >= st.next:
+		// mark any skipped nonce as free so it can be used later
+		for v := st.next; v < want; v++ {
+			st.free[v] = true
+		}
+		res = want
+		st.next = want + 1
+	case st.free[want]:
+		res = want
+	default:
+		// want is already in use, allocate another one
+		if v, ok := st.lowestFree(); ok {
+			res = v
+		} else {
+			res = st.next
+			st.next += 1
+		}
+	}
+	delete(st.free, res)
+	st.inflight[res] = true
+	return res, nil
+}
+
+// commitNonce marks a reserved nonce as used by a transaction accepted by the network
+func commitNonce(n *wltnet.Network, addr string, nonce uint64) {
+	st := getNonceState(n, addr)
+	st.lk.Lock()
+	defer st.lk.Unlock()
+
+	delete(st.inflight, nonce)
+}
+
+// releaseNonce returns a reserved nonce after a transaction failed to be signed or sent. If resync is true,
+// the network rejected the nonce and the local state will be checked against the chain on next use.
+func releaseNonce(n *wltnet.Network, addr string, nonce uint64, resync bool) {
+	st := getNonceState(n, addr)
+	st.lk.Lock()
+	defer st.lk.Unlock()
+
+	delete(st.inflight, nonce)
+	if nonce+1 == st.next {
+		st.next = nonce
+		// also drop any free nonce right below
+		for st.next > 0 && st.free[st.next-1] {
+			st.next -= 1
+			delete(st.free, st.next)
+		}
+	} else if nonce < st.next {
+		st.free[nonce] = true
+	}
+	if resync {
+		st.synced = time.Time{}
+		st.lower = true
+	}
+}
+
+// dropNonce is called when a transaction sent from addr was dropped, so the local state can go back to the
+// chain's pending nonce on next use
+func dropNonce(n *wltnet.Network, addr string) {
+	st := getNonceState(n, addr)
+	st.lk.Lock()
+	defer st.lk.Unlock()
+
+	st.synced = time.Time{}
+	st.lower = true
+}
+
+// isNonceError returns true if err was returned by the node because of an invalid nonce
+func isNonceError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "nonce") || strings.Contains(msg, "already known") || strings.Contains(msg, "underpriced")
+}
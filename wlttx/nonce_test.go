@@ -0,0 +1,73 @@
+package wlttx
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/KarpelesLab/xuid"
+)
+
+func TestNonceManager(t *testing.T) {
+	chain := "0x5"
+	n := newTestNetwork(t, map[string]func([]json.RawMessage) any{
+		"eth_getTransactionCount": func([]json.RawMessage) any { return chain },
+	})
+	n.Id = xuid.Must(xuid.NewRandom("net"))
+	const addr = "0x1111111111111111111111111111111111111111"
+
+	// two transactions validated with the same nonce get different ones
+	if v, _ := nextNonce(n, addr); v != 5 {
+		t.Fatalf("expected next nonce 5, got %d", v)
+	}
+	a, _ := reserveNonce(n, addr, 5)
+	b, _ := reserveNonce(n, addr, 5)
+	if a != 5 || b != 6 {
+		t.Fatalf("expected nonces 5 and 6, got %d and %d", a, b)
+	}
+	if v, _ := nextNonce(n, addr); v != 7 {
+		t.Errorf("expected next nonce 7, got %d", v)
+	}
+
+	// first one fails: its nonce is reused first
+	releaseNonce(n, addr, a, false)
+	commitNonce(n, addr, b)
+	if v, _ := reserveNonce(n, addr, 6); v != 5 {
+		t.Errorf("expected released nonce 5 to be reused, got %d", v)
+	}
+	commitNonce(n, addr, 5)
+
+	// last one fails: next goes back
+	c, _ := reserveNonce(n, addr, 0)
+	if c != 7 {
+		t.Fatalf("expected nonce 7, got %d", c)
+	}
+	releaseNonce(n, addr, c, true)
+
+	// chain moved ahead (sent from another wallet), forced resync picks it up
+	chain = "0x9"
+	if v, _ := reserveNonce(n, addr, 0); v != 9 {
+		t.Errorf("expected nonce 9 after resync, got %d", v)
+	}
+
+	// nonces far above the chain are refused instead of filling the gap
+	if _, err := reserveNonce(n, addr, 1<<40); err == nil {
+		t.Errorf("expected a nonce far above the next one to be refused")
+	}
+	if v, _ := nextNonce(n, addr); v != 10 {
+		t.Errorf("expected next nonce 10, got %d", v)
+	}
+	commitNonce(n, addr, 9)
+
+	// a node that did not see the committed transaction yet does not bring its nonce back
+	getNonceState(n, addr).synced = time.Time{}
+	if v, _ := nextNonce(n, addr); v != 10 {
+		t.Errorf("expected next nonce 10 with a lagging node, got %d", v)
+	}
+
+	// once the transaction is dropped, the chain's value is used again
+	dropNonce(n, addr)
+	if v, _ := nextNonce(n, addr); v != 9 {
+		t.Errorf("expected next nonce 9 after drop, got %d", v)
+	}
+}
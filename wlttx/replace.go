@@ -0,0 +1,115 @@
+package wlttx
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/EllipX/ellipxobj"
+	"github.com/EllipX/libwallet/wltnet"
+	"github.com/ModChain/ethrpc"
+)
+
+// replacementBump is the minimum fee increase (in percent) required by nodes to accept a replacement
+// transaction with the same nonce (geth's txpool.pricebump default)
+const replacementBump = 10
+
+// bumpFee returns the fee to use for a replacement: at least replacementBump percent over old, or the
+// current network value if higher
+func bumpFee(old, current *big.Int) *big.Int {
+	res := new(big.Int).Mul(old, big.NewInt(100+replacementBump))
+	res.Add(res, big.NewInt(99)) // round up
+	res.Quo(res, big.NewInt(100))
+	if res.Cmp(old) <= 0 {
+		res.Add(old, big.NewInt(1))
+	}
+	if current != nil && current.Cmp(res) > 0 {
+		return new(big.Int).Set(current)
+	}
+	return res
+}
+
+// replacement returns a new transaction using the same nonce as tx with bumped fees. If cancel is true
+// the replacement is a zero value transfer to self, otherwise the same payload is sent again.
+func (tx *Transaction) replacement(n *wltnet.Network, cancel bool) (*Transaction, error) {
+	if n.Type != "evm" {
+		return nil, fmt.Errorf("transaction replacement is not supported on %s", n)
+	}
+	switch tx.Status {
+	case TxPending, "":
+		// ok
+	default:
+		return nil, fmt.Errorf("transaction is %s and cannot be replaced", tx.Status)
+	}
+	if tx.Hash == "" {
+		return nil, errors.New("transaction was not sent")
+	}
+
+	res := &Transaction{
+		Type:     tx.Type,
+		Asset:    tx.Asset,
+		From:     tx.From,
+		To:       tx.To,
+		Gas:      tx.Gas,
+		Nonce:    tx.Nonce,
+		Format:   tx.Format,
+		Network:  tx.Network,
+		Amount:   tx.Amount,
+		Value:    tx.Value,
+		Data:     tx.Data,
+		Replaces: tx.Id,
+	}
+	if cancel {
+		dec, err := n.NativeDecimals()
+		if err != nil {
+			return nil, err
+		}
+		res.Type = "evm"
+		res.Asset = ""
+		res.To = tx.From
+		res.Amount = ellipxobj.NewAmount(0, dec)
+		res.Value = nil
+		res.Data = ""
+		res.Gas = 21000
+	}
+
+	switch tx.Format {
+	case "eip1559":
+		oldMax, ok := new(big.Int).SetString(tx.MaxFeePerGas, 0)
+		if !ok {
+			return nil, errors.New("invalid maxFeePerGas")
+		}
+		oldTip, ok := new(big.Int).SetString(tx.MaxPriority, 0)
+		if !ok {
+			return nil, errors.New("invalid maxPriorityFeePerGas")
+		}
+		fee, err := suggestDynamicFee(n)
+		if err != nil {
+			return nil, err
+		}
+		res.baseFee = fee.BaseFee
+		tip := bumpFee(oldTip, fee.Tip)
+		maxFee := bumpFee(oldMax, fee.MaxFee)
+		if maxFee.Cmp(tip) < 0 {
+			maxFee = tip
+		}
+		res.MaxPriority = tip.String()
+		res.MaxFeePerGas = maxFee.String()
+	default:
+		res.Format = "legacy"
+		old, ok := new(big.Int).SetString(tx.GasPrice, 0)
+		if !ok {
+			return nil, errors.New("invalid gasPrice")
+		}
+		current, err := ethrpc.ReadBigInt(n.DoRPC("eth_gasPrice"))
+		if err != nil {
+			return nil, err
+		}
+		res.GasPrice = bumpFee(old, current).String()
+	}
+
+	if err := res.computeFee(n); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,65 @@
+package wlttx
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/KarpelesLab/xuid"
+)
+
+func TestBumpFee(t *testing.T) {
+	if v := bumpFee(big.NewInt(1000), nil); v.Int64() != 1100 {
+		t.Errorf("expected 1100, got %s", v)
+	}
+	if v := bumpFee(big.NewInt(1001), big.NewInt(500)); v.Int64() != 1102 {
+		t.Errorf("expected 1102 (rounded up), got %s", v)
+	}
+	if v := bumpFee(big.NewInt(1), nil); v.Int64() != 2 {
+		t.Errorf("expected 2, got %s", v)
+	}
+	if v := bumpFee(big.NewInt(1000), big.NewInt(5000)); v.Int64() != 5000 {
+		t.Errorf("expected current network fee 5000, got %s", v)
+	}
+}
+
+func TestReplacement(t *testing.T) {
+	n := newTestNetwork(t, map[string]func([]json.RawMessage) any{
+		"eth_gasPrice": func([]json.RawMessage) any { return "0x3b9aca00" }, // 1 gwei
+	})
+
+	tx := &Transaction{
+		Id:       xuid.Must(xuid.NewRandom("tx")),
+		Type:     "evm",
+		From:     "0x1111111111111111111111111111111111111111",
+		To:       "0x2222222222222222222222222222222222222222",
+		Data:     "0xa9059cbb",
+		Gas:      50000,
+		GasPrice: "2000000000",
+		Nonce:    7,
+		Format:   "legacy",
+		Hash:     "0xabcd",
+		Status:   TxPending,
+	}
+
+	res, err := tx.replacement(n, false)
+	if err != nil {
+		t.Fatalf("replacement failed: %s", err)
+	}
+	if res.Nonce != 7 || res.Data != tx.Data || res.GasPrice != "2200000000" || res.Replaces != tx.Id {
+		t.Errorf("unexpected speed up transaction: %+v", res)
+	}
+
+	res, err = tx.replacement(n, true)
+	if err != nil {
+		t.Fatalf("replacement failed: %s", err)
+	}
+	if res.Nonce != 7 || res.To != tx.From || res.Data != "" || res.Amount.Sign() != 0 || res.Gas != 21000 {
+		t.Errorf("unexpected cancel transaction: %+v", res)
+	}
+
+	tx.Status = TxConfirmed
+	if _, err := tx.replacement(n, false); err == nil {
+		t.Errorf("expected error when replacing a confirmed transaction")
+	}
+}
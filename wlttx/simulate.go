@@ -0,0 +1,358 @@
+package wlttx
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/EllipX/ellipxobj"
+	"github.com/EllipX/libwallet/wltabi"
+	"github.com/EllipX/libwallet/wltacct"
+	"github.com/EllipX/libwallet/wltintf"
+	"github.com/EllipX/libwallet/wltnet"
+	"github.com/KarpelesLab/cryptutil"
+	"github.com/ModChain/ethrpc"
+	"golang.org/x/crypto/sha3"
+)
+
+var (
+	topicTransfer       = eventTopic("Transfer(address,address,uint256)")                          // ERC-20 & ERC-721
+	topicTransferSingle = eventTopic("TransferSingle(address,address,address,uint256,uint256)")    // ERC-1155
+	topicTransferBatch  = eventTopic("TransferBatch(address,address,address,uint256[],uint256[])") // ERC-1155
+
+	uintArraysTypes = abiTypes("uint256[]", "uint256[]")
+	stringTypes     = abiTypes("string")
+)
+
+// Simulation is the result of running a transaction against the pending block without sending it
+type Simulation struct {
+	Reverted       bool             `json:"reverted"`
+	RevertReason   string           `json:"revert_reason,omitempty"`
+	ReturnData     string           `json:"return_data,omitempty"`
+	Traced         bool             `json:"traced"` // if false, balance changes were guessed from the transaction itself
+	BalanceChanges []*BalanceChange `json:"balance_changes"`
+}
+
+// BalanceChange is a change of balance of the From account caused by a transaction
+type BalanceChange struct {
+	Standard string            `json:"standard"` // native, erc20, erc721 or erc1155
+	Contract string            `json:"contract,omitempty"`
+	TokenId  string            `json:"token_id,omitempty"`
+	Symbol   string            `json:"symbol,omitempty"`
+	Amount   *ellipxobj.Amount `json:"amount"` // negative if sent, positive if received
+}
+
+type callFrame struct {
+	Type  string       `json:"type"`
+	From  string       `json:"from"`
+	To    string       `json:"to"`
+	Value string       `json:"value"`
+	Error string       `json:"error"`
+	Calls []*callFrame `json:"calls"`
+	Logs  []*callLog   `json:"logs"`
+}
+
+type callLog struct {
+	Address string   `json:"address"`
+	Topics  []string `json:"topics"`
+	Data    string   `json:"data"`
+}
+
+func eventTopic(sig string) string {
+	return "0x" + hex.EncodeToString(cryptutil.Hash([]byte(sig), sha3.NewLegacyKeccak256))
+}
+
+// Simulate runs the transaction with eth_call against the pending block and, if the node supports
+// debug_traceCall, computes the balance changes of the From account
+func (tx *Transaction) Simulate(e wltintf.Env) (*Simulation, error) {
+	if tx.From == "" {
+		acct, err := wltacct.CurrentAccount(e)
+		if err != nil {
+			return nil, err
+		}
+		tx.From = acct.Address
+	}
+	n, err := tx.getNetwork(e)
+	if err != nil {
+		return nil, err
+	}
+	return tx.simulate(n)
+}
+
+func (tx *Transaction) simulate(n *wltnet.Network) (*Simulation, error) {
+	if n.Type != "evm" {
+		return nil, fmt.Errorf("simulation is not supported on %s", n)
+	}
+	to, value, data, err := tx.evmCall(n)
+	if err != nil {
+		return nil, err
+	}
+	call := map[string]any{"from": tx.From}
+	if to != "" {
+		call["to"] = to
+	}
+	if value.Sign() > 0 {
+		call["value"] = "0x" + value.Text(16)
+	}
+	if len(data) > 0 {
+		call["data"] = "0x" + hex.EncodeToString(data)
+	}
+	if tx.Gas != 0 {
+		call["gas"] = fmt.Sprintf("0x%x", tx.Gas)
+	}
+
+	res := &Simulation{BalanceChanges: []*BalanceChange{}}
+
+	ret, err := ethrpc.ReadString(n.DoRPC("eth_call", call, "pending"))
+	if err != nil {
+		var rpcErr *ethrpc.ErrorObject
+		if !errors.As(err, &rpcErr) || (rpcErr.Code != 3 && !strings.Contains(strings.ToLower(rpcErr.Message), "revert")) {
+			return nil, err
+		}
+		res.Reverted = true
+		res.RevertReason = rpcErr.Message
+		if s, ok := rpcErr.Data.(string); ok {
+			res.ReturnData = s
+			if reason, ok := decodeRevert(s); ok {
+				res.RevertReason = reason
+			}
+		}
+		return res, nil
+	}
+	res.ReturnData = ret
+
+	var frame *callFrame
+	err = ethrpc.ReadTo(&frame)(n.DoRPC("debug_traceCall", call, "pending", map[string]any{"tracer": "callTracer", "tracerConfig": map[string]any{"withLog": true}}))
+	if err == nil && frame != nil {
+		res.Traced = true
+		res.BalanceChanges = traceBalanceChanges(frame, tx.From)
+	} else {
+		res.BalanceChanges = tx.guessBalanceChanges(value)
+	}
+
+	for _, c := range res.BalanceChanges {
+		c.describe(n)
+	}
+	return res, nil
+}
+
+// describe sets the symbol of a balance change and its decimals for native and ERC-20 amounts
+func (c *BalanceChange) describe(n *wltnet.Network) {
+	switch c.Standard {
+	case "native":
+		c.Symbol, _ = n.NativeSymbol()
+		if dec, err := n.NativeDecimals(); err == nil {
+			c.Amount = ellipxobj.NewAmountRaw(c.Amount.Value(), dec)
+		}
+	case "erc20":
+		if info, err := n.TokenInfo(c.Contract); err == nil {
+			c.Symbol = info.Symbol
+			c.Amount = ellipxobj.NewAmountRaw(c.Amount.Value(), info.Decimals)
+		}
+	}
+}
+
+// guessBalanceChanges returns the balance changes that can be deduced from the transaction without a trace
+func (tx *Transaction) guessBalanceChanges(value *big.Int) []*BalanceChange {
+	res := []*BalanceChange{}
+	if value.Sign() > 0 {
+		res = append(res, &BalanceChange{Standard: "native", Amount: ellipxobj.NewAmountRaw(new(big.Int).Neg(value), 0)})
+	}
+	if token, _ := tx.token(); token != "" && tx.Amount != nil {
+		res = append(res, &BalanceChange{Standard: "erc20", Contract: strings.ToLower(token), Amount: tx.Amount.Neg()})
+	}
+	return res
+}
+
+// traceBalanceChanges walks a callTracer result and sums native value and token transfers to/from addr
+func traceBalanceChanges(root *callFrame, addr string) []*BalanceChange {
+	addr = strings.ToLower(addr)
+	sums := make(map[string]*BalanceChange)
+	var order []string
+
+	add := func(standard, contract, tokenId string, v *big.Int, out bool) {
+		if out {
+			v = new(big.Int).Neg(v)
+		}
+		k := standard + "/" + contract + "/" + tokenId
+		c, ok := sums[k]
+		if !ok {
+			c = &BalanceChange{Standard: standard, Contract: contract, TokenId: tokenId, Amount: ellipxobj.NewAmountRaw(new(big.Int), 0)}
+			sums[k] = c
+			order = append(order, k)
+		}
+		c.Amount = ellipxobj.NewAmountRaw(new(big.Int).Add(c.Amount.Value(), v), 0)
+	}
+
+	var walk func(f *callFrame)
+	walk = func(f *callFrame) {
+		if f.Error != "" {
+			// reverted sub call, nothing happened
+			return
+		}
+		if v, ok := new(big.Int).SetString(f.Value, 0); ok && v.Sign() > 0 && f.Type != "DELEGATECALL" && f.Type != "STATICCALL" {
+			from, to := strings.ToLower(f.From), strings.ToLower(f.To)
+			if from != to {
+				if from == addr {
+					add("native", "", "", v, true)
+				}
+				if to == addr {
+					add("native", "", "", v, false)
+				}
+			}
+		}
+		for _, l := range f.Logs {
+			logBalanceChanges(l, addr, add)
+		}
+		for _, c := range f.Calls {
+			walk(c)
+		}
+	}
+	walk(root)
+
+	res := make([]*BalanceChange, 0, len(order))
+	for _, k := range order {
+		if c := sums[k]; c.Amount.Sign() != 0 {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// logBalanceChanges decodes ERC-20/721/1155 transfer events involving addr
+func logBalanceChanges(l *callLog, addr string, add func(standard, contract, tokenId string, v *big.Int, out bool)) {
+	if len(l.Topics) == 0 {
+		return
+	}
+	contract := strings.ToLower(l.Address)
+	data, err := hex.DecodeString(strings.TrimPrefix(l.Data, "0x"))
+	if err != nil {
+		return
+	}
+	topicAddr := func(t string) string {
+		t = strings.TrimPrefix(strings.ToLower(t), "0x")
+		if len(t) != 64 {
+			return ""
+		}
+		return "0x" + t[24:]
+	}
+	topicInt := func(t string) *big.Int {
+		v, _ := new(big.Int).SetString(strings.TrimPrefix(t, "0x"), 16)
+		return v
+	}
+	word := func(i int) *big.Int {
+		if len(data) < (i+1)*32 {
+			return nil
+		}
+		return new(big.Int).SetBytes(data[i*32 : (i+1)*32])
+	}
+	apply := func(standard, from, to, tokenId string, v *big.Int) {
+		if v == nil || from == to {
+			return
+		}
+		if from == addr {
+			add(standard, contract, tokenId, v, true)
+		}
+		if to == addr {
+			add(standard, contract, tokenId, v, false)
+		}
+	}
+
+	switch strings.ToLower(l.Topics[0]) {
+	case topicTransfer:
+		from, to := "", ""
+		if len(l.Topics) >= 3 {
+			from, to = topicAddr(l.Topics[1]), topicAddr(l.Topics[2])
+		}
+		switch len(l.Topics) {
+		case 3: // ERC-20, value in data
+			apply("erc20", from, to, "", word(0))
+		case 4: // ERC-721, token id indexed
+			if id := topicInt(l.Topics[3]); id != nil {
+				apply("erc721", from, to, id.String(), big.NewInt(1))
+			}
+		}
+	case topicTransferSingle:
+		if len(l.Topics) != 4 {
+			return
+		}
+		id := word(0)
+		if id == nil {
+			return
+		}
+		apply("erc1155", topicAddr(l.Topics[2]), topicAddr(l.Topics[3]), id.String(), word(1))
+	case topicTransferBatch:
+		if len(l.Topics) != 4 {
+			return
+		}
+		ids, values := abiUintArrays(data)
+		if len(ids) != len(values) {
+			return
+		}
+		for i, id := range ids {
+			apply("erc1155", topicAddr(l.Topics[2]), topicAddr(l.Topics[3]), id.String(), values[i])
+		}
+	}
+}
+
+// abiTypes parses a list of ABI types
+func abiTypes(types ...string) []*wltabi.Type {
+	res := make([]*wltabi.Type, 0, len(types))
+	for _, typ := range types {
+		t, err := wltabi.ParseType(typ)
+		if err != nil {
+			panic(err)
+		}
+		res = append(res, t)
+	}
+	return res
+}
+
+// abiUintArrays decodes ABI encoded data made of two uint256[], as found in ERC-1155 TransferBatch events
+func abiUintArrays(data []byte) ([]*big.Int, []*big.Int) {
+	args, err := wltabi.DecodeArgs(uintArraysTypes, data)
+	if err != nil {
+		return nil, nil
+	}
+	var res [2][]*big.Int
+	for i, arg := range args {
+		for _, v := range arg.Value.([]any) {
+			n, _ := new(big.Int).SetString(v.(string), 10)
+			res[i] = append(res[i], n)
+		}
+	}
+	return res[0], res[1]
+}
+
+// decodeRevert decodes Error(string) and Panic(uint256) revert data
+func decodeRevert(s string) (string, bool) {
+	data, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil || len(data) < 4 {
+		return "", false
+	}
+	switch hex.EncodeToString(data[:4]) {
+	case "08c379a0": // Error(string)
+		reason, err := decodeAbiString(data[4:])
+		if err != nil {
+			return "", false
+		}
+		return reason, true
+	case "4e487b71": // Panic(uint256)
+		if len(data) < 36 {
+			return "", false
+		}
+		return fmt.Sprintf("panic: 0x%x", new(big.Int).SetBytes(data[4:36])), true
+	}
+	return "", false
+}
+
+// decodeAbiString decodes a single ABI encoded string argument
+func decodeAbiString(data []byte) (string, error) {
+	args, err := wltabi.DecodeArgs(stringTypes, data)
+	if err != nil {
+		return "", err
+	}
+	return args[0].Value.(string), nil
+}
@@ -0,0 +1,135 @@
+package wlttx
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestEventTopics(t *testing.T) {
+	tests := map[string]string{
+		topicTransfer:       "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+		topicTransferSingle: "0xc3d58168c5ae7397731d063d5bbf3d657854427343f4c083240f7aacaa2d0f62",
+		topicTransferBatch:  "0x4a39dc06d4c0dbc64b70af90fd698a233a518aa5d07e595d983b8c0526c8f7fb",
+	}
+	for got, expect := range tests {
+		if got != expect {
+			t.Errorf("unexpected topic %s, expected %s", got, expect)
+		}
+	}
+}
+
+func TestDecodeRevert(t *testing.T) {
+	data := "0x08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"000000000000000000000000000000000000000000000000000000000000000c" +
+		"6e6f7420616c6c6f776564210000000000000000000000000000000000000000" // "not allowed!"
+	reason, ok := decodeRevert(data)
+	if !ok || reason != "not allowed!" {
+		t.Errorf("unexpected revert reason %q", reason)
+	}
+
+	reason, ok = decodeRevert("0x4e487b710000000000000000000000000000000000000000000000000000000000000011")
+	if !ok || reason != "panic: 0x11" {
+		t.Errorf("unexpected panic reason %q", reason)
+	}
+
+	if _, ok := decodeRevert("0x12345678"); ok {
+		t.Errorf("expected unknown selector to fail")
+	}
+
+	// offsets and lengths that overflow when added must not cause out of range slicing
+	ff := "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"
+	zero := "0000000000000000000000000000000000000000000000000000000000000000"
+	offset := "0000000000000000000000000000000000000000000000000000000000000020"
+	for _, data := range []string{
+		"0x08c379a0" + "000000000000000000000000000000000000000000000000ffffffffffffffff" + zero,
+		"0x08c379a0" + offset + "000000000000000000000000000000000000000000000000ffffffffffffffff",
+		"0x08c379a0" + ff + zero,
+	} {
+		if _, ok := decodeRevert(data); ok {
+			t.Errorf("expected invalid revert data to fail")
+		}
+	}
+	batch := []string{
+		"000000000000000000000000000000000000000000000000ffffffffffffffff" + zero,
+		offset + offset + "000000000000000000000000000000000000000000000000ffffffffffffffff",
+		offset + offset + "0000000000000000000000000000000000000000000000000800000000000001",
+	}
+	for _, data := range batch {
+		buf, _ := hex.DecodeString(data)
+		if ids, values := abiUintArrays(buf); ids != nil || values != nil {
+			t.Errorf("expected invalid batch data to fail")
+		}
+	}
+}
+
+func TestTraceBalanceChanges(t *testing.T) {
+	const (
+		me  = "0x1111111111111111111111111111111111111111"
+		meT = "0x0000000000000000000000001111111111111111111111111111111111111111"
+		otT = "0x0000000000000000000000002222222222222222222222222222222222222222"
+	)
+	frame := &callFrame{
+		Type:  "CALL",
+		From:  me,
+		To:    "0x3333333333333333333333333333333333333333",
+		Value: "0xde0b6b3a7640000", // 1 ether
+		Logs: []*callLog{
+			// ERC-20: receive 0x64
+			{Address: "0xAAAAaaaaAAAAaaaaAAAAaaaaAAAAaaaaAAAAaaaa", Topics: []string{topicTransfer, otT, meT}, Data: "0x0000000000000000000000000000000000000000000000000000000000000064"},
+			// ERC-721: send token 7
+			{Address: "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", Topics: []string{topicTransfer, meT, otT, "0x0000000000000000000000000000000000000000000000000000000000000007"}, Data: "0x"},
+		},
+		Calls: []*callFrame{
+			{
+				Type: "CALL",
+				From: "0x3333333333333333333333333333333333333333",
+				To:   "0xcccccccccccccccccccccccccccccccccccccccc",
+				Logs: []*callLog{
+					// ERC-1155 single: receive 2 of id 5
+					{Address: "0xcccccccccccccccccccccccccccccccccccccccc", Topics: []string{topicTransferSingle, otT, otT, meT}, Data: "0x" +
+						"0000000000000000000000000000000000000000000000000000000000000005" +
+						"0000000000000000000000000000000000000000000000000000000000000002"},
+					// ERC-1155 batch: send 1 of id 5, 3 of id 6
+					{Address: "0xcccccccccccccccccccccccccccccccccccccccc", Topics: []string{topicTransferBatch, otT, meT, otT}, Data: "0x" +
+						"0000000000000000000000000000000000000000000000000000000000000040" +
+						"00000000000000000000000000000000000000000000000000000000000000a0" +
+						"0000000000000000000000000000000000000000000000000000000000000002" +
+						"0000000000000000000000000000000000000000000000000000000000000005" +
+						"0000000000000000000000000000000000000000000000000000000000000006" +
+						"0000000000000000000000000000000000000000000000000000000000000002" +
+						"0000000000000000000000000000000000000000000000000000000000000001" +
+						"0000000000000000000000000000000000000000000000000000000000000003"},
+				},
+			},
+			{
+				// reverted sub call, must be ignored
+				Type:  "CALL",
+				From:  "0x3333333333333333333333333333333333333333",
+				To:    me,
+				Value: "0x1",
+				Error: "execution reverted",
+			},
+		},
+	}
+
+	changes := traceBalanceChanges(frame, me)
+	expect := []struct {
+		standard, contract, tokenId, amount string
+	}{
+		{"native", "", "", "-1000000000000000000"},
+		{"erc20", "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "", "100"},
+		{"erc721", "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", "7", "-1"},
+		{"erc1155", "0xcccccccccccccccccccccccccccccccccccccccc", "5", "1"},
+		{"erc1155", "0xcccccccccccccccccccccccccccccccccccccccc", "6", "-3"},
+	}
+	if len(changes) != len(expect) {
+		t.Fatalf("expected %d balance changes, got %d", len(expect), len(changes))
+	}
+	for i, e := range expect {
+		c := changes[i]
+		if c.Standard != e.standard || c.Contract != e.contract || c.TokenId != e.tokenId || c.Amount.Value().String() != e.amount {
+			t.Errorf("change %d: expected %+v, got %s %s %s %s", i, e, c.Standard, c.Contract, c.TokenId, c.Amount.Value())
+		}
+	}
+}
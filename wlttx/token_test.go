@@ -0,0 +1,54 @@
+package wlttx
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/EllipX/ellipxobj"
+)
+
+func TestTokenTransfer(t *testing.T) {
+	const contract = "0xdac17f958d2ee523a2206206994597c13d831ec7"
+	n := newTestNetwork(t, map[string]func([]json.RawMessage) any{
+		"eth_call": func(params []json.RawMessage) any {
+			var call map[string]string
+			json.Unmarshal(params[0], &call)
+			switch call["data"] {
+			case "0x313ce567": // decimals
+				return "0x0000000000000000000000000000000000000000000000000000000000000006"
+			case "0x95d89b41": // symbol
+				return "0x" +
+					"0000000000000000000000000000000000000000000000000000000000000020" +
+					"0000000000000000000000000000000000000000000000000000000000000004" +
+					"5553445400000000000000000000000000000000000000000000000000000000"
+			default:
+				return "0x"
+			}
+		},
+	})
+
+	amt, _ := ellipxobj.NewAmountFromString("1.5", 0)
+	tx := &Transaction{
+		Type:   "transfer",
+		Asset:  "evm.1." + contract,
+		To:     "0x2222222222222222222222222222222222222222",
+		Amount: amt,
+	}
+	to, value, data, err := tx.evmCall(n)
+	if err != nil {
+		t.Fatalf("evmCall failed: %s", err)
+	}
+	if to != contract {
+		t.Errorf("expected call to token contract, got %s", to)
+	}
+	if value.Sign() != 0 {
+		t.Errorf("expected zero value, got %s", value)
+	}
+	expect := "a9059cbb" +
+		"0000000000000000000000002222222222222222222222222222222222222222" +
+		"000000000000000000000000000000000000000000000000000000000016e360" // 1500000
+	if hex.EncodeToString(data) != expect {
+		t.Errorf("unexpected calldata %x", data)
+	}
+}
@@ -13,7 +13,9 @@ import (
 	"time"
 
 	"github.com/EllipX/ellipxobj"
+	"github.com/EllipX/libwallet/wltabi"
 	"github.com/EllipX/libwallet/wltacct"
+	"github.com/EllipX/libwallet/wltasset"
 	"github.com/EllipX/libwallet/wltintf"
 	"github.com/EllipX/libwallet/wltnet"
 	"github.com/EllipX/libwallet/wltquote"
@@ -25,28 +27,45 @@ import (
 )
 
 type Transaction struct {
-	Id           *xuid.XUID                `json:"id,omitempty" gorm:"primaryKey"`
-	Type         string                    `json:"type"`           // transfer, etc
-	Asset        string                    `json:"asset"`          // asset id (network id + "@" + NATIVE if native, or token id)
-	From         string                    `json:"from,omitempty"` // from (account)
-	To           string                    `json:"to"`
-	Gas          uint64                    `json:"gas"`                // gas amount
-	GasPrice     string                    `json:"gasPrice,omitempty"` // gas price
-	Fee          *ellipxobj.Amount         `json:"fee,omitempty" gorm:"serializer:json"`
-	Nonce        uint64                    `json:"nonce"`            // eth only
-	Format       string                    `json:"format,omitempty"` // transaction format, for ethereum: legacy or eip1559
-	Raw          []byte                    `json:"raw,omitempty"`
-	Hash         string                    `json:"hash,omitempty"`
-	URL          string                    `json:"url,omitempty"`
-	Network      *xuid.XUID                `json:"network,omitempty"`
-	Amount       *ellipxobj.Amount         `json:"amount" gorm:"serializer:json"`
-	Value        *ellipxobj.Amount         `json:"value,omitempty" gorm:"serializer:json"`
-	Data         string                    `json:"data,omitempty"`
-	Keys         []*wltsign.KeyDescription `json:"Keys,omitempty" gorm:"-:all"`
-	Created      *time.Time                `json:"created,omitempty" gorm:"autoCreateTime"`
-	FiatAmount   *ellipxobj.Amount         `json:"fiat_amount,omitempty" gorm:"-:all"`
-	FiatCurrency string                    `json:"fiat_currency,omitempty" gorm:"-:all"`
-	FiatQuote    any                       `json:"fiat_quote,omitempty" gorm:"-:all"`
+	Id            *xuid.XUID                `json:"id,omitempty" gorm:"primaryKey"`
+	Type          string                    `json:"type"`              // transfer, etc
+	Asset         string                    `json:"asset"`             // asset key (network type + "." + chain id + "." + NATIVE if native, or token contract)
+	From          string                    `json:"from,omitempty"`    // from (account address)
+	Account       *xuid.XUID                `json:"account,omitempty"` // id of the sending account
+	To            string                    `json:"to"`
+	Gas           uint64                    `json:"gas"`                            // gas amount
+	GasPrice      string                    `json:"gasPrice,omitempty"`             // gas price (legacy)
+	MaxFeePerGas  string                    `json:"maxFeePerGas,omitempty"`         // max fee per gas (eip1559)
+	MaxPriority   string                    `json:"maxPriorityFeePerGas,omitempty"` // max priority fee per gas (eip1559)
+	Fee           *ellipxobj.Amount         `json:"fee,omitempty" gorm:"serializer:json"`
+	MaxFee        *ellipxobj.Amount         `json:"max_fee,omitempty" gorm:"serializer:json"`
+	Nonce         uint64                    `json:"nonce"`             // eth only
+	FeeRate       uint64                    `json:"feeRate,omitempty"` // fee rate in satoshis per vbyte (bitcoin only)
+	Format        string                    `json:"format,omitempty"`  // transaction format, for ethereum: legacy or eip1559
+	Raw           []byte                    `json:"raw,omitempty"`
+	Hash          string                    `json:"hash,omitempty"`
+	URL           string                    `json:"url,omitempty"`
+	Network       *xuid.XUID                `json:"network,omitempty"`
+	Status        string                    `json:"status,omitempty"` // pending, confirmed, failed, rejected, dropped or replaced
+	Error         string                    `json:"error,omitempty"`  // error returned by the node if the transaction was rejected
+	BlockNumber   uint64                    `json:"block_number,omitempty"`
+	Confirmations uint64                    `json:"confirmations,omitempty"`
+	GasUsed       uint64                    `json:"gas_used,omitempty"`
+	ActualFee     *ellipxobj.Amount         `json:"actual_fee,omitempty" gorm:"serializer:json"`
+	Watch         bool                      `json:"-" gorm:"index"`        // true while the watcher needs to check this transaction
+	Replaces      *xuid.XUID                `json:"replaces,omitempty"`    // id of the transaction this one replaces (speed up or cancel)
+	ReplacedBy    *xuid.XUID                `json:"replaced_by,omitempty"` // id of the replacement transaction, if any
+	Amount        *ellipxobj.Amount         `json:"amount" gorm:"serializer:json"`
+	Value         *ellipxobj.Amount         `json:"value,omitempty" gorm:"serializer:json"`
+	Data          string                    `json:"data,omitempty"`
+	Decoded       *wltabi.Call              `json:"decoded,omitempty" gorm:"serializer:json"` // decoded Data, if the method is known
+	Keys          []*wltsign.KeyDescription `json:"Keys,omitempty" gorm:"-:all"`
+	Created       *time.Time                `json:"created,omitempty" gorm:"autoCreateTime"`
+	FiatAmount    *ellipxobj.Amount         `json:"fiat_amount,omitempty" gorm:"-:all"`
+	FiatCurrency  string                    `json:"fiat_currency,omitempty" gorm:"-:all"`
+	FiatQuote     any                       `json:"fiat_quote,omitempty" gorm:"-:all"`
+	baseFee       *big.Int                  // base fee at time of validation, used to compute expected fee
+	batchSig      *batchSigned              // signature computed beforehand by Batch.SignAndSend, if any
 }
 
 func (tx *Transaction) save(e wltintf.Env) error {
@@ -64,6 +83,14 @@ func (tx *Transaction) save(e wltintf.Env) error {
 func (tx *Transaction) getNetwork(e wltintf.Env) (*wltnet.Network, error) {
 	if tx.Network != nil {
 		return wltnet.NetworkById(e, tx.Network)
+	} else if tx.Asset != "" {
+		// asset key includes the network
+		typ, chainId, _, err := wltasset.ParseKey(tx.Asset)
+		if err != nil {
+			return nil, err
+		}
+		tx.Network = wltnet.NetworkIdForTypeAndChainId(typ, chainId)
+		return wltnet.NetworkById(e, tx.Network)
 	} else {
 		n, err := wltnet.CurrentNetwork(e)
 		if err != nil {
@@ -76,12 +103,102 @@ func (tx *Transaction) getNetwork(e wltintf.Env) (*wltnet.Network, error) {
 
 func (tx *Transaction) getSymbol(e wltintf.Env) (string, error) {
 	// a.Asset = evm.137.NATIVE
-	// TODO For now we only do native assets anyway, return network symbol
 	net, err := tx.getNetwork(e)
 	if err != nil {
 		return "", err
 	}
-	return net.NativeSymbol()
+	token, err := tx.token()
+	if err != nil {
+		return "", err
+	}
+	if token == "" {
+		return net.NativeSymbol()
+	}
+	info, err := net.TokenInfo(token)
+	if err != nil {
+		return "", err
+	}
+	return info.Symbol, nil
+}
+
+// token returns the contract address of the token being transferred, or an empty string
+// if this transaction is not a token transfer
+func (tx *Transaction) token() (string, error) {
+	if tx.Type != "transfer" || tx.Asset == "" {
+		return "", nil
+	}
+	_, _, token, err := wltasset.ParseKey(tx.Asset)
+	if err != nil {
+		return "", err
+	}
+	if token == wltasset.NativeToken {
+		return "", nil
+	}
+	return token, nil
+}
+
+// evmCall returns the actual destination, value and data of the transaction as it will be sent on
+// chain. For token transfers this is a call to the token contract's transfer(address,uint256).
+func (tx *Transaction) evmCall(n *wltnet.Network) (string, *big.Int, []byte, error) {
+	var data []byte
+	if tx.Data != "" {
+		v, ok := strings.CutPrefix(tx.Data, "0x")
+		if !ok {
+			return "", nil, nil, errors.New("bad tx.Data: must start with 0x or be empty")
+		}
+		var err error
+		data, err = hex.DecodeString(v)
+		if err != nil {
+			return "", nil, nil, err
+		}
+	}
+	value := new(big.Int)
+	if tx.Amount != nil && tx.Amount.Sign() > 0 {
+		value = tx.Amount.Value()
+	}
+	if tx.Value != nil && tx.Value.Sign() > 0 {
+		value = tx.Value.Value()
+	}
+
+	token, err := tx.token()
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if token == "" {
+		return tx.To, value, data, nil
+	}
+	if len(data) != 0 {
+		return "", nil, nil, errors.New("data cannot be specified for token transfers")
+	}
+	info, err := n.TokenInfo(token)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	data, err = erc20TransferData(tx.To, tx.Amount.Dup().SetExp(info.Decimals).Value())
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return info.Contract, new(big.Int), data, nil
+}
+
+// erc20TransferData returns the calldata for transfer(address,uint256)
+func erc20TransferData(to string, amount *big.Int) ([]byte, error) {
+	addr, ok := strings.CutPrefix(to, "0x")
+	if !ok || len(addr) != 40 {
+		return nil, fmt.Errorf("invalid destination address %s", to)
+	}
+	addrBin, err := hex.DecodeString(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid destination address %s: %w", to, err)
+	}
+	if amount.Sign() < 0 || amount.BitLen() > 256 {
+		return nil, errors.New("invalid token amount")
+	}
+	res := make([]byte, 4+32+32)
+	copy(res, []byte{0xa9, 0x05, 0x9c, 0xbb}) // transfer(address,uint256)
+	copy(res[4+12:36], addrBin)
+	amount.FillBytes(res[36:])
+	return res, nil
 }
 
 func (tx *Transaction) convertTo(e wltintf.Env, currency string) error {
@@ -115,9 +232,48 @@ func (tx *Transaction) convertTo(e wltintf.Env, currency string) error {
 }
 
 func (tx *Transaction) encodeTx(n *wltnet.Network, acct *wltacct.Account, csigner crypto.Signer, signopts crypto.SignerOpts) (*outscript.EvmTx, error) {
+	res, err := tx.unsignedTx(n)
+	if err != nil {
+		return nil, err
+	}
+	err = res.SignWithOptions(csigner, signopts)
+	return res, err
+}
+
+// unsignedTx returns the EVM transaction for tx, before signature
+func (tx *Transaction) unsignedTx(n *wltnet.Network) (*outscript.EvmTx, error) {
 	switch tx.Type {
 	case "transfer", "evm":
+		info, err := n.GetChainInfo()
+		if err != nil {
+			return nil, err
+		}
+		to, value, data, err := tx.evmCall(n)
+		if err != nil {
+			return nil, err
+		}
+		res := &outscript.EvmTx{
+			Nonce:   tx.Nonce,
+			Gas:     tx.Gas,
+			To:      to,
+			Value:   value,
+			Data:    data,
+			ChainId: info.ChainId,
+		}
+
 		switch tx.Format {
+		case "eip1559":
+			maxFee, ok := new(big.Int).SetString(tx.MaxFeePerGas, 0)
+			if !ok {
+				return nil, errors.New("invalid maxFeePerGas")
+			}
+			tip, ok := new(big.Int).SetString(tx.MaxPriority, 0)
+			if !ok {
+				return nil, errors.New("invalid maxPriorityFeePerGas")
+			}
+			res.Type = outscript.EvmTxEIP1559
+			res.GasFeeCap = maxFee
+			res.GasTipCap = tip
 		case "legacy":
 			fallthrough
 		default:
@@ -125,53 +281,32 @@ func (tx *Transaction) encodeTx(n *wltnet.Network, acct *wltacct.Account, csigne
 			if !ok {
 				return nil, errors.New("invalid gasPrice")
 			}
-			info, err := n.GetChainInfo()
-			if err != nil {
-				return nil, err
-			}
-			res := &outscript.EvmTx{
-				Type:      outscript.EvmTxLegacy,
-				Nonce:     tx.Nonce,
-				GasFeeCap: v,
-				Gas:       tx.Gas,
-				To:        tx.To,
-				Value:     tx.Amount.Value(),
-				ChainId:   info.ChainId,
-			}
-			if tx.Value != nil && tx.Value.Sign() > 0 {
-				res.Value = tx.Value.Value()
-			}
-			if data := tx.Data; data != "" {
-				if data, ok := strings.CutPrefix(data, "0x"); ok {
-					dataBin, err := hex.DecodeString(data)
-					if err != nil {
-						return nil, err
-					}
-					res.Data = dataBin
-				} else {
-					return nil, errors.New("bad tx.Data: must start with 0x or be empty")
-				}
-			}
-			err = res.SignWithOptions(csigner, signopts)
-			return res, err
+			res.Type = outscript.EvmTxLegacy
+			res.GasFeeCap = v
 		}
+		return res, nil
 	default:
 	}
 	return nil, errors.New("TODO")
 }
 
 func (tx *Transaction) estimateGas(n *wltnet.Network) error {
+	to, value, data, err := tx.evmCall(n)
+	if err != nil {
+		return err
+	}
 	v := make(map[string]any)
-	if tx.Data != "" {
-		v["data"] = tx.Data
+	if len(data) > 0 {
+		v["data"] = "0x" + hex.EncodeToString(data)
 	}
-	if tx.Amount.Sign() > 0 {
-		v["value"] = "0x" + tx.Amount.Value().Text(16)
-	} else if tx.Value.Sign() > 0 {
-		v["value"] = "0x" + tx.Value.Value().Text(16)
+	if value.Sign() > 0 {
+		v["value"] = "0x" + value.Text(16)
 	}
-	if tx.To != "" {
-		v["to"] = tx.To
+	if to != "" {
+		v["to"] = to
+	}
+	if tx.From != "" {
+		v["from"] = tx.From
 	}
 
 	log.Printf("about to run eth_estimateGas with: %+v", v)
@@ -205,19 +340,16 @@ func (tx *Transaction) Validate(e wltintf.Env) error {
 	var acct *wltacct.Account
 	var err error
 
-	if tx.From == "" {
+	if tx.From == "" && tx.Account == nil {
 		acct, err = wltacct.CurrentAccount(e)
-		if err != nil {
-			return err
-		}
-		tx.From = acct.Address
 	} else {
-		acct, err = wltacct.FindAccount(e, tx.From)
-		if err != nil {
-			return err
-		}
-		tx.From = acct.Address
+		acct, err = tx.account(e)
+	}
+	if err != nil {
+		return err
 	}
+	tx.Account = acct.Id
+	tx.From = acct.Address
 
 	n, err := tx.getNetwork(e)
 	if err != nil {
@@ -225,8 +357,18 @@ func (tx *Transaction) Validate(e wltintf.Env) error {
 	}
 	tx.Network = n.Id
 
+	if tx.Type == "transfer" {
+		if err := tx.validateAsset(n, acct); err != nil {
+			return err
+		}
+	}
+	if n.Type == "bitcoin" {
+		return tx.validateBtc(n, acct)
+	}
+	tx.decodeData(e, n)
+
 	if tx.Nonce == 0 {
-		txc, err := ethrpc.ReadUint64(n.DoRPC("eth_getTransactionCount", acct.Address, "pending"))
+		txc, err := nextNonce(n, acct.Address)
 		if err != nil {
 			return err
 		}
@@ -240,38 +382,145 @@ func (tx *Transaction) Validate(e wltintf.Env) error {
 		}
 	}
 
-	if tx.GasPrice == "" {
-		v, err := ethrpc.ReadBigInt(n.DoRPC("eth_gasPrice"))
+	if tx.Format == "" {
+		tx.Format = tx.selectFormat(n)
+	}
+
+	switch tx.Format {
+	case "eip1559":
+		fee, err := suggestDynamicFee(n)
 		if err != nil {
 			return err
 		}
-		tx.GasPrice = v.String()
+		tx.baseFee = fee.BaseFee
+		if tx.MaxPriority == "" {
+			tx.MaxPriority = fee.Tip.String()
+		}
+		if tx.MaxFeePerGas == "" {
+			tx.MaxFeePerGas = fee.MaxFee.String()
+		}
+		// priority fee cannot exceed max fee
+		maxFee, _ := new(big.Int).SetString(tx.MaxFeePerGas, 0)
+		tip, _ := new(big.Int).SetString(tx.MaxPriority, 0)
+		if maxFee == nil || tip == nil {
+			return errors.New("invalid maxFeePerGas or maxPriorityFeePerGas")
+		}
+		if tip.Cmp(maxFee) > 0 {
+			tx.MaxPriority = tx.MaxFeePerGas
+		}
+		tx.GasPrice = ""
+	case "legacy":
+		if tx.GasPrice == "" {
+			v, err := ethrpc.ReadBigInt(n.DoRPC("eth_gasPrice"))
+			if err != nil {
+				return err
+			}
+			tx.GasPrice = v.String()
+		}
+		tx.MaxFeePerGas = ""
+		tx.MaxPriority = ""
+	default:
+		return fmt.Errorf("unsupported transaction format %s", tx.Format)
 	}
 
-	tx.computeFee(n)
+	return tx.computeFee(n)
+}
 
-	if tx.Format == "" {
-		// TODO check ChainInfo.HasFeature("EIP1559")
-		tx.Format = "legacy"
+// validateAsset checks the asset being transferred matches the network and, for tokens, that the
+// account holds enough of it. Amount is converted to the asset's decimals.
+func (tx *Transaction) validateAsset(n *wltnet.Network, acct *wltacct.Account) error {
+	typ, chainId, _, err := wltasset.ParseKey(tx.Asset)
+	if err != nil {
+		return err
+	}
+	if typ != n.Type || chainId != n.ChainId {
+		return fmt.Errorf("asset %s is not on network %s", tx.Asset, n)
+	}
+	token, err := tx.token()
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		dec, err := n.NativeDecimals()
+		if err != nil {
+			return err
+		}
+		tx.Amount = tx.Amount.Dup().SetExp(dec)
+		return nil
+	}
+	if n.Type != "evm" {
+		return fmt.Errorf("tokens are not supported on %s", n)
+	}
+
+	info, err := n.TokenInfo(token)
+	if err != nil {
+		return err
+	}
+	tx.Amount = tx.Amount.Dup().SetExp(info.Decimals)
+	if tx.Amount.Sign() <= 0 {
+		return errors.New("invalid amount")
+	}
+	if tx.Value != nil && tx.Value.Sign() > 0 {
+		return errors.New("value cannot be specified for token transfers")
+	}
+
+	bal, err := n.TokenBalance(token, acct)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s balance: %w", info.Symbol, err)
+	}
+	if bal.Cmp(tx.Amount) < 0 {
+		return fmt.Errorf("insufficient %s balance: %s available", info.Symbol, bal)
 	}
 	return nil
 }
 
+// selectFormat returns the best transaction format for the given network. If the transaction was
+// provided with a gasPrice only (typically from a dApp), legacy format is kept.
+func (tx *Transaction) selectFormat(n *wltnet.Network) string {
+	if tx.GasPrice != "" && tx.MaxFeePerGas == "" {
+		return "legacy"
+	}
+	info, err := n.GetChainInfo()
+	if err != nil {
+		return "legacy"
+	}
+	if info.HasFeature("EIP1559") {
+		return "eip1559"
+	}
+	return "legacy"
+}
+
+// computeFee sets tx.Fee to the expected fee and tx.MaxFee to the maximum fee that can be paid by this transaction
 func (tx *Transaction) computeFee(n *wltnet.Network) error {
-	// fee = gas*gasPrice
 	info, err := n.GetChainInfo()
 	if err != nil {
 		return err
 	}
+	gas := new(big.Int).SetUint64(tx.Gas)
 
-	gp, ok := new(big.Int).SetString(tx.GasPrice, 0)
-	if !ok {
-		return errors.New("invalid gasprice")
+	switch tx.Format {
+	case "eip1559":
+		// maxFee = gas*maxFeePerGas, fee = gas*min(maxFeePerGas, baseFee+maxPriorityFeePerGas)
+		maxFee, ok := new(big.Int).SetString(tx.MaxFeePerGas, 0)
+		if !ok {
+			return errors.New("invalid maxFeePerGas")
+		}
+		tip, ok := new(big.Int).SetString(tx.MaxPriority, 0)
+		if !ok {
+			return errors.New("invalid maxPriorityFeePerGas")
+		}
+		price := effectiveGasPrice(tx.baseFee, tip, maxFee)
+		tx.MaxFee = ellipxobj.NewAmountRaw(new(big.Int).Mul(maxFee, gas), info.NativeCurrency.Decimals)
+		tx.Fee = ellipxobj.NewAmountRaw(new(big.Int).Mul(price, gas), info.NativeCurrency.Decimals)
+	default:
+		// fee = gas*gasPrice
+		gp, ok := new(big.Int).SetString(tx.GasPrice, 0)
+		if !ok {
+			return errors.New("invalid gasprice")
+		}
+		tx.Fee = ellipxobj.NewAmountRaw(new(big.Int).Mul(gp, gas), info.NativeCurrency.Decimals)
+		tx.MaxFee = tx.Fee
 	}
-
-	amt := ellipxobj.NewAmountRaw(gp, info.NativeCurrency.Decimals)
-	gas := ellipxobj.NewAmount(int64(tx.Gas), 0)
-	tx.Fee = amt.Dup().Mul(amt, gas)
 	return nil
 }
 
@@ -284,6 +533,14 @@ func (tx *Transaction) ApiDelete(ctx *apirouter.Context) error {
 	return e.Delete(tx)
 }
 
+// account returns the account sending tx, found by id if known or else by address
+func (tx *Transaction) account(e wltintf.Env) (*wltacct.Account, error) {
+	if tx.Account != nil {
+		return wltacct.AccountById(e, tx.Account)
+	}
+	return wltacct.FindAccount(e, tx.From)
+}
+
 func (tx *Transaction) SignAndSend(ctx context.Context, keys []*wltsign.KeyDescription) error {
 	e := wltintf.GetEnv(ctx)
 	if e == nil {
@@ -293,10 +550,10 @@ func (tx *Transaction) SignAndSend(ctx context.Context, keys []*wltsign.KeyDescr
 	var acct *wltacct.Account
 	var err error
 
-	if tx.From == "" {
+	if tx.From == "" && tx.Account == nil {
 		return errors.New("from is required")
 	}
-	acct, err = wltacct.FindAccount(e, tx.From)
+	acct, err = tx.account(e)
 	if err != nil {
 		return err
 	}
@@ -323,15 +580,47 @@ func (tx *Transaction) SignAndSend(ctx context.Context, keys []*wltsign.KeyDescr
 		Keys:    keys,
 	}
 
-	data, err := tx.encodeTx(n, acct, acct, signOpt)
-	if err != nil {
-		return err
+	// sent is set once the network accepted the transaction, sendErr if it was rejected
+	var sent bool
+	var sendErr error
+	if n.Type == "evm" && tx.Replaces == nil {
+		// allocate the nonce locally so transactions sent back to back do not conflict
+		nonce, err := reserveNonce(n, tx.From, tx.Nonce)
+		if err != nil {
+			return err
+		}
+		tx.Nonce = nonce
+		defer func() {
+			if sent {
+				commitNonce(n, tx.From, nonce)
+			} else {
+				releaseNonce(n, tx.From, nonce, sendErr != nil && isNonceError(sendErr))
+			}
+		}()
 	}
-	// sets: tx.Hash = hex.EncodeToString(h[:])
-	// return secp256k1.Sign(digestHash, seckey)
-	buf, err := data.MarshalBinary()
-	if err != nil {
-		return err
+
+	var buf []byte
+	switch n.Type {
+	case "bitcoin":
+		buf, err = tx.encodeBtcTx(n, acct, signOpt)
+		if err != nil {
+			return err
+		}
+	default:
+		var signer crypto.Signer = acct
+		if tx.batchSig != nil {
+			signer = &batchSigned{Signer: acct, digest: tx.batchSig.digest, sig: tx.batchSig.sig}
+		}
+		data, err := tx.encodeTx(n, acct, signer, signOpt)
+		if err != nil {
+			return err
+		}
+		// sets: tx.Hash = hex.EncodeToString(h[:])
+		// return secp256k1.Sign(digestHash, seckey)
+		buf, err = data.MarshalBinary()
+		if err != nil {
+			return err
+		}
 	}
 	tx.Raw = buf
 
@@ -340,17 +629,34 @@ func (tx *Transaction) SignAndSend(ctx context.Context, keys []*wltsign.KeyDescr
 		return err
 	}
 
-	// eth_sendRawTransaction
-	hash, err := ethrpc.ReadString(n.DoRPC("eth_sendRawTransaction", "0x"+hex.EncodeToString(buf)))
+	var hash string
+	switch n.Type {
+	case "bitcoin":
+		hash, err = n.BroadcastBtcTx(buf)
+	default:
+		// eth_sendRawTransaction
+		hash, err = ethrpc.ReadString(n.DoRPC("eth_sendRawTransaction", "0x"+hex.EncodeToString(buf)))
+	}
 	if err != nil {
+		sendErr = err
+		tx.Status = TxRejected
+		tx.Error = err.Error()
+		tx.save(e)
+		tx.broadcastStatus()
 		return err
 	}
+	sent = true
 	// should already be the same
 	tx.Hash = hash
 	tx.URL = n.TransactionUrl(tx.Hash)
+	tx.Status = TxPending
+	tx.Error = ""
+	tx.Watch = true
 	if err := tx.save(e); err != nil {
 		return fmt.Errorf("failed to save transaction after broadcast: %w", err)
 	}
+	tx.broadcastStatus()
+	watchTransactions(e)
 
 	return nil
 }
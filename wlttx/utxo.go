@@ -0,0 +1,406 @@
+package wlttx
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/EllipX/ellipxobj"
+	"github.com/EllipX/libwallet/wltacct"
+	"github.com/EllipX/libwallet/wltnet"
+	"github.com/EllipX/libwallet/wltschnorr"
+	"github.com/EllipX/libwallet/wltsign"
+	"github.com/ModChain/bech32m"
+	"github.com/ModChain/outscript"
+	"github.com/ModChain/secp256k1"
+)
+
+// btcPlan is the result of coin selection for a bitcoin-based transaction
+type btcPlan struct {
+	tx     *outscript.BtcTx // unsigned transaction, inputs are prefilled for size computation
+	inputs []*btcCoin       // spent outputs, in the same order as tx.In
+	fee    uint64           // fee in satoshis
+	change uint64           // amount sent back to the account
+}
+
+// btcSigner describes the key and address used by an account on a bitcoin-based network
+type btcSigner struct {
+	Signer  crypto.Signer
+	Scheme  string // p2wpkh, p2pkh or p2tr
+	Script  []byte // output script of the account's address
+	Address string
+}
+
+// btcAccount returns the signer, output script and address of acct on the given bitcoin-based network
+func btcAccount(n *wltnet.Network, acct *wltacct.Account) (*btcSigner, error) {
+	scheme, addrNet, err := n.BtcFormat()
+	if err != nil {
+		return nil, err
+	}
+	signer, err := acct.BtcSigner()
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := signer.Public().(*secp256k1.PublicKey)
+	if !ok {
+		return nil, errors.New("unsupported public key type for bitcoin")
+	}
+	out := outscript.New(pub).Out(scheme)
+	addr, err := out.Address(addrNet)
+	if err != nil {
+		return nil, err
+	}
+	res := &btcSigner{
+		Signer:  signer,
+		Scheme:  scheme,
+		Script:  out.Bytes(),
+		Address: addr,
+	}
+	return res, nil
+}
+
+// btcTaproot returns the signer, output script and address of the Taproot address of acct, which is only used on
+// bitcoin (see wltacct.Account.TaprootSigner)
+func btcTaproot(acct *wltacct.Account) (*btcSigner, error) {
+	signer, err := acct.TaprootSigner()
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := signer.Public().(*secp256k1.PublicKey)
+	if !ok {
+		return nil, errors.New("unsupported public key type for taproot")
+	}
+	x := wltschnorr.XOnly(pub)
+	addr, err := bech32m.SegwitAddrEncode("bc", 1, x)
+	if err != nil {
+		return nil, err
+	}
+	res := &btcSigner{
+		Signer:  signer,
+		Scheme:  "p2tr",
+		Script:  append([]byte{0x51, 0x20}, x...), // OP_1 <32 bytes output key>
+		Address: addr,
+	}
+	return res, nil
+}
+
+// btcSources returns the addresses of acct whose unspent outputs can be spent on the given network. The first one
+// is the account's main address, which also receives the change.
+func btcSources(n *wltnet.Network, acct *wltacct.Account) ([]*btcSigner, error) {
+	from, err := btcAccount(n, acct)
+	if err != nil {
+		return nil, err
+	}
+	if n.ChainId != "bitcoin" {
+		return []*btcSigner{from}, nil
+	}
+	tr, err := btcTaproot(acct)
+	if err != nil {
+		return nil, err
+	}
+	return []*btcSigner{from, tr}, nil
+}
+
+// btcCoin is an unspent output along with the account address it was sent to
+type btcCoin struct {
+	*wltnet.Utxo
+	From *btcSigner
+}
+
+// selectCoins picks unspent outputs to pay amount to dest at the given fee rate (in satoshis per vbyte). Larger
+// outputs are used first to keep the number of inputs low, and change is returned to the change script unless
+// it would be dust, in which case it is added to the fee.
+func selectCoins(utxos []*btcCoin, amount, feeRate, dust uint64, dest, change []byte) (*btcPlan, error) {
+	if amount < dust {
+		return nil, fmt.Errorf("amount is below the dust limit of %d", dust)
+	}
+
+	sorted := slices.Clone(utxos)
+	slices.SortStableFunc(sorted, func(a, b *btcCoin) int {
+		// confirmed outputs first, then by decreasing value
+		if ac, bc := a.Height > 0, b.Height > 0; ac != bc {
+			if ac {
+				return -1
+			}
+			return 1
+		}
+		switch {
+		case a.Value > b.Value:
+			return -1
+		case a.Value < b.Value:
+			return 1
+		}
+		return 0
+	})
+
+	tx := &outscript.BtcTx{Version: 2}
+	plan := &btcPlan{tx: tx}
+	var total uint64
+
+	for _, u := range sorted {
+		in, err := utxoInput(u.Utxo, u.From.Scheme)
+		if err != nil {
+			return nil, err
+		}
+		tx.In = append(tx.In, in)
+		plan.inputs = append(plan.inputs, u)
+		total += u.Value
+
+		// try with a change output first
+		tx.Out = []*outscript.BtcTxOutput{{Amount: amount, Script: dest}, {Script: change}}
+		fee := uint64(tx.ComputeSize()) * feeRate
+		if total >= amount+fee && total-amount-fee >= dust {
+			tx.Out[1].Amount = total - amount - fee
+			plan.fee = fee
+			plan.change = tx.Out[1].Amount
+			return plan, nil
+		}
+
+		// no change, any extra goes to fees
+		tx.Out = tx.Out[:1]
+		fee = uint64(tx.ComputeSize()) * feeRate
+		if total >= amount+fee {
+			plan.fee = total - amount
+			return plan, nil
+		}
+	}
+
+	return nil, fmt.Errorf("insufficient funds: %d available, need %d plus fees", total, amount)
+}
+
+// utxoInput returns a transaction input spending u, prefilled with an empty signature of the right size
+func utxoInput(u *wltnet.Utxo, scheme string) (*outscript.BtcTxInput, error) {
+	txid, err := hex.DecodeString(u.TxId)
+	if err != nil || len(txid) != 32 {
+		return nil, fmt.Errorf("invalid utxo txid %s", u.TxId)
+	}
+	in := &outscript.BtcTxInput{
+		Vout:     u.Vout,
+		Sequence: 0xffffffff,
+	}
+	copy(in.TXID[:], txid)
+	if scheme == "p2tr" {
+		// key path spend, a single 64 bytes schnorr signature with SIGHASH_DEFAULT
+		in.Witnesses = [][]byte{make([]byte, 64)}
+		return in, nil
+	}
+	if err := in.Prefill(scheme); err != nil {
+		return nil, err
+	}
+	return in, nil
+}
+
+// btcAmount returns tx.Amount in satoshis
+func (tx *Transaction) btcAmount() (uint64, error) {
+	if tx.Amount == nil {
+		return 0, errors.New("invalid amount")
+	}
+	v := tx.Amount.Dup().SetExp(8).Value()
+	if v.Sign() <= 0 || !v.IsUint64() {
+		return 0, errors.New("invalid amount")
+	}
+	return v.Uint64(), nil
+}
+
+// planBtcTx lists the unspent outputs of the account's addresses and selects coins for this transaction, sending
+// the change to the first address
+func (tx *Transaction) planBtcTx(n *wltnet.Network, sources []*btcSigner) (*btcPlan, error) {
+	amount, err := tx.btcAmount()
+	if err != nil {
+		return nil, err
+	}
+	dest, err := n.BtcScript(tx.To)
+	if err != nil {
+		return nil, fmt.Errorf("invalid destination address: %w", err)
+	}
+	if tx.FeeRate == 0 {
+		tx.FeeRate, err = n.BtcFeeRate()
+		if err != nil {
+			return nil, err
+		}
+	}
+	var coins []*btcCoin
+	for _, from := range sources {
+		utxos, err := n.ListUnspent(from.Script)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range utxos {
+			coins = append(coins, &btcCoin{Utxo: u, From: from})
+		}
+	}
+	plan, err := selectCoins(coins, amount, tx.FeeRate, n.BtcDustLimit(), dest, sources[0].Script)
+	if err != nil {
+		return nil, err
+	}
+	tx.Fee = ellipxobj.NewAmount(int64(plan.fee), 8)
+	tx.MaxFee = tx.Fee
+	return plan, nil
+}
+
+// validateBtc validates a transfer on a bitcoin-based network, selecting coins to compute the fee
+func (tx *Transaction) validateBtc(n *wltnet.Network, acct *wltacct.Account) error {
+	if tx.Type != "transfer" {
+		return fmt.Errorf("unsupported transaction type %s on %s", tx.Type, n)
+	}
+	sources, err := btcSources(n, acct)
+	if err != nil {
+		return err
+	}
+	// account addresses are stored for the evm format, SignAndSend finds the account with tx.Account
+	tx.From = sources[0].Address
+	tx.Format = sources[0].Scheme
+	tx.Gas = 0
+	tx.Nonce = 0
+
+	_, err = tx.planBtcTx(n, sources)
+	return err
+}
+
+// encodeBtcTx builds and signs a bitcoin-based transaction, all the inputs being signed by the account's wallet in
+// a single batch
+func (tx *Transaction) encodeBtcTx(n *wltnet.Network, acct *wltacct.Account, signopts *wltsign.Opts) ([]byte, error) {
+	sources, err := btcSources(n, acct)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := tx.planBtcTx(n, sources)
+	if err != nil {
+		return nil, err
+	}
+
+	signBatch := func(signers []crypto.Signer, digests [][]byte) ([][]byte, error) {
+		return acct.SignBatch(rand.Reader, signers, digests, signopts)
+	}
+	if err := signBtcTx(plan, n.BtcSigHash(), signopts, signBatch); err != nil {
+		return nil, err
+	}
+	return plan.tx.Bytes(), nil
+}
+
+// signBatchFunc signs digests[i] with signers[i], all at once (see wltacct.Account.SignBatch)
+type signBatchFunc func(signers []crypto.Signer, digests [][]byte) ([][]byte, error)
+
+// signBtcTx signs all the inputs of plan with the key of the address they were sent to. The digest of each input
+// is computed first so all the inputs can be signed in a single batch, then outscript builds the inputs with the
+// resulting signatures.
+func signBtcTx(plan *btcPlan, sigHash uint32, opts crypto.SignerOpts, signBatch signBatchFunc) error {
+	keys := make([]*outscript.BtcTxSign, len(plan.inputs))
+	recorders := make([]*digestRecorder, len(plan.inputs))
+	for i, u := range plan.inputs {
+		recorders[i] = &digestRecorder{Signer: u.From.Signer}
+		keys[i] = btcSignKey(u, recorders[i], opts, sigHash)
+	}
+	if err := plan.tx.Dup().Sign(keys...); err != nil {
+		return err
+	}
+
+	signers := make([]crypto.Signer, len(plan.inputs))
+	digests := make([][]byte, len(plan.inputs))
+	for i, u := range plan.inputs {
+		signers[i] = u.From.Signer
+		digests[i] = recorders[i].digest
+		if u.From.Scheme == "p2tr" {
+			digests[i] = taprootSigHash(plan.tx, plan.inputs, i)
+		}
+	}
+	sigs, err := signBatch(signers, digests)
+	if err != nil {
+		return err
+	}
+
+	for i, u := range plan.inputs {
+		keys[i] = btcSignKey(u, &batchSigned{Signer: u.From.Signer, digest: digests[i], sig: sigs[i]}, opts, sigHash)
+	}
+	if err := plan.tx.Sign(keys...); err != nil {
+		return err
+	}
+	for i, u := range plan.inputs {
+		if u.From.Scheme == "p2tr" {
+			plan.tx.In[i].Script = nil
+			plan.tx.In[i].Witnesses = [][]byte{sigs[i]}
+		}
+	}
+	return nil
+}
+
+// btcSignKey returns the outscript signing parameters of input u, signed with key. As outscript does not know
+// taproot, taproot inputs get no signature and their witness is set by signBtcTx. This does not change the other
+// signatures, as neither BIP143 nor BIP341 signatures commit to witnesses.
+func btcSignKey(u *btcCoin, key crypto.Signer, opts crypto.SignerOpts, sigHash uint32) *outscript.BtcTxSign {
+	res := &outscript.BtcTxSign{
+		Key:     key,
+		Options: opts,
+		Scheme:  u.From.Scheme,
+		Amount:  u.Value,
+		SigHash: sigHash,
+	}
+	if u.From.Scheme == "p2tr" {
+		res.Key = &digestRecorder{Signer: u.From.Signer}
+		res.Scheme = "p2wpkh"
+	}
+	return res
+}
+
+// digestRecorder keeps the digest it is asked to sign and produces no signature
+type digestRecorder struct {
+	crypto.Signer
+	digest []byte
+}
+
+// Sign implements the crypto.Signer interface
+func (r *digestRecorder) Sign(_ io.Reader, digest []byte, _ crypto.SignerOpts) ([]byte, error) {
+	r.digest = digest
+	return nil, nil
+}
+
+// batchSigned returns a signature computed beforehand by a batch for its digest, other digests are signed by the
+// wrapped signer
+type batchSigned struct {
+	crypto.Signer
+	digest, sig []byte
+}
+
+// Sign implements the crypto.Signer interface
+func (b *batchSigned) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if b.sig != nil && bytes.Equal(digest, b.digest) {
+		return b.sig, nil
+	}
+	return b.Signer.Sign(rand, digest, opts)
+}
+
+// taprootSigHash returns the BIP341 signature hash of the i-th input of tx for a key path spend with
+// SIGHASH_DEFAULT. Unlike segwit v0, it commits to the amounts and output scripts of all the spent outputs.
+func taprootSigHash(tx *outscript.BtcTx, spent []*btcCoin, i int) []byte {
+	var prevouts, amounts, scripts, sequences, outputs []byte
+	for n, in := range tx.In {
+		txid := slices.Clone(in.TXID[:])
+		slices.Reverse(txid)
+		prevouts = binary.LittleEndian.AppendUint32(append(prevouts, txid...), in.Vout)
+		amounts = binary.LittleEndian.AppendUint64(amounts, spent[n].Value)
+		scripts = append(scripts, outscript.BtcVarInt(len(spent[n].From.Script)).Bytes()...)
+		scripts = append(scripts, spent[n].From.Script...)
+		sequences = binary.LittleEndian.AppendUint32(sequences, in.Sequence)
+	}
+	for _, out := range tx.Out {
+		outputs = append(outputs, out.Bytes()...)
+	}
+
+	msg := []byte{0x00, 0x00} // sighash epoch, hash type (SIGHASH_DEFAULT)
+	msg = binary.LittleEndian.AppendUint32(msg, tx.Version)
+	msg = binary.LittleEndian.AppendUint32(msg, tx.Locktime)
+	for _, b := range [][]byte{prevouts, amounts, scripts, sequences, outputs} {
+		h := sha256.Sum256(b)
+		msg = append(msg, h[:]...)
+	}
+	msg = append(msg, 0x00) // spend type: key path, no annex
+	msg = binary.LittleEndian.AppendUint32(msg, uint32(i))
+	return wltschnorr.TaggedHash("TapSighash", msg)
+}
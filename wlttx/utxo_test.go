@@ -0,0 +1,139 @@
+package wlttx
+
+import (
+	"bytes"
+	"crypto"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/EllipX/libwallet/wltnet"
+	"github.com/EllipX/libwallet/wltschnorr"
+	"github.com/ModChain/outscript"
+	"github.com/ModChain/secp256k1"
+)
+
+func TestSelectCoins(t *testing.T) {
+	dest := append([]byte{0x00, 0x14}, bytes.Repeat([]byte{0x11}, 20)...)   // p2wpkh
+	change := append([]byte{0x00, 0x14}, bytes.Repeat([]byte{0x22}, 20)...) // p2wpkh
+	from := &btcSigner{Scheme: "p2wpkh", Script: change}
+	utxos := []*btcCoin{
+		{&wltnet.Utxo{TxId: strings.Repeat("01", 32), Vout: 0, Height: 100, Value: 10_000}, from},
+		{&wltnet.Utxo{TxId: strings.Repeat("02", 32), Vout: 1, Height: 100, Value: 50_000}, from},
+		{&wltnet.Utxo{TxId: strings.Repeat("03", 32), Vout: 0, Height: 0, Value: 80_000}, from}, // unconfirmed
+	}
+
+	// confirmed largest output covers amount and change
+	plan, err := selectCoins(utxos, 30_000, 2, 546, dest, change)
+	if err != nil {
+		t.Fatalf("selectCoins failed: %s", err)
+	}
+	if len(plan.inputs) != 1 || plan.inputs[0].Value != 50_000 {
+		t.Errorf("expected the 50000 output to be selected, got %+v", plan.inputs)
+	}
+	if len(plan.tx.Out) != 2 || plan.tx.Out[1].Amount != plan.change {
+		t.Fatalf("expected a change output")
+	}
+	if plan.fee+plan.change+30_000 != 50_000 {
+		t.Errorf("inputs do not match outputs + fee: fee=%d change=%d", plan.fee, plan.change)
+	}
+	if plan.fee != uint64(plan.tx.ComputeSize())*2 {
+		t.Errorf("unexpected fee %d for size %d", plan.fee, plan.tx.ComputeSize())
+	}
+
+	// change below dust goes to fees
+	plan, err = selectCoins(utxos[:1], 9_500, 1, 546, dest, change)
+	if err != nil {
+		t.Fatalf("selectCoins failed: %s", err)
+	}
+	if len(plan.tx.Out) != 1 || plan.fee != 500 {
+		t.Errorf("expected no change and fee=500, got %d outputs and fee=%d", len(plan.tx.Out), plan.fee)
+	}
+
+	// need several inputs
+	plan, err = selectCoins(utxos, 100_000, 1, 546, dest, change)
+	if err != nil {
+		t.Fatalf("selectCoins failed: %s", err)
+	}
+	if len(plan.inputs) != 3 {
+		t.Errorf("expected all 3 inputs to be used, got %d", len(plan.inputs))
+	}
+
+	if _, err := selectCoins(utxos, 200_000, 1, 546, dest, change); err == nil {
+		t.Errorf("expected insufficient funds error")
+	}
+	if _, err := selectCoins(utxos, 100, 1, 546, dest, change); err == nil {
+		t.Errorf("expected dust error")
+	}
+}
+
+// testBtcKey records the digests it is asked to sign
+type testBtcKey struct {
+	pub     *secp256k1.PublicKey
+	digests [][]byte
+}
+
+func (k *testBtcKey) Public() crypto.PublicKey { return k.pub }
+
+func (k *testBtcKey) Sign(_ io.Reader, digest []byte, _ crypto.SignerOpts) ([]byte, error) {
+	k.digests = append(k.digests, digest)
+	return bytes.Repeat([]byte{0x42}, 64), nil
+}
+
+func TestSignBtcTaproot(t *testing.T) {
+	dest := append([]byte{0x00, 0x14}, bytes.Repeat([]byte{0x11}, 20)...)
+	wpkhKey := &testBtcKey{pub: secp256k1.PrivKeyFromBytes(bytes.Repeat([]byte{1}, 32)).PubKey()}
+	trKey := &testBtcKey{pub: secp256k1.PrivKeyFromBytes(bytes.Repeat([]byte{2}, 32)).PubKey()}
+	segwit := &btcSigner{Signer: wpkhKey, Scheme: "p2wpkh", Script: outscript.New(wpkhKey.pub).Out("p2wpkh").Bytes()}
+	taproot := &btcSigner{Signer: trKey, Scheme: "p2tr", Script: append([]byte{0x51, 0x20}, wltschnorr.XOnly(trKey.pub)...)}
+	coins := []*btcCoin{
+		{&wltnet.Utxo{TxId: strings.Repeat("01", 32), Vout: 0, Height: 100, Value: 10_000}, segwit},
+		{&wltnet.Utxo{TxId: strings.Repeat("02", 32), Vout: 3, Height: 100, Value: 20_000}, taproot},
+	}
+
+	plan, err := selectCoins(coins, 25_000, 1, 546, dest, segwit.Script)
+	if err != nil {
+		t.Fatalf("selectCoins failed: %s", err)
+	}
+	if len(plan.inputs) != 2 || plan.inputs[0].From != taproot {
+		t.Fatalf("expected the taproot output to be spent first, got %+v", plan.inputs)
+	}
+	batches := 0
+	signBatch := func(signers []crypto.Signer, digests [][]byte) ([][]byte, error) {
+		batches += 1
+		res := make([][]byte, len(digests))
+		for i, s := range signers {
+			res[i], _ = s.Sign(nil, digests[i], crypto.SHA256)
+		}
+		return res, nil
+	}
+	if err := signBtcTx(plan, 0x01, crypto.SHA256, signBatch); err != nil {
+		t.Fatalf("signBtcTx failed: %s", err)
+	}
+	if batches != 1 {
+		t.Errorf("expected all the inputs to be signed in a single batch, got %d batches", batches)
+	}
+
+	if len(trKey.digests) != 1 || len(wpkhKey.digests) != 1 {
+		t.Fatalf("expected one signature per key, got %d taproot and %d segwit", len(trKey.digests), len(wpkhKey.digests))
+	}
+	sigHash := taprootSigHash(plan.tx, plan.inputs, 0)
+	if !bytes.Equal(trKey.digests[0], sigHash) {
+		t.Errorf("taproot input signed the wrong digest")
+	}
+	if w := plan.tx.In[0].Witnesses; len(w) != 1 || len(w[0]) != 64 || len(plan.tx.In[0].Script) != 0 {
+		t.Errorf("unexpected taproot input witness %x script %x", w, plan.tx.In[0].Script)
+	}
+	if w := plan.tx.In[1].Witnesses; len(w) != 2 || !bytes.Equal(w[0], append(bytes.Repeat([]byte{0x42}, 64), 0x01)) || !bytes.Equal(w[1], wpkhKey.pub.SerializeCompressed()) {
+		t.Errorf("unexpected segwit input witness %x", w)
+	}
+
+	// BIP341 signatures commit to the input index and to the amounts of all spent outputs
+	if bytes.Equal(taprootSigHash(plan.tx, plan.inputs, 1), sigHash) {
+		t.Errorf("signature hash does not depend on the input index")
+	}
+	other := []*btcCoin{plan.inputs[0], {&wltnet.Utxo{TxId: plan.inputs[1].TxId, Value: 10_001}, segwit}}
+	if bytes.Equal(taprootSigHash(plan.tx, other, 0), sigHash) {
+		t.Errorf("signature hash does not depend on the amounts of other inputs")
+	}
+}
@@ -0,0 +1,256 @@
+package wlttx
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/EllipX/ellipxobj"
+	"github.com/EllipX/libwallet/wltintf"
+	"github.com/EllipX/libwallet/wltnet"
+	"github.com/EllipX/libwallet/wltutil"
+	"github.com/ModChain/ethrpc"
+)
+
+// transaction status values
+const (
+	TxPending   = "pending"   // broadcast, not yet included in a block
+	TxConfirmed = "confirmed" // included in a block and successful
+	TxFailed    = "failed"    // included in a block but reverted
+	TxRejected  = "rejected"  // refused by the node when broadcasting, see Transaction.Error
+	TxDropped   = "dropped"   // removed from the mempool, or nonce used by an unknown transaction
+	TxReplaced  = "replaced"  // nonce used by another of our transactions (speed up, cancel, etc)
+)
+
+const (
+	// interval between two checks of watched transactions
+	watchInterval = 10 * time.Second
+	// number of confirmations after which we stop watching a transaction
+	watchConfirmations = 12
+	// time after which a transaction unknown to the node is considered dropped
+	watchDropTimeout = 30 * time.Minute
+)
+
+var (
+	watchers   = make(map[wltintf.Env]bool)
+	watchersLk sync.Mutex
+)
+
+type txReceipt struct {
+	Status            string `json:"status"`
+	BlockNumber       string `json:"blockNumber"`
+	GasUsed           string `json:"gasUsed"`
+	EffectiveGasPrice string `json:"effectiveGasPrice"`
+}
+
+// watchTransactions starts the watcher for the given env if not already running. The watcher stops by itself once
+// no transaction needs to be watched anymore.
+func watchTransactions(e wltintf.Env) {
+	watchersLk.Lock()
+	defer watchersLk.Unlock()
+
+	if watchers[e] {
+		return
+	}
+	watchers[e] = true
+	go watchLoop(e)
+}
+
+func watchLoop(e wltintf.Env) {
+	t := time.NewTicker(watchInterval)
+	defer t.Stop()
+
+	for range t.C {
+		var list []*Transaction
+		err := e.Find(&list, map[string]any{"Watch": true})
+		if err != nil {
+			log.Printf("tx watcher: failed to list transactions: %s", err)
+		}
+		if len(list) == 0 {
+			watchersLk.Lock()
+			// check again while locked in case a transaction was just added
+			if e.Find(&list, map[string]any{"Watch": true}) != nil || len(list) == 0 {
+				delete(watchers, e)
+				watchersLk.Unlock()
+				return
+			}
+			watchersLk.Unlock()
+		}
+
+		for _, tx := range list {
+			if err := tx.refresh(e); err != nil {
+				log.Printf("tx watcher: failed to refresh %s: %s", tx.Id, err)
+			}
+		}
+	}
+}
+
+// refresh updates the status of the transaction from the network, and saves & broadcasts any change
+func (tx *Transaction) refresh(e wltintf.Env) error {
+	n, err := tx.getNetwork(e)
+	if err != nil {
+		// network was probably removed, nothing more we can do
+		tx.Watch = false
+		tx.save(e)
+		return err
+	}
+
+	prev := tx.Status
+	prevConf := tx.Confirmations
+
+	switch n.Type {
+	case "evm":
+		err = tx.refreshEvm(n)
+		if err == nil && tx.Status == TxDropped {
+			tx.checkReplaced(e)
+		}
+		if err == nil && tx.Status == TxDropped && prev != TxDropped {
+			dropNonce(n, tx.From)
+		}
+	case "bitcoin":
+		err = tx.refreshBtc(n)
+	default:
+		tx.Watch = false
+	}
+	if err != nil {
+		return err
+	}
+
+	if tx.Status == prev && tx.Confirmations == prevConf && tx.Watch {
+		return nil
+	}
+	if err := tx.save(e); err != nil {
+		return err
+	}
+	tx.broadcastStatus()
+	return nil
+}
+
+func (tx *Transaction) broadcastStatus() {
+	go wltutil.BroadcastMsg("tx:status", map[string]any{
+		"id":            tx.Id.String(),
+		"hash":          tx.Hash,
+		"status":        tx.Status,
+		"confirmations": tx.Confirmations,
+	})
+}
+
+// refreshEvm checks the transaction receipt and updates status, block, gas used and actual fee
+func (tx *Transaction) refreshEvm(n *wltnet.Network) error {
+	if tx.Hash == "" {
+		return errors.New("transaction has no hash")
+	}
+	// fetch nonce before the receipt so a transaction mined in between isn't seen as dropped
+	nonce, err := ethrpc.ReadUint64(n.DoRPC("eth_getTransactionCount", tx.From, "latest"))
+	if err != nil {
+		return err
+	}
+	var receipt *txReceipt
+	if err := ethrpc.ReadTo(&receipt)(n.DoRPC("eth_getTransactionReceipt", tx.Hash)); err != nil {
+		return err
+	}
+
+	if receipt == nil {
+		// not mined (yet), check if the transaction is still around
+		var known json.RawMessage
+		if err := ethrpc.ReadTo(&known)(n.DoRPC("eth_getTransactionByHash", tx.Hash)); err != nil {
+			return err
+		}
+		if nonce > tx.Nonce {
+			// nonce was used by another transaction
+			tx.Status = TxDropped
+			tx.Watch = false
+			return nil
+		}
+		if len(known) == 0 || string(known) == "null" {
+			if tx.Created != nil && time.Since(*tx.Created) > watchDropTimeout {
+				tx.Status = TxDropped
+				tx.Watch = false
+			}
+			return nil
+		}
+		tx.Status = TxPending
+		return nil
+	}
+
+	block, ok := new(big.Int).SetString(receipt.BlockNumber, 0)
+	if !ok {
+		return errors.New("invalid block number in receipt")
+	}
+	head, err := ethrpc.ReadUint64(n.DoRPC("eth_blockNumber"))
+	if err != nil {
+		return err
+	}
+	tx.BlockNumber = block.Uint64()
+	if head >= tx.BlockNumber {
+		tx.Confirmations = head - tx.BlockNumber + 1
+	}
+
+	if receipt.Status == "0x1" {
+		tx.Status = TxConfirmed
+	} else {
+		tx.Status = TxFailed
+	}
+
+	gasUsed, ok := new(big.Int).SetString(receipt.GasUsed, 0)
+	if ok {
+		tx.GasUsed = gasUsed.Uint64()
+		if price, ok := new(big.Int).SetString(receipt.EffectiveGasPrice, 0); ok {
+			if dec, err := n.NativeDecimals(); err == nil {
+				tx.ActualFee = ellipxobj.NewAmountRaw(price.Mul(price, gasUsed), dec)
+			}
+		}
+	}
+
+	if tx.Confirmations >= watchConfirmations {
+		tx.Watch = false
+	}
+	return nil
+}
+
+// checkReplaced marks a dropped transaction as replaced if another of our transactions used the same nonce
+func (tx *Transaction) checkReplaced(e wltintf.Env) {
+	var list []*Transaction
+	err := e.Find(&list, map[string]any{"From": tx.From, "Network": tx.Network, "Nonce": tx.Nonce})
+	if err != nil {
+		return
+	}
+	for _, other := range list {
+		if other.Hash != tx.Hash && other.Hash != "" {
+			tx.Status = TxReplaced
+			return
+		}
+	}
+}
+
+// refreshBtc checks the number of confirmations of a transaction on a bitcoin-based network
+func (tx *Transaction) refreshBtc(n *wltnet.Network) error {
+	var info *struct {
+		Confirmations uint64 `json:"confirmations"`
+	}
+	err := ethrpc.ReadTo(&info)(n.DoRPC("blockchain.transaction.get", tx.Hash, true))
+	if err != nil {
+		if tx.Created != nil && time.Since(*tx.Created) > watchDropTimeout {
+			tx.Status = TxDropped
+			tx.Watch = false
+			return nil
+		}
+		return err
+	}
+	if info == nil || info.Confirmations == 0 {
+		tx.Status = TxPending
+		return nil
+	}
+	tx.Status = TxConfirmed
+	tx.Confirmations = info.Confirmations
+	if tx.Fee != nil {
+		tx.ActualFee = tx.Fee
+	}
+	if tx.Confirmations >= watchConfirmations {
+		tx.Watch = false
+	}
+	return nil
+}
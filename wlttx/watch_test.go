@@ -0,0 +1,58 @@
+package wlttx
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestRefreshEvm(t *testing.T) {
+	var receipt any
+	n := newTestNetwork(t, map[string]func([]json.RawMessage) any{
+		"eth_getTransactionCount":   func([]json.RawMessage) any { return "0x5" },
+		"eth_getTransactionReceipt": func([]json.RawMessage) any { return receipt },
+		"eth_getTransactionByHash":  func([]json.RawMessage) any { return map[string]any{"hash": "0xabcd"} },
+		"eth_blockNumber":           func([]json.RawMessage) any { return "0x64" },
+	})
+
+	tx := &Transaction{Hash: "0xabcd", From: "0x1111111111111111111111111111111111111111", Nonce: 5, Watch: true}
+
+	// still in mempool
+	if err := tx.refreshEvm(n); err != nil {
+		t.Fatalf("refreshEvm failed: %s", err)
+	}
+	if tx.Status != TxPending || !tx.Watch {
+		t.Errorf("expected pending, got %s", tx.Status)
+	}
+
+	// mined in block 98, 3 confirmations
+	receipt = map[string]any{"status": "0x1", "blockNumber": "0x62", "gasUsed": "0x5208", "effectiveGasPrice": "0x3b9aca00"}
+	if err := tx.refreshEvm(n); err != nil {
+		t.Fatalf("refreshEvm failed: %s", err)
+	}
+	if tx.Status != TxConfirmed || tx.BlockNumber != 98 || tx.Confirmations != 3 || tx.GasUsed != 21000 {
+		t.Errorf("unexpected state after receipt: %+v", tx)
+	}
+	if tx.ActualFee == nil || tx.ActualFee.Value().Cmp(big.NewInt(21000*1_000_000_000)) != 0 {
+		t.Errorf("unexpected actual fee %v", tx.ActualFee)
+	}
+
+	// reverted
+	receipt = map[string]any{"status": "0x0", "blockNumber": "0x62", "gasUsed": "0x5208", "effectiveGasPrice": "0x3b9aca00"}
+	if err := tx.refreshEvm(n); err != nil {
+		t.Fatalf("refreshEvm failed: %s", err)
+	}
+	if tx.Status != TxFailed {
+		t.Errorf("expected failed, got %s", tx.Status)
+	}
+
+	// nonce consumed by another transaction
+	receipt = nil
+	tx = &Transaction{Hash: "0xabcd", From: "0x1111111111111111111111111111111111111111", Nonce: 4, Watch: true}
+	if err := tx.refreshEvm(n); err != nil {
+		t.Fatalf("refreshEvm failed: %s", err)
+	}
+	if tx.Status != TxDropped || tx.Watch {
+		t.Errorf("expected dropped, got %s", tx.Status)
+	}
+}
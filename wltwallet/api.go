@@ -1,6 +1,7 @@
 package wltwallet
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -22,6 +23,7 @@ func init() {
 		},
 	)
 	pobj.RegisterStatic("Wallet:reshare", apiWalletReshare)
+	pobj.RegisterStatic("Wallet:presign", apiWalletPresign)
 }
 
 func WalletById(e wltintf.Env, id *xuid.XUID) (*Wallet, error) {
@@ -102,8 +104,11 @@ func FirstWallet(e wltintf.Env) (w *Wallet, err error) {
 }
 
 func apiCreateWallet(ctx *apirouter.Context, in struct {
-	Name string
-	Keys []*wltsign.KeyDescription
+	Name      string
+	Keys      []*wltsign.KeyDescription
+	Threshold int    // Threshold+1 keys will be required to sign, defaults to 1
+	Curve     string // secp256k1 (default) or ed25519
+	Timeout   int    // optional, in seconds
 }) (any, error) {
 	e := wltintf.GetEnv(ctx)
 	if e == nil {
@@ -114,17 +119,29 @@ func apiCreateWallet(ctx *apirouter.Context, in struct {
 	if keyCnt < 3 {
 		return nil, fmt.Errorf("need at least 3 keys, got %d", keyCnt)
 	}
+	if in.Threshold < 0 || in.Threshold >= keyCnt {
+		return nil, fmt.Errorf("invalid threshold %d, must be between 1 and %d (0 for the default of 1)", in.Threshold, keyCnt-1)
+	}
 
 	wallet := &Wallet{
-		Id:       xuid.New("wlt"),
-		Name:     in.Name,
-		Created:  time.Now(),
-		Modified: time.Now(),
+		Id:        xuid.New("wlt"),
+		Name:      in.Name,
+		Threshold: in.Threshold,
+		Curve:     in.Curve,
+		Created:   time.Now(),
+		Modified:  time.Now(),
 	}
 
-	err := wallet.initializeWallet(ctx, in.Keys)
+	tctx := context.Context(ctx)
+	if in.Timeout > 0 {
+		var cancel context.CancelFunc
+		tctx, cancel = context.WithTimeout(ctx, time.Duration(in.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	err := wallet.initializeWallet(tctx, in.Keys)
 	if err != nil {
-		return nil, err
+		return nil, apiError(err)
 	}
 
 	if err := wallet.save(e); err != nil {
@@ -135,8 +152,10 @@ func apiCreateWallet(ctx *apirouter.Context, in struct {
 }
 
 func apiWalletReshare(ctx *apirouter.Context, in struct {
-	Old []*wltsign.KeyDescription
-	New []*wltsign.KeyDescription
+	Old          []*wltsign.KeyDescription
+	New          []*wltsign.KeyDescription
+	NewThreshold int // defaults to the current threshold
+	Timeout      int // optional, in seconds
 }) (any, error) {
 	e := wltintf.GetEnv(ctx)
 	if e == nil {
@@ -148,11 +167,16 @@ func apiWalletReshare(ctx *apirouter.Context, in struct {
 		return nil, errors.New("Wallet required")
 	}
 
-	var err error
+	tctx := context.Context(ctx)
+	if in.Timeout > 0 {
+		var cancel context.CancelFunc
+		tctx, cancel = context.WithTimeout(ctx, time.Duration(in.Timeout)*time.Second)
+		defer cancel()
+	}
 
-	err = w.Reshare(ctx, in.Old, in.New)
+	err := w.Reshare(tctx, in.Old, in.New, in.NewThreshold)
 	if err != nil {
-		return nil, err
+		return nil, apiError(err)
 	}
 	err = w.save(e)
 	if err != nil {
@@ -162,6 +186,27 @@ func apiWalletReshare(ctx *apirouter.Context, in struct {
 	return w, nil
 }
 
+func apiWalletPresign(ctx *apirouter.Context, in struct {
+	Keys  []*wltsign.KeyDescription
+	Count int // defaults to the maximum pool size
+}) (any, error) {
+	e := wltintf.GetEnv(ctx)
+	if e == nil {
+		return nil, errors.New("failed to get env")
+	}
+
+	w := apirouter.GetObject[Wallet](ctx, "Wallet")
+	if w == nil {
+		return nil, errors.New("Wallet required")
+	}
+
+	n, err := w.Presign(ctx, e, in.Keys, in.Count)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"count": n}, nil
+}
+
 func newWallet(name string) *Wallet {
 	res := &Wallet{
 		Id:        xuid.New("wlt"),
@@ -0,0 +1,185 @@
+package wltwallet
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/EllipX/libwallet/wltsign"
+	"github.com/KarpelesLab/apirouter"
+	"github.com/ModChain/tss-lib/v2/ecdsa/keygen"
+	eddsakeygen "github.com/ModChain/tss-lib/v2/eddsa/keygen"
+)
+
+// batchParallelism is how many signatures of a batch can run at the same time
+const batchParallelism = 4
+
+// BatchError is returned by SignBatch when some of the digests could not be signed
+type BatchError struct {
+	Errors []error // error for each digest, nil for digests that were signed
+}
+
+func (e *BatchError) Error() string {
+	var first error
+	failed := 0
+	for _, err := range e.Errors {
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			failed++
+		}
+	}
+	return fmt.Sprintf("failed to sign %d of %d digests: %s", failed, len(e.Errors), first)
+}
+
+func (e *BatchError) Unwrap() []error {
+	var res []error
+	for _, err := range e.Errors {
+		if err != nil {
+			res = append(res, err)
+		}
+	}
+	return res
+}
+
+// shareCache holds the decrypted key shares of a signing session, so each key is decrypted only once. Signing
+// parties only read the save data (derivation creates copies), so the same shares can be used concurrently.
+type shareCache struct {
+	lk    sync.Mutex
+	sdata map[string]*keygen.LocalPartySaveData
+	edata map[string]*eddsakeygen.LocalPartySaveData
+}
+
+func newShareCache() *shareCache {
+	return &shareCache{
+		sdata: make(map[string]*keygen.LocalPartySaveData),
+		edata: make(map[string]*eddsakeygen.LocalPartySaveData),
+	}
+}
+
+// decrypt returns the decrypted secp256k1 share of wk, decrypting it directly if c is nil
+func (c *shareCache) decrypt(wk *WalletKey, kd *wltsign.KeyDescription) (*keygen.LocalPartySaveData, error) {
+	if c == nil {
+		return wk.decrypt(kd, keySignPurpose)
+	}
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	if v, ok := c.sdata[wk.Id.String()]; ok {
+		return v, nil
+	}
+	v, err := wk.decrypt(kd, keySignPurpose)
+	if err != nil {
+		return nil, err
+	}
+	c.sdata[wk.Id.String()] = v
+	return v, nil
+}
+
+// decryptEdDSA is the same as decrypt for keys of ed25519 wallets
+func (c *shareCache) decryptEdDSA(wk *WalletKey, kd *wltsign.KeyDescription) (*eddsakeygen.LocalPartySaveData, error) {
+	if c == nil {
+		return wk.decryptEdDSA(kd, keySignPurpose)
+	}
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	if v, ok := c.edata[wk.Id.String()]; ok {
+		return v, nil
+	}
+	v, err := wk.decryptEdDSA(kd, keySignPurpose)
+	if err != nil {
+		return nil, err
+	}
+	c.edata[wk.Id.String()] = v
+	return v, nil
+}
+
+// SignBatch signs several digests with the same keys, decrypting each key only once and running up to
+// batchParallelism signatures at the same time. ils contains the HD derivation offset of each digest, and can be nil
+// to use opts.IL for all digests. Each signature is the same as what Sign would return, except that presignatures
+// are not used as they would require decrypting the keys again for each digest (see Presign).
+//
+// With a RemoteKey, the digests are signed one after the other in order, as the remote party runs one session at
+// a time.
+//
+// If some digests could not be signed, their signature is nil and a *BatchError with the error of each digest is
+// returned. Progress is reported with the number of digests signed.
+func (w *Wallet) SignBatch(rand io.Reader, digests [][]byte, ils []*big.Int, opts *wltsign.Opts) ([][]byte, error) {
+	return w.signBatch(digests, ils, opts, func(digest []byte, aopt *wltsign.Opts, shares *shareCache) ([]byte, error) {
+		return w.safeSign(rand, digest, aopt, shares)
+	})
+}
+
+// signBatch runs sign for each digest with the options of this digest, see SignBatch
+func (w *Wallet) signBatch(digests [][]byte, ils []*big.Int, opts *wltsign.Opts, sign func(digest []byte, aopt *wltsign.Opts, shares *shareCache) ([]byte, error)) ([][]byte, error) {
+	if ils != nil && len(ils) != len(digests) {
+		return nil, fmt.Errorf("got %d derivation offsets for %d digests", len(ils), len(digests))
+	}
+	if w.Threshold == 0 {
+		w.Threshold = 1
+	}
+	if len(opts.Keys) <= w.Threshold {
+		return nil, fmt.Errorf("at least %d keys are required to sign", w.Threshold+1)
+	}
+	parallel := batchParallelism
+	for _, kd := range opts.Keys {
+		p := w.getKey(kd.Id)
+		if p == nil {
+			return nil, fmt.Errorf("could not find key id=%s", kd.Id)
+		}
+		if p.Type == "RemoteKey" {
+			parallel = 1
+		}
+	}
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	shares := newShareCache()
+	sigs := make([][]byte, len(digests))
+	errs := make([]error, len(digests))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	var lk sync.Mutex
+	done := 0
+
+	apirouter.Progress(ctx, map[string]any{"count": len(digests), "done": 0})
+	for i, digest := range digests {
+		aopt := *opts
+		if ils != nil {
+			aopt.IL = ils[i]
+		}
+		// wait for a slot before starting, so digests are started in order
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			sigs[i], errs[i] = sign(digest, &aopt, shares)
+
+			lk.Lock()
+			defer lk.Unlock()
+			done++
+			apirouter.Progress(ctx, map[string]any{"count": len(digests), "done": done})
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return sigs, &BatchError{Errors: errs}
+		}
+	}
+	return sigs, nil
+}
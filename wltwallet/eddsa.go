@@ -0,0 +1,75 @@
+package wltwallet
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+
+	tsscrypto "github.com/ModChain/tss-lib/v2/crypto"
+	eddsakeygen "github.com/ModChain/tss-lib/v2/eddsa/keygen"
+	"github.com/ModChain/tss-lib/v2/tss"
+)
+
+// walletCurve returns the curve of the wallet, defaulting to secp256k1 for wallets created before the curve was
+// stored
+func (w *Wallet) walletCurve() tss.CurveName {
+	if w.Curve == "" {
+		return tss.Secp256k1
+	}
+	return tss.CurveName(w.Curve)
+}
+
+// IsEdDSA returns true if the wallet is an ed25519 wallet, signing with EdDSA
+func (w *Wallet) IsEdDSA() bool {
+	return w.walletCurve() == tss.Ed25519
+}
+
+// Ed25519Pubkey returns the public key of an ed25519 wallet
+func (w *Wallet) Ed25519Pubkey() (ed25519.PublicKey, error) {
+	if !w.IsEdDSA() {
+		return nil, fmt.Errorf("wallet uses curve %s, not ed25519", w.Curve)
+	}
+	dat, err := base64.RawURLEncoding.DecodeString(w.Pubkey)
+	if err != nil {
+		return nil, err
+	}
+	if len(dat) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid ed25519 public key length")
+	}
+	return ed25519.PublicKey(dat), nil
+}
+
+// eddsaPubkey returns the standard 32 bytes encoding of an ed25519 point
+func eddsaPubkey(p *tsscrypto.ECPoint) []byte {
+	return p.ToEd25519PubKey().Serialize()
+}
+
+// eddsaApplyDelta returns a copy of key where the shared secret is increased by delta. Adding the same value to
+// all the shares of a Shamir secret adds it to the secret, so the parties sign for the public key plus delta*G,
+// which is how child keys of ed25519 wallets are derived (see wltacct).
+func eddsaApplyDelta(key eddsakeygen.LocalPartySaveData, delta *big.Int) (eddsakeygen.LocalPartySaveData, error) {
+	curve := tss.Edwards()
+	n := curve.Params().N
+	delta = new(big.Int).Mod(delta, n)
+	deltaG := tsscrypto.ScalarBaseMult(curve, delta)
+
+	res := key
+	res.Xi = new(big.Int).Add(key.Xi, delta)
+	res.Xi.Mod(res.Xi, n)
+	res.BigXj = make([]*tsscrypto.ECPoint, len(key.BigXj))
+	for j, x := range key.BigXj {
+		p, err := x.Add(deltaG)
+		if err != nil {
+			return res, err
+		}
+		res.BigXj[j] = p
+	}
+	pub, err := key.EDDSAPub.Add(deltaG)
+	if err != nil {
+		return res, err
+	}
+	res.EDDSAPub = pub
+	return res, nil
+}
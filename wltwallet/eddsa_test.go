@@ -0,0 +1,81 @@
+package wltwallet
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/EllipX/libwallet/wltsign"
+	"github.com/KarpelesLab/xuid"
+	tsscrypto "github.com/ModChain/tss-lib/v2/crypto"
+	"github.com/ModChain/tss-lib/v2/tss"
+)
+
+func TestEdDSAWallet(t *testing.T) {
+	w := &Wallet{
+		Id:       xuid.New("wlt"),
+		Name:     "Test",
+		Curve:    "ed25519",
+		Created:  time.Now(),
+		Modified: time.Now(),
+	}
+	kd := []*wltsign.KeyDescription{{Type: "Plain"}, {Type: "Plain"}, {Type: "Plain"}}
+	if err := w.initializeWallet(context.Background(), kd); err != nil {
+		t.Fatalf("failed to create wallet: %s", err)
+	}
+	pub, err := w.Ed25519Pubkey()
+	if err != nil {
+		t.Fatalf("failed to get public key: %s", err)
+	}
+	if _, err := w.GetPubkey(); err == nil {
+		t.Errorf("GetPubkey should fail on ed25519 wallets")
+	}
+
+	sign := func(msg []byte, il *big.Int) []byte {
+		opts := &wltsign.Opts{Context: context.Background(), IL: il}
+		for _, k := range w.Keys[:2] {
+			opts.Keys = append(opts.Keys, &wltsign.KeyDescription{Id: k.Id.String()})
+		}
+		sig, err := w.Sign(rand.Reader, msg, opts)
+		if err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+		return sig
+	}
+
+	// ed25519 signs the whole message, including leading zeroes
+	msg := []byte("\x00\x01hello solana")
+	if sig := sign(msg, nil); !ed25519.Verify(pub, msg, sig) {
+		t.Errorf("invalid signature")
+	}
+
+	// signing with an IL signs for pub + IL*G
+	il := big.NewInt(123456789)
+	x, y := tss.Edwards().ScalarBaseMult(il.Bytes())
+	pubPoint, err := tsscrypto.NewECPoint(tss.Edwards(), w.Keys[0].edata.EDDSAPub.X(), w.Keys[0].edata.EDDSAPub.Y())
+	if err != nil {
+		t.Fatalf("invalid public key: %s", err)
+	}
+	child, err := pubPoint.Add(tsscrypto.NewECPointNoCurveCheck(tss.Edwards(), x, y))
+	if err != nil {
+		t.Fatalf("failed to derive: %s", err)
+	}
+	if sig := sign(msg, il); !ed25519.Verify(ed25519.PublicKey(eddsaPubkey(child)), msg, sig) {
+		t.Errorf("invalid signature for derived key")
+	}
+
+	// reshare to 2-of-4, the public key must not change
+	opts := []*wltsign.KeyDescription{{Id: w.Keys[0].Id.String()}, {Id: w.Keys[2].Id.String()}}
+	if err := w.Reshare(context.Background(), opts, []*wltsign.KeyDescription{{Type: "Plain"}, {Type: "Plain"}, {Type: "Plain"}, {Type: "Plain"}}, 0); err != nil {
+		t.Fatalf("failed to reshare: %s", err)
+	}
+	if len(w.Keys) != 4 {
+		t.Errorf("expected 4 keys after reshare, got %d", len(w.Keys))
+	}
+	if sig := sign(msg, nil); !ed25519.Verify(pub, msg, sig) {
+		t.Errorf("invalid signature after reshare")
+	}
+}
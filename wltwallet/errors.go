@@ -1,6 +1,7 @@
 package wltwallet
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/KarpelesLab/apirouter"
@@ -9,4 +10,20 @@ import (
 var (
 	ErrBadPassword = &apirouter.Error{Message: "wrong password", Token: "error_wrong_password", Code: http.StatusForbidden}
 	ErrBadStoreKey = &apirouter.Error{Message: "wrong storeKey, try to restore your wallet from the cloud", Token: "error_wrong_store_key", Code: http.StatusForbidden}
+
+	// errors of TSS operations (key generation, signature, reshare), wrapping the underlying error
+	ErrTSSTimeout     = &apirouter.Error{Message: "TSS operation timed out", Token: "error_tss_timeout", Code: http.StatusGatewayTimeout}
+	ErrTSSPartyFailed = &apirouter.Error{Message: "TSS party failed", Token: "error_tss_party_failed", Code: http.StatusInternalServerError}
+	ErrBadShare       = &apirouter.Error{Message: "key share is invalid or does not belong to this wallet", Token: "error_bad_share", Code: http.StatusInternalServerError}
 )
+
+// apiError returns err as an *apirouter.Error carrying the token of the TSS error it wraps, if any, so the token is
+// included in API responses
+func apiError(err error) error {
+	for _, e := range []*apirouter.Error{ErrTSSTimeout, ErrTSSPartyFailed, ErrBadShare} {
+		if errors.Is(err, e) {
+			return apirouter.NewError(e.Code, e.Token, "%w", err)
+		}
+	}
+	return err
+}
@@ -5,4 +5,5 @@ import "github.com/EllipX/libwallet/wltintf"
 func InitEnv(e wltintf.Env) {
 	e.AutoMigrate(&Wallet{})
 	e.AutoMigrate(&WalletKey{})
+	e.AutoMigrate(&Presignature{})
 }
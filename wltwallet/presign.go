@@ -0,0 +1,316 @@
+package wltwallet
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/EllipX/libwallet/wltintf"
+	"github.com/EllipX/libwallet/wltsign"
+	"github.com/KarpelesLab/xuid"
+	"github.com/ModChain/secp256k1"
+	"github.com/ModChain/tss-lib/v2/tss"
+)
+
+// presignPoolSize is the maximum number of presignatures kept for a given set of keys
+const presignPoolSize = 20
+
+// presignLock ensures a presignature is only taken once
+var presignLock sync.Mutex
+
+// Presignature is message independent ECDSA signing material for a given set of keys of a wallet. With it, a
+// signature only needs a single local computation per key instead of the full TSS signing protocol.
+//
+// Presignatures are not computed by a multi-party protocol: a single process generates the nonce shares of all the
+// keys, see Presign.
+//
+// As in GG18, the nonce is R = k^-1 * G and each key holds additive shares of k and sigma = k * x, so its share of
+// the signature is m * k_i + r * sigma_i. Using a presignature twice reveals the secret of the wallet, so entries are
+// deleted before being used.
+type Presignature struct {
+	Id      *xuid.XUID        `gorm:"primaryKey"`
+	Wallet  *xuid.XUID        `gorm:"index"`
+	Gen     uint64            `gorm:"not null;default:0"` // wallet generation, entries of other generations are invalid
+	KeySet  string            `gorm:"index"`              // sorted ids of the keys, comma separated
+	R       []byte            // compressed nonce point
+	Shares  map[string][]byte `gorm:"serializer:json" json:"-"` // share of each key, sealed for this key
+	Created time.Time         `gorm:"autoCreateTime"`
+}
+
+// presignShare is the share of a presignature held by a single key
+type presignShare struct {
+	K     []byte
+	Sigma []byte
+}
+
+// presignKeySet returns the key set identifier of keys
+func presignKeySet(keys []*wltsign.KeyDescription) string {
+	ids := make([]string, 0, len(keys))
+	for _, kd := range keys {
+		ids = append(ids, kd.Id)
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
+
+// Presign fills the presignature pool of the given set of keys with up to count entries (presignPoolSize if count is
+// zero), and returns the number of presignatures available for this set.
+//
+// This is not a threshold protocol. All the keys must be local (a RemoteKey is refused): their shares of the secret
+// are decrypted in this process, which draws k_i and gamma_i for every key and computes the multiplicative to
+// additive conversions directly, without Paillier based MtA. The resulting shares are sealed for each key, but for
+// the duration of the call this process knows k and sigma, and thus enough to recover the wallet secret from any
+// signature made with the presignature. Only use it when all the signing keys are already trusted to this process.
+func (w *Wallet) Presign(ctx context.Context, e wltintf.Env, keys []*wltsign.KeyDescription, count int) (int, error) {
+	if w.IsEdDSA() {
+		return 0, errors.New("presignatures are only available for secp256k1 wallets")
+	}
+	if len(keys) <= w.Threshold {
+		return 0, fmt.Errorf("at least %d keys are required to sign", w.Threshold+1)
+	}
+	if count <= 0 || count > presignPoolSize {
+		count = presignPoolSize
+	}
+	keySet := presignKeySet(keys)
+
+	var existing []*Presignature
+	if err := e.Find(&existing, map[string]any{"Wallet": w.Id.String(), "Gen": w.Gen, "KeySet": keySet}); err != nil {
+		return 0, err
+	}
+	if len(existing) >= count {
+		return len(existing), nil
+	}
+
+	sids, shares, err := w.presignShares(keys)
+	if err != nil {
+		return 0, err
+	}
+
+	n := len(existing)
+	for ; n < count; n++ {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+		p, err := w.newPresignature(rand.Reader, sids, shares)
+		if err != nil {
+			return n, err
+		}
+		p.KeySet = keySet
+		if err := e.Save(p); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// presignShares returns the sorted signers of keys with their additive shares of the secret
+func (w *Wallet) presignShares(keys []*wltsign.KeyDescription) (tss.SortedPartyIDs, []*secp256k1.ModNScalar, error) {
+	var ids tss.UnSortedPartyIDs
+	kds := make(map[string]*wltsign.KeyDescription)
+	for _, kd := range keys {
+		p := w.getKey(kd.Id)
+		if p == nil {
+			return nil, nil, fmt.Errorf("could not find key id=%s", kd.Id)
+		}
+		if p.Type == "RemoteKey" {
+			return nil, nil, errors.New("presignatures are not available with a RemoteKey")
+		}
+		ids = append(ids, tss.NewPartyID(p.Id.String(), p.Id.String(), new(big.Int).SetBytes(p.Id.UUID[:])))
+		kds[p.Id.String()] = kd
+	}
+	sids := tss.SortPartyIDs(ids)
+	shares := make([]*secp256k1.ModNScalar, len(sids))
+	for i, id := range sids {
+		wi, err := w.additiveShare(nil, kds[id.Id], nil, sids, i)
+		if err != nil {
+			return nil, nil, err
+		}
+		shares[i] = wi
+	}
+	return sids, shares, nil
+}
+
+// newPresignature computes a presignature for the signers sids, whose additive shares of the secret are shares. It
+// plays the part of every signer, see Presign.
+func (w *Wallet) newPresignature(rnd io.Reader, sids tss.SortedPartyIDs, shares []*secp256k1.ModNScalar) (*Presignature, error) {
+	cnt := len(sids)
+	k := make([]secp256k1.ModNScalar, cnt)
+	gamma := make([]secp256k1.ModNScalar, cnt)
+	delta := make([]secp256k1.ModNScalar, cnt)
+	sigma := make([]secp256k1.ModNScalar, cnt)
+	var bigGamma secp256k1.JacobianPoint
+
+	for i := range sids {
+		if err := randomScalar(rnd, &k[i]); err != nil {
+			return nil, err
+		}
+		if err := randomScalar(rnd, &gamma[i]); err != nil {
+			return nil, err
+		}
+		var gi secp256k1.JacobianPoint
+		secp256k1.ScalarBaseMultNonConst(&gamma[i], &gi)
+		secp256k1.AddNonConst(&bigGamma, &gi, &bigGamma)
+
+		delta[i].Mul2(&k[i], &gamma[i])
+		sigma[i].Mul2(&k[i], shares[i])
+	}
+	// pairwise conversions, i gets alpha and j gets beta with alpha + beta = a_i * b_j
+	for i := range sids {
+		for j := range sids {
+			if i == j {
+				continue
+			}
+			for _, v := range []struct {
+				b   *secp256k1.ModNScalar
+				res []secp256k1.ModNScalar
+			}{{&gamma[j], delta}, {shares[j], sigma}} {
+				var alpha, beta secp256k1.ModNScalar
+				if err := randomScalar(rnd, &alpha); err != nil {
+					return nil, err
+				}
+				beta.Mul2(&k[i], v.b).Add(new(secp256k1.ModNScalar).NegateVal(&alpha))
+				v.res[i].Add(&alpha)
+				v.res[j].Add(&beta)
+			}
+		}
+	}
+
+	// R = delta^-1 * Gamma = k^-1 * G
+	var d secp256k1.ModNScalar
+	for i := range delta {
+		d.Add(&delta[i])
+	}
+	if d.IsZero() {
+		return nil, errors.New("invalid presignature")
+	}
+	var r secp256k1.JacobianPoint
+	secp256k1.ScalarMultNonConst(d.InverseNonConst(), &bigGamma, &r)
+	if (r.X.IsZero() && r.Y.IsZero()) || r.Z.IsZero() {
+		return nil, errors.New("invalid presignature")
+	}
+	r.ToAffine()
+
+	res := &Presignature{
+		Id:     xuid.New("wpsg"),
+		Wallet: w.Id,
+		Gen:    w.Gen,
+		R:      secp256k1.NewPublicKey(&r.X, &r.Y).SerializeCompressed(),
+		Shares: make(map[string][]byte),
+	}
+	for i, id := range sids {
+		kb, sb := k[i].Bytes(), sigma[i].Bytes()
+		sealed, err := w.getKey(id.Id).seal(&presignShare{K: kb[:], Sigma: sb[:]})
+		if err != nil {
+			return nil, err
+		}
+		res.Shares[id.Id] = sealed
+	}
+	return res, nil
+}
+
+// takePresignature removes a presignature for keys from the pool and returns it, or nil if there are none
+func (w *Wallet) takePresignature(e wltintf.Env, keys []*wltsign.KeyDescription) (*Presignature, error) {
+	presignLock.Lock()
+	defer presignLock.Unlock()
+
+	var p *Presignature
+	err := e.FirstWhere(&p, map[string]any{"Wallet": w.Id.String(), "Gen": w.Gen, "KeySet": presignKeySet(keys)})
+	if err != nil || p == nil {
+		// no presignature available
+		return nil, nil
+	}
+	if err := e.Delete(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// signPresigned signs digest using a presignature from the pool, if any. It returns a nil signature and no error if
+// no presignature can be used, in which case the full signing protocol is needed.
+func (w *Wallet) signPresigned(ctx context.Context, digest []byte, aopt *wltsign.Opts) ([]byte, error) {
+	if w.IsEdDSA() || len(digest) > 32 {
+		return nil, nil
+	}
+	e := wltintf.GetEnv(ctx)
+	if e == nil {
+		return nil, nil
+	}
+	p, err := w.takePresignature(e, aopt.Keys)
+	if err != nil || p == nil {
+		return nil, err
+	}
+	sig, err := w.signWithPresignature(p, digest, aopt)
+	if err != nil {
+		// the presignature is consumed anyway, use the full protocol
+		log.Printf("[wallet] failed to sign with presignature %s: %s", p.Id, err)
+		return nil, nil
+	}
+	return sig, nil
+}
+
+// signWithPresignature computes the DER encoded ECDSA signature of digest using p, for the key derived with aopt.IL
+func (w *Wallet) signWithPresignature(p *Presignature, digest []byte, aopt *wltsign.Opts) ([]byte, error) {
+	bigR, err := secp256k1.ParsePubKey(p.R)
+	if err != nil {
+		return nil, err
+	}
+	var r, m, il secp256k1.ModNScalar
+	r.SetByteSlice(bigR.X().Bytes())
+	if r.IsZero() {
+		return nil, errors.New("invalid presignature")
+	}
+	m.SetByteSlice(digest)
+	if aopt.IL != nil {
+		il.SetByteSlice(new(big.Int).Mod(aopt.IL, tss.EC().Params().N).Bytes())
+	}
+
+	// each key computes m * k_i + r * (sigma_i + k_i * IL), as sigma for the derived key is k * (x + IL)
+	var s secp256k1.ModNScalar
+	for _, kd := range aopt.Keys {
+		wk := w.getKey(kd.Id)
+		if wk == nil {
+			return nil, fmt.Errorf("could not find key id=%s", kd.Id)
+		}
+		sealed, ok := p.Shares[wk.Id.String()]
+		if !ok {
+			return nil, fmt.Errorf("presignature has no share for key %s", kd.Id)
+		}
+		var share *presignShare
+		if err := wk.openData(sealed, kd, keySignPurpose, &share); err != nil {
+			return nil, err
+		}
+		var ki, sigmai, si, t secp256k1.ModNScalar
+		ki.SetByteSlice(share.K)
+		sigmai.SetByteSlice(share.Sigma)
+		si.Mul2(&m, &ki)
+		t.Mul2(&ki, &il).Add(&sigmai).Mul(&r)
+		s.Add(si.Add(&t))
+	}
+	if s.IsOverHalfOrder() {
+		s.Negate()
+	}
+
+	pub, err := w.GetPubkey()
+	if err != nil {
+		return nil, err
+	}
+	if aopt.IL != nil {
+		pub, err = addScalarBase(pub, aopt.IL)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sig := secp256k1.NewSignature(&r, &s)
+	if !sig.Verify(digest, pub) {
+		return nil, errors.New("presigned signature is invalid")
+	}
+	return sig.Serialize(), nil
+}
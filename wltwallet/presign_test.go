@@ -0,0 +1,77 @@
+package wltwallet
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"math/big"
+	"testing"
+
+	"github.com/EllipX/libwallet/wltsign"
+	"github.com/ModChain/secp256k1"
+)
+
+func TestPresignature(t *testing.T) {
+	w, err := NewWalletForTesting("Test")
+	if err != nil {
+		t.Fatalf("failed to create wallet: %s", err)
+	}
+	pub, err := w.GetPubkey()
+	if err != nil {
+		t.Fatalf("failed to get public key: %s", err)
+	}
+	var keys []*wltsign.KeyDescription
+	for _, k := range []*WalletKey{w.Keys[2], w.Keys[0]} {
+		keys = append(keys, &wltsign.KeyDescription{Id: k.Id.String()})
+	}
+	sids, shares, err := w.presignShares(keys)
+	if err != nil {
+		t.Fatalf("failed to get shares: %s", err)
+	}
+
+	hash := sha256.Sum256([]byte("hello presignature"))
+	il := big.NewInt(424242)
+	child, err := addScalarBase(pub, il)
+	if err != nil {
+		t.Fatalf("failed to derive: %s", err)
+	}
+
+	tests := []struct {
+		name string
+		il   *big.Int
+		pub  *secp256k1.PublicKey
+	}{
+		{"plain", nil, pub},
+		{"derived", il, child},
+	}
+	for _, test := range tests {
+		p, err := w.newPresignature(rand.Reader, sids, shares)
+		if err != nil {
+			t.Fatalf("%s: failed to presign: %s", test.name, err)
+		}
+		opts := &wltsign.Opts{Context: context.Background(), IL: test.il, Keys: keys}
+		der, err := w.signWithPresignature(p, hash[:], opts)
+		if err != nil {
+			t.Errorf("%s: failed to sign: %s", test.name, err)
+			continue
+		}
+		sig, err := secp256k1.ParseDERSignature(der)
+		if err != nil {
+			t.Errorf("%s: failed to parse signature: %s", test.name, err)
+			continue
+		}
+		if !sig.Verify(hash[:], test.pub) {
+			t.Errorf("%s: invalid signature", test.name)
+		}
+	}
+
+	// a presignature is bound to its set of keys
+	p, err := w.newPresignature(rand.Reader, sids, shares)
+	if err != nil {
+		t.Fatalf("failed to presign: %s", err)
+	}
+	opts := &wltsign.Opts{Context: context.Background(), Keys: []*wltsign.KeyDescription{keys[0], {Id: w.Keys[1].Id.String()}}}
+	if _, err := w.signWithPresignature(p, hash[:], opts); err == nil {
+		t.Errorf("expected signing with another set of keys to fail")
+	}
+}
@@ -3,6 +3,7 @@ package wltwallet
 import (
 	"context"
 	"encoding/hex"
+	"fmt"
 
 	"github.com/KarpelesLab/pobj"
 	"github.com/KarpelesLab/rest"
@@ -12,6 +13,7 @@ import (
 func init() {
 	pobj.RegisterStatic("RemoteKey:new", remotekeyNew)
 	pobj.RegisterStatic("RemoteKey:reshare", remotekeyReshare)
+	pobj.RegisterStatic("RemoteKey:sign", remotekeySign)
 	pobj.RegisterStatic("RemoteKey:validate", remotekeyValidate)
 }
 
@@ -26,6 +28,21 @@ type walletSignReshareInit struct {
 	NewPartycount int                `json:"new_partycount"`
 	OldThreshold  int                `json:"old_threshold"`
 	NewThreshold  int                `json:"new_threshold"`
+	Curve         string             `json:"curve"` // secp256k1 (ECDSA) or ed25519 (EdDSA)
+}
+
+// walletSignSignInit is the first packet sent when signing with a RemoteKey. The digest and IL must match the
+// ones given to RemoteKey:sign when the session was created.
+//
+// Remote will use: params := tss.NewParameters(tss.EC(), tss.NewPeerContext(p.Peers), p.Name, p.Partycount, p.Threshold)
+type walletSignSignInit struct {
+	Peers      tss.SortedPartyIDs `json:"peers"`
+	Name       *tss.PartyID       `json:"name"`
+	Partycount int                `json:"partycount"`
+	Threshold  int                `json:"threshold"`
+	Curve      string             `json:"curve"` // secp256k1 (ECDSA) or ed25519 (EdDSA)
+	Hash       string             `json:"hash"`  // digest to sign, hex encoded
+	IL         string             `json:"il"`    // HD derivation offset, hex encoded
 }
 
 type remoteKeyNewResult struct {
@@ -54,15 +71,41 @@ func remoteSign(ctx context.Context, key string, hash []byte, il []byte) (*remot
 	return res, rest.Apply(ctx, "EllipX/WalletSign:sign", "POST", rest.Param{"key": key, "hash": hex.EncodeToString(hash), "il": hex.EncodeToString(il)}, &res)
 }
 
-func remoteReshare(ctx context.Context, key string) (*remoteKeyNewResult, error) {
+// remoteReshare requests a reshare session for key, threshold and count are the parameters of the new set of keys
+func remoteReshare(ctx context.Context, key string, threshold, count int) (*remoteKeyNewResult, error) {
 	var res *remoteKeyNewResult
-	return res, rest.Apply(ctx, "EllipX/WalletSign:reshare", "POST", rest.Param{"key": key, "threshold": 1, "count": 3}, &res)
+	return res, rest.Apply(ctx, "EllipX/WalletSign:reshare", "POST", rest.Param{"key": key, "threshold": threshold, "count": count}, &res)
 }
 
 func remotekeyReshare(ctx context.Context, in struct {
-	Key string `json:"key"`
+	Key       string `json:"key"`
+	Threshold int    `json:"threshold"` // new threshold, defaults to 1
+	Count     int    `json:"count"`     // new number of keys, defaults to 3
+}) (any, error) {
+	if in.Threshold == 0 {
+		in.Threshold = 1
+	}
+	if in.Count == 0 {
+		in.Count = 3
+	}
+	if in.Threshold < 0 || in.Threshold >= in.Count {
+		return nil, fmt.Errorf("invalid threshold %d for %d keys", in.Threshold, in.Count)
+	}
+	res, err := rest.Do(ctx, "EllipX/WalletSign:reshare", "POST", rest.Param{"key": in.Key, "threshold": in.Threshold, "count": in.Count})
+	if err != nil {
+		return nil, err
+	}
+	return res.Data, nil
+}
+
+// remotekeySign requests a signature session for the given digest, which has to be validated with RemoteKey:validate.
+// The resulting RemoteKey is passed as Key of the RemoteKey when signing.
+func remotekeySign(ctx context.Context, in struct {
+	Key  string `json:"key"`
+	Hash string `json:"hash"` // hex encoded
+	IL   string `json:"il"`   // hex encoded
 }) (any, error) {
-	res, err := rest.Do(ctx, "EllipX/WalletSign:reshare", "POST", rest.Param{"key": in.Key, "threshold": 1, "count": 3})
+	res, err := rest.Do(ctx, "EllipX/WalletSign:sign", "POST", rest.Param{"key": in.Key, "hash": in.Hash, "il": in.IL})
 	if err != nil {
 		return nil, err
 	}
@@ -2,6 +2,8 @@ package wltwallet
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"log"
 	"log/slog"
 	"os"
@@ -10,6 +12,7 @@ import (
 
 	"github.com/EllipX/libwallet/wltsign"
 	"github.com/KarpelesLab/xuid"
+	"github.com/ModChain/secp256k1"
 )
 
 func init() {
@@ -59,7 +62,7 @@ func TestRemoteWallet(t *testing.T) {
 	// wallet is *ready*
 
 	// now let's try a reshare
-	remote, err = remoteReshare(context.Background(), remoteV.RemoteKey)
+	remote, err = remoteReshare(context.Background(), remoteV.RemoteKey, 1, 3)
 	if err != nil {
 		t.Errorf("failed to initialize reshare: %s", err)
 	}
@@ -87,8 +90,75 @@ func TestRemoteWallet(t *testing.T) {
 		},
 	}
 
-	err = wallet.Reshare(context.Background(), oldKeys, newKeys)
+	err = wallet.Reshare(context.Background(), oldKeys, newKeys, 0)
 	if err != nil {
 		t.Errorf("failed to reshare remote wallet: %s", err)
 	}
 }
+
+func TestRemoteSign(t *testing.T) {
+	remote, err := remoteNew(context.Background(), testPhone)
+	if err != nil {
+		t.Fatalf("failed to initialize context: %s", err)
+	}
+	remoteV, err := remoteVerify(context.Background(), remote.Session, "000000") // test code
+	if err != nil {
+		t.Fatalf("failed to verify remote context: %s", err)
+	}
+
+	keys := []*wltsign.KeyDescription{
+		&wltsign.KeyDescription{Type: "Plain"},
+		&wltsign.KeyDescription{Type: "Plain"},
+		&wltsign.KeyDescription{
+			Type: "RemoteKey",
+			Key:  remoteV.RemoteKey,
+		},
+	}
+	wallet := &Wallet{
+		Id:       xuid.New("wlt"),
+		Name:     "Test",
+		Created:  time.Now(),
+		Modified: time.Now(),
+	}
+	if err := wallet.initializeWallet(context.Background(), keys); err != nil {
+		t.Fatalf("failed to initialize wallet: %s", err)
+	}
+
+	// sign with one local key and the remote key, as a user who lost their password would
+	hash := sha256.Sum256([]byte("hello remote"))
+	remote, err = remoteSign(context.Background(), remoteV.RemoteKey, hash[:], nil)
+	if err != nil {
+		t.Fatalf("failed to initialize signature: %s", err)
+	}
+	signV, err := remoteVerify(context.Background(), remote.Session, "000000") // test code
+	if err != nil {
+		t.Fatalf("failed to verify signature remote context: %s", err)
+	}
+
+	opts := &wltsign.Opts{Context: context.Background()}
+	for _, k := range wallet.Keys {
+		switch k.Type {
+		case "RemoteKey":
+			opts.Keys = append(opts.Keys, &wltsign.KeyDescription{Id: k.Id.String(), Key: signV.RemoteKey})
+		default:
+			if len(opts.Keys) == 0 {
+				opts.Keys = append(opts.Keys, &wltsign.KeyDescription{Id: k.Id.String()})
+			}
+		}
+	}
+	sig, err := wallet.Sign(rand.Reader, hash[:], opts)
+	if err != nil {
+		t.Fatalf("failed to sign with remote key: %s", err)
+	}
+	sigO, err := secp256k1.ParseDERSignature(sig)
+	if err != nil {
+		t.Fatalf("failed to parse signature: %s", err)
+	}
+	pub, err := wallet.GetPubkey()
+	if err != nil {
+		t.Fatalf("failed to get public key: %s", err)
+	}
+	if !sigO.Verify(hash[:], pub) {
+		t.Errorf("invalid signature")
+	}
+}
@@ -9,32 +9,43 @@ import (
 	"sync"
 	"time"
 
-	"github.com/EllipX/libwallet/wltintf"
 	"github.com/EllipX/libwallet/wltsign"
 	"github.com/KarpelesLab/apirouter"
 	"github.com/KarpelesLab/spotlib"
 	"github.com/ModChain/tss-lib/v2/ecdsa/keygen"
 	"github.com/ModChain/tss-lib/v2/ecdsa/resharing"
+	eddsakeygen "github.com/ModChain/tss-lib/v2/eddsa/keygen"
+	eddsaresharing "github.com/ModChain/tss-lib/v2/eddsa/resharing"
 	"github.com/ModChain/tss-lib/v2/tss"
 )
 
-// Reshare will produce new keys for the given wallet.
-func (w *Wallet) Reshare(ctx context.Context, oldKeys []*wltsign.KeyDescription, newKeys []*wltsign.KeyDescription) error {
+// Reshare will produce new keys for the given wallet. The number of new keys and the threshold can differ from
+// the current ones, if newThreshold is zero the current threshold is kept.
+//
+// The deadline of ctx applies to the whole operation, if ctx has none the reshare protocol itself times out after
+// reshareTimeout.
+func (w *Wallet) Reshare(ctx context.Context, oldKeys []*wltsign.KeyDescription, newKeys []*wltsign.KeyDescription, newThreshold int) error {
 	if w.Threshold == 0 {
 		w.Threshold = 1
 	}
+	if newThreshold == 0 {
+		newThreshold = w.Threshold
+	}
 
 	nk := len(newKeys)
 
 	if nk == 0 {
 		return errors.New("at least one key is required")
 	}
-	if w.Threshold >= nk {
+	if newThreshold >= nk {
 		return errors.New("threshold too high")
 	}
-	if w.Threshold < 0 {
+	if newThreshold < 0 {
 		return errors.New("threshold too low")
 	}
+	if len(oldKeys) <= w.Threshold {
+		return fmt.Errorf("at least %d of the current keys are required to reshare", w.Threshold+1)
+	}
 
 	// prepare old ids
 	var oldids tss.UnSortedPartyIDs
@@ -73,11 +84,19 @@ func (w *Wallet) Reshare(ctx context.Context, oldKeys []*wltsign.KeyDescription,
 
 		k, err := w.createWalletKey(ctx, kInfo.Type)
 		if err != nil {
+			if ctx.Err() != nil {
+				return tssContextError(ctx)
+			}
 			return err
 		}
-		sdata := keygen.NewLocalPartySaveData(len(newWKeys))
-		sdata.LocalPreParams = *k.pre
-		k.sdata = &sdata
+		if w.IsEdDSA() {
+			edata := eddsakeygen.NewLocalPartySaveData(len(newWKeys))
+			k.edata = &edata
+		} else {
+			sdata := keygen.NewLocalPartySaveData(len(newWKeys))
+			sdata.LocalPreParams = *k.pre
+			k.sdata = &sdata
+		}
 		newWKeys[i] = k
 	}
 
@@ -98,24 +117,48 @@ func (w *Wallet) Reshare(ctx context.Context, oldKeys []*wltsign.KeyDescription,
 
 	log.Printf("producing final; oldids = %v newids = %v", oldsids, newsids)
 
-	outCh := make(chan tss.Message, len(newWKeys)+len(oldKeys))
-	defer close(outCh)
+	// The protocol has its own timeout, pre parameters generation only stops if ctx is done
+	ctx, cancel := tssContext(ctx, 0, reshareTimeout)
+	defer cancel()
+
+	outCh := make(chan tss.Message, tssMessageBuffer(len(newWKeys)+len(oldKeys)))
+	errCh := make(chan error, 1)
+	fail := func(err error) {
+		select {
+		case errCh <- err:
+		default:
+		}
+	}
 	var wg sync.WaitGroup
 	wg.Add(len(newWKeys))
 
+	// waitEnd stores the result of a party in p, unless ctx is done first
+	waitEnd := func(p *WalletKey, sdataCh chan *keygen.LocalPartySaveData, edataCh chan *eddsakeygen.LocalPartySaveData) {
+		defer wg.Done()
+		select {
+		case p.sdata = <-sdataCh:
+		case p.edata = <-edataCh:
+		case <-ctx.Done():
+		}
+	}
+
 	for n, p := range newWKeys {
-		endCh := make(chan *keygen.LocalPartySaveData)
-		params := tss.NewReSharingParameters(curve, oldtssctx, newtssctx, newidmap[n], len(oldKeys), w.Threshold, len(newKeys), w.Threshold)
+		params := tss.NewReSharingParameters(curve, oldtssctx, newtssctx, newidmap[n], len(oldKeys), w.Threshold, len(newKeys), newThreshold)
+		if w.IsEdDSA() {
+			endCh := make(chan *eddsakeygen.LocalPartySaveData, 1)
+			m[p.Id.String()] = eddsaresharing.NewLocalParty(params, *p.edata, outCh, endCh)
+			go waitEnd(p, nil, endCh)
+			continue
+		}
+		endCh := make(chan *keygen.LocalPartySaveData, 1)
 		party := resharing.NewLocalParty(params, *p.sdata, outCh, endCh)
 		m[p.Id.String()] = party
-		go func(p *WalletKey) {
-			defer wg.Done()
-			p.sdata = <-endCh
-		}(p)
+		go waitEnd(p, endCh, nil)
 	}
 
 	wg.Add(len(oldKeys))
 
+	done := false
 	for n, kd := range oldKeys {
 		p := w.getKey(kd.Id)
 		if p.Type == "RemoteKey" {
@@ -129,59 +172,60 @@ func (w *Wallet) Reshare(ctx context.Context, oldKeys []*wltsign.KeyDescription,
 				OldPartycount: len(oldKeys),
 				NewPartycount: len(newKeys),
 				OldThreshold:  w.Threshold,
-				NewThreshold:  w.Threshold,
-			}
-			var spot *spotlib.Client
-			if env := wltintf.GetEnv(ctx); env != nil {
-				spot = env.Spot()
-			}
-			if spot == nil {
-				var err error
-				// establish new spot connection (this will only happen in test mode, typically)
-				spot, err = spotlib.New()
-				if err != nil {
-					return err
-				}
+				NewThreshold:  newThreshold,
+				Curve:         w.Curve,
 			}
-			if err := waitOnlineSpot(spot); err != nil {
+			spot, err := spotClient(ctx)
+			if err != nil {
 				return err
 			}
 			log.Printf("initializing remote peer %s with info=%+v", p.Id.String(), info)
 			log.Printf("remote sid = %s", kd.Key)
-			m[p.Id.String()] = &spotParty{info: info, spot: spot, sid: kd.Key, parties: m}
+			sp := &spotParty{ctx: ctx, init: info, name: oldidmap[n], spot: spot, sid: kd.Key, parties: m, fail: fail}
+			defer func() { sp.close(!done) }()
+			m[p.Id.String()] = sp
 			// setup is done, skip the normal decrypt
 			continue
 		}
-		endCh := make(chan *keygen.LocalPartySaveData)
-		params := tss.NewReSharingParameters(curve, oldtssctx, newtssctx, oldidmap[n], len(oldKeys), w.Threshold, len(newKeys), w.Threshold)
+		params := tss.NewReSharingParameters(curve, oldtssctx, newtssctx, oldidmap[n], len(oldKeys), w.Threshold, len(newKeys), newThreshold)
+		if w.IsEdDSA() {
+			edata, err := p.decryptEdDSA(kd, keyResharePurpose)
+			if err != nil {
+				return err
+			}
+			if err := w.checkShare(p, edata); err != nil {
+				return err
+			}
+			endCh := make(chan *eddsakeygen.LocalPartySaveData, 1)
+			m[p.Id.String()] = eddsaresharing.NewLocalParty(params, *edata, outCh, endCh)
+			go waitEnd(p, nil, endCh)
+			continue
+		}
+		endCh := make(chan *keygen.LocalPartySaveData, 1)
 		sdata, err := p.decrypt(kd, keyResharePurpose)
 		if err != nil {
 			return err
 		}
+		if err := w.checkShare(p, sdata); err != nil {
+			return err
+		}
 		party := resharing.NewLocalParty(params, *sdata, outCh, endCh)
 		m[p.Id.String()] = party
-		go func(p *WalletKey) {
-			defer wg.Done()
-			p.sdata = <-endCh
-		}(p)
+		go waitEnd(p, endCh, nil)
 	}
 
-	errCh := make(chan error, 2)
 	var wgStart sync.WaitGroup
 	wgStart.Add(len(m))
 
 	// start all
-	for _, p := range m {
+	for id, p := range m {
 		go func(party tssPartyUpdateOnly) {
 			defer wgStart.Done()
 
 			err := party.Start()
 			if err != nil {
 				log.Printf("failed to start tss party: %s", err)
-				select {
-				case errCh <- err:
-				default:
-				}
+				fail(tssPartyError(id, err))
 			}
 		}(p)
 	}
@@ -193,14 +237,26 @@ func (w *Wallet) Reshare(ctx context.Context, oldKeys []*wltsign.KeyDescription,
 		return err
 	default:
 	}
+	if ctx.Err() != nil {
+		return tssContextError(ctx)
+	}
 
 	// only route messages after everyone has started
-	go tssRouter(ctx, m, outCh)
+	go tssRouter(ctx, m, outCh, fail)
 
-	// wait for all save data to fill
-	wg.Wait()
+	// wait for all save data to fill, a party to fail, or cancellation
+	if err := tssWait(ctx, &wg, errCh); err != nil {
+		return err
+	}
+	for _, p := range newWKeys {
+		if p.saveData() == nil {
+			return fmt.Errorf("%w: reshare failed for key %s", ErrTSSPartyFailed, p.Id)
+		}
+	}
+	done = true
 
 	w.Keys = newWKeys
+	w.Threshold = newThreshold
 
 	// params shouldn't have changed
 	//pk := w.Keys[0].sdata.ECDSAPub.ToSecp256k1PubKey()
@@ -0,0 +1,313 @@
+package wltwallet
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/EllipX/libwallet/wltschnorr"
+	"github.com/EllipX/libwallet/wltsign"
+	"github.com/ModChain/secp256k1"
+	"github.com/ModChain/tss-lib/v2/ecdsa/keygen"
+	"github.com/ModChain/tss-lib/v2/ecdsa/signing"
+	"github.com/ModChain/tss-lib/v2/tss"
+)
+
+// SignTaprootLocal produces a BIP340 Schnorr signature of each digest for the Taproot output key (key path spend,
+// no script tree) of the wallet key derived by ils[i], or by opts.IL if ils is nil. Each key is decrypted only once.
+//
+// This is not threshold signing. Each key computes its own nonce commitments and partial signature as in FROST,
+// but all the keys run in this process, which decrypts every key share of opts.Keys. A RemoteKey is refused as the
+// remote signing service only runs the tss-lib ECDSA and EdDSA protocols. Results and errors are the same as for
+// SignBatch.
+func (w *Wallet) SignTaprootLocal(rand io.Reader, digests [][]byte, ils []*big.Int, opts *wltsign.Opts) ([][]byte, error) {
+	return w.signBatch(digests, ils, opts, func(digest []byte, aopt *wltsign.Opts, shares *shareCache) ([]byte, error) {
+		return w.signSchnorr(rand, digest, aopt, true, shares)
+	})
+}
+
+// schnorrSigner is one of the keys taking part in a BIP340 signature. Its secret values are only used by its own
+// rounds, the other signers and the coordinator (signSchnorr) only see its commitments and partial signature.
+type schnorrSigner struct {
+	i    int                  // index of the signer in the session's commitments
+	w    secp256k1.ModNScalar // additive share of the secret (lagrange coefficient * share)
+	d, e secp256k1.ModNScalar // nonces, cleared once used
+}
+
+// schnorrCommitment is the public output of round 1 of a signer
+type schnorrCommitment struct {
+	id      *tss.PartyID
+	D, E    secp256k1.JacobianPoint // nonce commitments
+	W       secp256k1.JacobianPoint // public additive share, used to check the partial signature
+	partial secp256k1.ModNScalar    // partial signature, set after round 2
+}
+
+// schnorrSession holds the public values of a signature, sent to each signer for round 2
+type schnorrSession struct {
+	digest      []byte
+	px          []byte               // x-only key to sign for
+	factor      secp256k1.ModNScalar // 1 or -1, applied to the shared secret so that it matches px
+	commitments []*schnorrCommitment
+}
+
+// signSchnorr produces a BIP340 signature of digest using the ECDSA key shares of the wallet, which share the same
+// secret, optionally for the Taproot output key. See SignTaprootLocal.
+func (w *Wallet) signSchnorr(rnd io.Reader, digest []byte, aopt *wltsign.Opts, taproot bool, shares *shareCache) ([]byte, error) {
+	if w.IsEdDSA() {
+		return nil, errors.New("schnorr signatures require a secp256k1 wallet")
+	}
+	if len(digest) != 32 {
+		return nil, fmt.Errorf("schnorr signatures require a 32 bytes message, got %d bytes", len(digest))
+	}
+	if rnd == nil {
+		rnd = rand.Reader
+	}
+	if w.Threshold == 0 {
+		w.Threshold = 1
+	}
+	if len(aopt.Keys) <= w.Threshold {
+		return nil, fmt.Errorf("at least %d keys are required to sign", w.Threshold+1)
+	}
+
+	// key to sign for: derived with IL, lifted to an even y, and optionally tweaked for taproot
+	pub, err := w.GetPubkey()
+	if err != nil {
+		return nil, err
+	}
+	if aopt.IL != nil {
+		pub, err = addScalarBase(pub, aopt.IL)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sess := &schnorrSession{digest: digest}
+	var tweak secp256k1.ModNScalar
+	sess.factor.SetInt(1)
+	if !wltschnorr.HasEvenY(pub) {
+		sess.factor.Negate()
+	}
+	if taproot {
+		t, q, err := wltschnorr.TapTweak(pub)
+		if err != nil {
+			return nil, err
+		}
+		tweak.Set(t)
+		if !wltschnorr.HasEvenY(q) {
+			sess.factor.Negate()
+			tweak.Negate()
+		}
+		pub = q
+	}
+	sess.px = wltschnorr.XOnly(pub)
+
+	// prepare signers
+	var ids tss.UnSortedPartyIDs
+	kds := make(map[string]*wltsign.KeyDescription)
+	for _, kd := range aopt.Keys {
+		p := w.getKey(kd.Id)
+		if p == nil {
+			return nil, fmt.Errorf("could not find key id=%s", kd.Id)
+		}
+		if p.Type == "RemoteKey" {
+			return nil, errors.New("schnorr signatures are not available with a RemoteKey")
+		}
+		ids = append(ids, tss.NewPartyID(p.Id.String(), p.Id.String(), new(big.Int).SetBytes(p.Id.UUID[:])))
+		kds[p.Id.String()] = kd
+	}
+	sids := tss.SortPartyIDs(ids)
+
+	// round 1, each signer decrypts its key and commits to its nonces
+	signers := make([]*schnorrSigner, len(sids))
+	for i, id := range sids {
+		s, err := w.newSchnorrSigner(shares, kds[id.Id], aopt.IL, sids, i)
+		if err != nil {
+			return nil, err
+		}
+		c, err := s.round1(rnd)
+		if err != nil {
+			return nil, err
+		}
+		c.id = id
+		signers[i] = s
+		sess.commitments = append(sess.commitments, c)
+	}
+
+	// round 2, each signer returns its partial signature, checked against its commitments
+	rho, r := sess.bind()
+	rx := r.X.Bytes()[:]
+	ch := wltschnorr.Challenge(rx, sess.px, digest)
+	var z secp256k1.ModNScalar
+	for i, s := range signers {
+		c := sess.commitments[i]
+		zi, err := s.round2(sess)
+		if err != nil {
+			return nil, err
+		}
+		if !sess.checkPartial(c, zi, &rho[i], r.Y.IsOdd(), ch) {
+			return nil, fmt.Errorf("invalid partial schnorr signature from key %s", c.id.Id)
+		}
+		z.Add(zi)
+	}
+	// the tweak is not shared, add it once
+	var ct secp256k1.ModNScalar
+	z.Add(ct.Mul2(ch, &tweak))
+
+	zb := z.Bytes()
+	sig := append(append([]byte{}, rx...), zb[:]...)
+	if err := wltschnorr.Verify(sig, digest, sess.px); err != nil {
+		return nil, fmt.Errorf("schnorr signature failed: %w", err)
+	}
+	return sig, nil
+}
+
+// newSchnorrSigner decrypts the key share of the i-th signer of sids
+func (w *Wallet) newSchnorrSigner(shares *shareCache, kd *wltsign.KeyDescription, il *big.Int, sids tss.SortedPartyIDs, i int) (*schnorrSigner, error) {
+	wi, err := w.additiveShare(shares, kd, il, sids, i)
+	if err != nil {
+		return nil, err
+	}
+	return &schnorrSigner{i: i, w: *wi}, nil
+}
+
+// round1 draws the nonces of the signer and returns its commitments
+func (s *schnorrSigner) round1(rnd io.Reader) (*schnorrCommitment, error) {
+	for _, n := range []*secp256k1.ModNScalar{&s.d, &s.e} {
+		if err := randomScalar(rnd, n); err != nil {
+			return nil, err
+		}
+	}
+	c := &schnorrCommitment{}
+	secp256k1.ScalarBaseMultNonConst(&s.d, &c.D)
+	secp256k1.ScalarBaseMultNonConst(&s.e, &c.E)
+	secp256k1.ScalarBaseMultNonConst(&s.w, &c.W)
+	c.D.ToAffine()
+	c.E.ToAffine()
+	c.W.ToAffine()
+	return c, nil
+}
+
+// round2 returns the partial signature z_i = k_i + c * factor * w_i of the signer, with k_i its nonce bound to the
+// session. The nonces are cleared so they cannot be used for another signature.
+func (s *schnorrSigner) round2(sess *schnorrSession) (*secp256k1.ModNScalar, error) {
+	if s.d.IsZero() {
+		return nil, errors.New("schnorr nonces already used")
+	}
+	rho, r := sess.bind()
+	ch := wltschnorr.Challenge(r.X.Bytes()[:], sess.px, sess.digest)
+
+	var k, cw secp256k1.ModNScalar
+	k.Mul2(&s.e, &rho[s.i]).Add(&s.d)
+	if r.Y.IsOdd() {
+		k.Negate()
+	}
+	cw.Mul2(ch, &sess.factor).Mul(&s.w)
+	s.d.Zero()
+	s.e.Zero()
+	return k.Add(&cw), nil
+}
+
+// bind returns the binding factor of each signer and the group nonce R, from the commitments of all the signers
+func (sess *schnorrSession) bind() ([]secp256k1.ModNScalar, *secp256k1.JacobianPoint) {
+	var enc bytes.Buffer
+	for _, c := range sess.commitments {
+		enc.Write(c.id.Key)
+		enc.Write(jacobianBytes(&c.D))
+		enc.Write(jacobianBytes(&c.E))
+	}
+	rho := make([]secp256k1.ModNScalar, len(sess.commitments))
+	var r secp256k1.JacobianPoint
+	for i, c := range sess.commitments {
+		rho[i].SetByteSlice(wltschnorr.TaggedHash("FROST/secp256k1/rho", sess.px, sess.digest, enc.Bytes(), c.id.Key))
+		var re, ri secp256k1.JacobianPoint
+		secp256k1.ScalarMultNonConst(&rho[i], &c.E, &re)
+		secp256k1.AddNonConst(&c.D, &re, &ri)
+		secp256k1.AddNonConst(&r, &ri, &r)
+	}
+	r.ToAffine()
+	return rho, &r
+}
+
+// checkPartial checks z*G = k_i*G + c * factor * W_i for the partial signature z of the signer of commitments c
+func (sess *schnorrSession) checkPartial(c *schnorrCommitment, z, rho *secp256k1.ModNScalar, negateNonce bool, ch *secp256k1.ModNScalar) bool {
+	var zG, re, ri, cw, expect secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(z, &zG)
+
+	secp256k1.ScalarMultNonConst(rho, &c.E, &re)
+	secp256k1.AddNonConst(&c.D, &re, &ri)
+	if negateNonce {
+		ri.ToAffine()
+		ri.Y.Negate(1).Normalize()
+	}
+	var f secp256k1.ModNScalar
+	f.Mul2(ch, &sess.factor)
+	secp256k1.ScalarMultNonConst(&f, &c.W, &cw)
+	secp256k1.AddNonConst(&ri, &cw, &expect)
+
+	zG.ToAffine()
+	expect.ToAffine()
+	return zG.X.Equals(&expect.X) && zG.Y.Equals(&expect.Y)
+}
+
+// additiveShare decrypts the key share of the i-th signer of sids and returns its additive share of the secret
+// (lagrange coefficient * share), with the secret derived by il if not nil. The shares of all signers sum up to the
+// secret, which is used by protocols tss-lib does not implement.
+func (w *Wallet) additiveShare(shares *shareCache, kd *wltsign.KeyDescription, il *big.Int, sids tss.SortedPartyIDs, i int) (*secp256k1.ModNScalar, error) {
+	p := w.getKey(kd.Id)
+	sdata, err := shares.decrypt(p, kd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt key %s for signing: %w", kd.Id, err)
+	}
+	if err := w.checkShare(p, sdata); err != nil {
+		return nil, err
+	}
+	subset := keygen.BuildLocalSaveDataSubset(*sdata, sids)
+	xi := subset.Xi
+	if il != nil {
+		// adding IL to all shares adds it to the secret
+		xi = new(big.Int).Add(xi, il)
+		xi.Mod(xi, tss.EC().Params().N)
+	}
+	wi, _ := signing.PrepareForSigning(tss.EC(), i, len(sids), xi, subset.Ks, subset.BigXj)
+
+	var res secp256k1.ModNScalar
+	res.SetByteSlice(wi.Bytes())
+	return &res, nil
+}
+
+// randomScalar sets n to a random non zero scalar
+func randomScalar(rnd io.Reader, n *secp256k1.ModNScalar) error {
+	var buf [32]byte
+	for {
+		if _, err := io.ReadFull(rnd, buf[:]); err != nil {
+			return err
+		}
+		if overflow := n.SetBytes(&buf); overflow == 0 && !n.IsZero() {
+			return nil
+		}
+	}
+}
+
+// jacobianBytes returns the compressed encoding of an affine point
+func jacobianBytes(p *secp256k1.JacobianPoint) []byte {
+	return secp256k1.NewPublicKey(&p.X, &p.Y).SerializeCompressed()
+}
+
+// addScalarBase returns pub + k*G
+func addScalarBase(pub *secp256k1.PublicKey, k *big.Int) (*secp256k1.PublicKey, error) {
+	var ks secp256k1.ModNScalar
+	if overflow := ks.SetByteSlice(new(big.Int).Mod(k, tss.EC().Params().N).Bytes()); overflow {
+		return nil, errors.New("invalid scalar")
+	}
+	var p, kG, res secp256k1.JacobianPoint
+	pub.AsJacobian(&p)
+	secp256k1.ScalarBaseMultNonConst(&ks, &kG)
+	secp256k1.AddNonConst(&p, &kG, &res)
+	if (res.X.IsZero() && res.Y.IsZero()) || res.Z.IsZero() {
+		return nil, errors.New("derived key is infinity")
+	}
+	res.ToAffine()
+	return secp256k1.NewPublicKey(&res.X, &res.Y), nil
+}
@@ -0,0 +1,97 @@
+package wltwallet
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"math/big"
+	"testing"
+
+	"github.com/EllipX/libwallet/wltschnorr"
+	"github.com/EllipX/libwallet/wltsign"
+	"github.com/ModChain/tss-lib/v2/tss"
+)
+
+func TestSignSchnorr(t *testing.T) {
+	w, err := NewWalletForTesting("Test")
+	if err != nil {
+		t.Fatalf("failed to create wallet: %s", err)
+	}
+	pub, err := w.GetPubkey()
+	if err != nil {
+		t.Fatalf("failed to get public key: %s", err)
+	}
+	hash := sha256.Sum256([]byte("hello taproot"))
+	il := big.NewInt(987654321)
+	child, err := addScalarBase(pub, il)
+	if err != nil {
+		t.Fatalf("failed to derive: %s", err)
+	}
+	_, output, err := wltschnorr.TapTweak(child)
+	if err != nil {
+		t.Fatalf("failed to tweak: %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		il      *big.Int
+		taproot bool
+		px      []byte
+	}{
+		{"plain", nil, false, wltschnorr.XOnly(pub)},
+		{"derived", il, false, wltschnorr.XOnly(child)},
+		{"taproot", il, true, wltschnorr.XOnly(output)},
+	}
+	for _, test := range tests {
+		opts := &wltsign.Opts{Context: context.Background(), IL: test.il}
+		for _, k := range []*WalletKey{w.Keys[0], w.Keys[2]} {
+			opts.Keys = append(opts.Keys, &wltsign.KeyDescription{Id: k.Id.String()})
+		}
+		var sig []byte
+		if test.taproot {
+			var sigs [][]byte
+			sigs, err = w.SignTaprootLocal(rand.Reader, [][]byte{hash[:]}, nil, opts)
+			if err == nil {
+				sig = sigs[0]
+			}
+		} else {
+			sig, err = w.signSchnorr(rand.Reader, hash[:], opts, false, nil)
+		}
+		if err != nil {
+			t.Errorf("%s: failed to sign: %s", test.name, err)
+			continue
+		}
+		if err := wltschnorr.Verify(sig, hash[:], test.px); err != nil {
+			t.Errorf("%s: invalid signature: %s", test.name, err)
+		}
+	}
+
+	opts := &wltsign.Opts{Context: context.Background()}
+	for _, k := range w.Keys[:2] {
+		opts.Keys = append(opts.Keys, &wltsign.KeyDescription{Id: k.Id.String()})
+	}
+	if _, err := w.signSchnorr(rand.Reader, []byte("not a hash"), opts, false, nil); err == nil {
+		t.Errorf("expected schnorr signature of a non 32 bytes message to fail")
+	}
+
+}
+
+func TestSchnorrNonceReuse(t *testing.T) {
+	s := &schnorrSigner{}
+	s.w.SetInt(42)
+	c, err := s.round1(rand.Reader)
+	if err != nil {
+		t.Fatalf("round 1 failed: %s", err)
+	}
+	c.id = tss.NewPartyID("a", "a", big.NewInt(1))
+	hash := sha256.Sum256([]byte("hello taproot"))
+	sess := &schnorrSession{digest: hash[:], px: make([]byte, 32), commitments: []*schnorrCommitment{c}}
+	sess.factor.SetInt(1)
+
+	if _, err := s.round2(sess); err != nil {
+		t.Fatalf("round 2 failed: %s", err)
+	}
+	if _, err := s.round2(sess); err == nil {
+		t.Errorf("expected nonces to be usable only once")
+	}
+}
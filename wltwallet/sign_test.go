@@ -0,0 +1,167 @@
+package wltwallet
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/EllipX/libwallet/wltsign"
+	"github.com/KarpelesLab/xuid"
+	"github.com/ModChain/secp256k1"
+	tsscrypto "github.com/ModChain/tss-lib/v2/crypto"
+	"github.com/ModChain/tss-lib/v2/tss"
+)
+
+func TestSignCancel(t *testing.T) {
+	w, err := NewWalletForTesting("Test")
+	if err != nil {
+		t.Fatalf("failed to create wallet: %s", err)
+	}
+	hash := sha256.Sum256([]byte("hello world"))
+
+	opts := &wltsign.Opts{Context: context.Background()}
+	for _, k := range w.Keys[:1] {
+		opts.Keys = append(opts.Keys, &wltsign.KeyDescription{Id: k.Id.String()})
+	}
+	if _, err := w.Sign(rand.Reader, hash[:], opts); err == nil {
+		t.Errorf("expected signing with less than threshold+1 keys to fail")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	opts = &wltsign.Opts{Context: ctx}
+	for _, k := range w.Keys[:2] {
+		opts.Keys = append(opts.Keys, &wltsign.KeyDescription{Id: k.Id.String()})
+	}
+	if _, err := w.Sign(rand.Reader, hash[:], opts); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	opts.Context = context.Background()
+	opts.Timeout = time.Millisecond
+	if _, err := w.Sign(rand.Reader, hash[:], opts); !errors.Is(err, ErrTSSTimeout) {
+		t.Errorf("expected ErrTSSTimeout, got %v", err)
+	}
+
+	opts.Timeout = 0
+	pubkey := w.Pubkey
+	w.Pubkey = "invalid"
+	if _, err := w.Sign(rand.Reader, hash[:], opts); !errors.Is(err, ErrBadShare) {
+		t.Errorf("expected ErrBadShare, got %v", err)
+	}
+	w.Pubkey = pubkey
+
+	if _, err := w.Sign(rand.Reader, hash[:], opts); err != nil {
+		t.Errorf("failed to sign: %s", err)
+	}
+}
+
+func TestSignBatch(t *testing.T) {
+	w, err := NewWalletForTesting("Test")
+	if err != nil {
+		t.Fatalf("failed to create wallet: %s", err)
+	}
+	pub, err := w.GetPubkey()
+	if err != nil {
+		t.Fatalf("failed to get public key: %s", err)
+	}
+	opts := &wltsign.Opts{Context: context.Background()}
+	for _, k := range w.Keys[1:] {
+		opts.Keys = append(opts.Keys, &wltsign.KeyDescription{Id: k.Id.String()})
+	}
+
+	var digests [][]byte
+	var ils []*big.Int
+	for i := 0; i < 5; i++ {
+		hash := sha256.Sum256([]byte{byte(i)})
+		digests = append(digests, hash[:])
+		ils = append(ils, big.NewInt(int64(i*1000)))
+	}
+	ils[0] = nil
+	sigs, err := w.SignBatch(rand.Reader, digests, ils, opts)
+	if err != nil {
+		t.Fatalf("failed to sign batch: %s", err)
+	}
+	for i, der := range sigs {
+		key := pub
+		if ils[i] != nil {
+			key, _ = addScalarBase(pub, ils[i])
+		}
+		sig, err := secp256k1.ParseDERSignature(der)
+		if err != nil || !sig.Verify(digests[i], key) {
+			t.Errorf("invalid signature for digest %d", i)
+		}
+	}
+
+	// schnorr signatures require 32 bytes digests, only the invalid digest should fail
+	digests[2] = []byte("too short")
+	sigs, err = w.SignTaprootLocal(rand.Reader, digests, nil, opts)
+	var berr *BatchError
+	if !errors.As(err, &berr) {
+		t.Fatalf("expected a batch error, got %v", err)
+	}
+	for i := range digests {
+		if (berr.Errors[i] != nil) != (i == 2) || (sigs[i] == nil) != (i == 2) {
+			t.Errorf("unexpected result for digest %d: %v", i, berr.Errors[i])
+		}
+	}
+}
+
+func TestSignBatchEdDSA(t *testing.T) {
+	w := &Wallet{
+		Id:       xuid.New("wlt"),
+		Name:     "Test",
+		Curve:    "ed25519",
+		Created:  time.Now(),
+		Modified: time.Now(),
+	}
+	kd := []*wltsign.KeyDescription{{Type: "Plain"}, {Type: "Plain"}, {Type: "Plain"}}
+	if err := w.initializeWallet(context.Background(), kd); err != nil {
+		t.Fatalf("failed to create wallet: %s", err)
+	}
+	opts := &wltsign.Opts{Context: context.Background()}
+	for _, k := range w.Keys[:2] {
+		opts.Keys = append(opts.Keys, &wltsign.KeyDescription{Id: k.Id.String()})
+	}
+	pubPoint, err := tsscrypto.NewECPoint(tss.Edwards(), w.Keys[0].edata.EDDSAPub.X(), w.Keys[0].edata.EDDSAPub.Y())
+	if err != nil {
+		t.Fatalf("invalid public key: %s", err)
+	}
+
+	// derived and non derived signatures are interleaved, as they share the decrypted keys
+	msgs := [][]byte{[]byte("first"), []byte("second"), []byte("third"), []byte("fourth")}
+	ils := []*big.Int{big.NewInt(123456789), nil, big.NewInt(42), nil}
+	sigs, err := w.SignBatch(rand.Reader, msgs, ils, opts)
+	if err != nil {
+		t.Fatalf("failed to sign batch: %s", err)
+	}
+	for i, sig := range sigs {
+		key := pubPoint
+		if ils[i] != nil {
+			x, y := tss.Edwards().ScalarBaseMult(ils[i].Bytes())
+			key, err = pubPoint.Add(tsscrypto.NewECPointNoCurveCheck(tss.Edwards(), x, y))
+			if err != nil {
+				t.Fatalf("failed to derive: %s", err)
+			}
+		}
+		if !ed25519.Verify(ed25519.PublicKey(eddsaPubkey(key)), msgs[i], sig) {
+			t.Errorf("invalid signature for message %d", i)
+		}
+	}
+}
+
+func TestSignBatchThreshold(t *testing.T) {
+	// threshold 0 means 1, two keys are required
+	w := &Wallet{Id: xuid.New("wlt")}
+	opts := &wltsign.Opts{Context: context.Background(), Keys: []*wltsign.KeyDescription{{Id: "wkey-test"}}}
+	_, err := w.SignBatch(rand.Reader, [][]byte{make([]byte, 32)}, nil, opts)
+	var berr *BatchError
+	if err == nil || errors.As(err, &berr) {
+		t.Errorf("expected signing with a single key to be refused, got %v", err)
+	}
+}
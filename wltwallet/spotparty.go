@@ -10,17 +10,22 @@ import (
 	"sync"
 	"time"
 
+	"github.com/EllipX/libwallet/wltintf"
 	"github.com/KarpelesLab/spotlib"
 	"github.com/KarpelesLab/spotproto"
 	"github.com/ModChain/tss-lib/v2/tss"
 )
 
+// spotParty is a tss party running on a remote peer (RemoteKey), reached through spot using the walletsign endpoint
 type spotParty struct {
-	info    *walletSignReshareInit
+	ctx     context.Context
+	init    any          // parameters of the session sent to the remote: *walletSignReshareInit or *walletSignSignInit
+	name    *tss.PartyID // party id of the remote
 	spot    *spotlib.Client
 	sid     string
 	peer    string
 	parties map[string]tssPartyUpdateOnly
+	fail    func(error) // called when a local party fails to process a message of the remote, if not nil
 	stOnce  sync.Once
 	stErr   error
 }
@@ -30,7 +35,7 @@ func (s *spotParty) Start() error {
 		// setup handler
 		s.spot.SetHandler(s.sid, s.messageHandler)
 
-		peerCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		peerCtx, cancel := context.WithTimeout(s.ctx, 15*time.Second)
 		defer cancel()
 
 		// locate a live peer
@@ -43,13 +48,15 @@ func (s *spotParty) Start() error {
 		s.peer = peer
 
 		// initalize session
-		buf, err := json.Marshal(s.info)
+		buf, err := json.Marshal(s.init)
 		if err != nil {
 			s.stErr = err
 			return
 		}
 
-		_, err = s.spot.QueryTimeout(15*time.Second, peer+"/walletsign/"+s.sid+"/init", buf)
+		initCtx, cancel2 := context.WithTimeout(s.ctx, 15*time.Second)
+		defer cancel2()
+		_, err = s.spot.Query(initCtx, peer+"/walletsign/"+s.sid+"/init", buf)
 		if err != nil {
 			s.stErr = fmt.Errorf("failed to init remote: %w", err)
 			return
@@ -72,11 +79,46 @@ func (s *spotParty) UpdateFromBytes(wireBytes []byte, from *tss.PartyID, isBroad
 
 	log.Printf("sending message from %s to %s broadcast=%v", sender, tgt, isBroadcast)
 
-	err := s.spot.SendToWithFrom(context.Background(), tgt, wireBytes, sender)
+	err := s.spot.SendToWithFrom(s.ctx, tgt, wireBytes, sender)
 
 	return true, err
 }
 
+// close stops handling messages of the session. If abort is true, the remote is told the session was
+// cancelled so it does not keep waiting for our messages.
+func (s *spotParty) close(abort bool) {
+	s.spot.SetHandler(s.sid, nil)
+	if !abort || s.peer == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.spot.SendToWithFrom(ctx, s.peer+"/walletsign/"+s.sid+"/cancel", []byte("cancel"), "/"+s.sid); err != nil {
+		log.Printf("failed to cancel remote session: %s", err)
+	}
+}
+
+// spotClient returns the spot client of the env in ctx, or a new client if there is none (typically in tests),
+// once it is online
+func spotClient(ctx context.Context) (*spotlib.Client, error) {
+	var spot *spotlib.Client
+	if env := wltintf.GetEnv(ctx); env != nil {
+		spot = env.Spot()
+	}
+	if spot == nil {
+		var err error
+		// establish new spot connection (this will only happen in test mode, typically)
+		spot, err = spotlib.New()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := waitOnlineSpot(spot); err != nil {
+		return nil, err
+	}
+	return spot, nil
+}
+
 func (s *spotParty) messageHandler(msg *spotproto.Message) ([]byte, error) {
 	if !msg.IsEncrypted() {
 		// only process messages that were end to end encrypted
@@ -106,23 +148,25 @@ func (s *spotParty) messageHandler(msg *spotproto.Message) ([]byte, error) {
 	dstParty := splitRecipient[2]
 	if dstParty == "all" {
 		log.Printf("*** broadcast msg")
-		for _, p := range s.parties {
-			ok, err := p.UpdateFromBytes(msg.Body, s.info.Name, true)
+		for id, p := range s.parties {
+			ok, err := p.UpdateFromBytes(msg.Body, s.name, true)
 			if err == nil && !ok {
 				err = errors.New("false returned")
 			}
 			if err != nil {
 				log.Printf("failed to update peer: %s", err)
+				s.partyFailed(id, err)
 			}
 		}
 	} else {
 		if p, ok := s.parties[dstParty]; ok {
-			ok, err := p.UpdateFromBytes(msg.Body, s.info.Name, isBroadcast)
+			ok, err := p.UpdateFromBytes(msg.Body, s.name, isBroadcast)
 			if err == nil && !ok {
 				err = errors.New("false returned")
 			}
 			if err != nil {
 				log.Printf("failed to update peer: %s", err)
+				s.partyFailed(dstParty, err)
 			}
 			return nil, nil
 		}
@@ -131,3 +175,10 @@ func (s *spotParty) messageHandler(msg *spotproto.Message) ([]byte, error) {
 
 	return nil, nil
 }
+
+// partyFailed reports the failure of a local party to process a message of the remote
+func (s *spotParty) partyFailed(id string, err error) {
+	if s.fail != nil {
+		s.fail(tssPartyError(id, err))
+	}
+}
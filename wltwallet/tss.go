@@ -4,7 +4,11 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"sync"
+	"time"
 
 	"github.com/EllipX/libwallet/wltcrash"
 	"github.com/KarpelesLab/pobj"
@@ -27,11 +31,87 @@ func tssGenParams(ctx context.Context) (any, error) {
 	return base64.RawURLEncoding.EncodeToString(res), nil
 }
 
-func tssRouter(ctx context.Context, parties map[string]tssPartyUpdateOnly, outCh chan tss.Message) {
+// tssMessageBuffer returns the size of the outgoing message channel of a TSS operation with n parties. It is large
+// enough for all the messages of the operation, so parties never block on sending even after the operation was
+// cancelled and the router stopped. The channel is never closed as parties may still be running.
+func tssMessageBuffer(n int) int {
+	// no protocol has more than 10 rounds, with at most one message per peer per round
+	return 10 * n * n
+}
+
+// tssContext returns the context of a TSS operation. The timeout applies if not zero, else the deadline of ctx if it
+// has one, or the default timeout def.
+func tssContext(ctx context.Context, timeout, def time.Duration) (context.Context, context.CancelFunc) {
+	if timeout == 0 {
+		if _, ok := ctx.Deadline(); ok {
+			return context.WithCancel(ctx)
+		}
+		timeout = def
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// tssContextError returns the error of a TSS operation whose context is done
+func tssContextError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrTSSTimeout, ctx.Err())
+	}
+	return ctx.Err()
+}
+
+// tssWait waits for wg, returning the first error of errCh or the context error if ctx is done first
+func tssWait(ctx context.Context, wg *sync.WaitGroup, errCh <-chan error) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		// parties stop waiting when ctx is done
+		if ctx.Err() != nil {
+			return tssContextError(ctx)
+		}
+		select {
+		case err := <-errCh:
+			return err
+		default:
+			return nil
+		}
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return tssContextError(ctx)
+	}
+}
+
+// tssPartyError returns the error of a failed party
+func tssPartyError(id string, err error) error {
+	return fmt.Errorf("%w: key %s: %w", ErrTSSPartyFailed, id, err)
+}
+
+// tssRouter delivers the messages of outCh to parties until ctx is done. Parties failing to process a message are
+// reported to fail, which may be called several times.
+func tssRouter(ctx context.Context, parties map[string]tssPartyUpdateOnly, outCh chan tss.Message, fail func(error)) {
 	defer func() {
 		wltcrash.Log(ctx, recover(), "tss router")
 	}()
-	for msg := range outCh {
+	update := func(id string, p tssPartyUpdateOnly, data []byte, from *tss.PartyID, isBroadcast bool) {
+		ok, err := p.UpdateFromBytes(data, from, isBroadcast)
+		if err != nil {
+			log.Printf("update from bytes error: %s", err)
+			fail(tssPartyError(id, err))
+		} else if !ok {
+			log.Printf("update from bytes not ok")
+		}
+	}
+	for {
+		var msg tss.Message
+		select {
+		case <-ctx.Done():
+			return
+		case msg = <-outCh:
+		}
 		log.Printf("msg: %s", msg)
 		data, routing, err := msg.WireBytes()
 		if err != nil {
@@ -40,11 +120,8 @@ func tssRouter(ctx context.Context, parties map[string]tssPartyUpdateOnly, outCh
 		}
 		if routing.To == nil {
 			// when `nil` the message should be broadcast to all parties
-			for _, p := range parties {
-				go func(p tssPartyUpdateOnly) {
-					p.UpdateFromBytes(data, routing.From, true)
-					//log.Printf("%s waiting for %v", p, p.WaitingFor())
-				}(p)
+			for id, p := range parties {
+				go update(id, p, data, routing.From, true)
 			}
 		} else {
 			for _, to := range routing.To {
@@ -53,15 +130,7 @@ func tssRouter(ctx context.Context, parties map[string]tssPartyUpdateOnly, outCh
 					log.Printf("tss: id not found: %s", to.Id)
 					continue
 				}
-				go func(p tssPartyUpdateOnly) {
-					ok, err := p.UpdateFromBytes(data, msg.GetFrom(), msg.IsBroadcast())
-					if err != nil {
-						log.Printf("update from bytes error: %s", err)
-					} else if !ok {
-						log.Printf("update from bytes not ok")
-					}
-					//log.Printf("%s waiting for %v", p, p.WaitingFor())
-				}(p)
+				go update(to.Id, p, data, msg.GetFrom(), msg.IsBroadcast())
 			}
 		}
 	}
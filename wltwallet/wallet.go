@@ -5,6 +5,7 @@ import (
 	"crypto"
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -22,16 +23,29 @@ import (
 	"github.com/ModChain/tss-lib/v2/common"
 	"github.com/ModChain/tss-lib/v2/ecdsa/keygen"
 	"github.com/ModChain/tss-lib/v2/ecdsa/signing"
+	eddsakeygen "github.com/ModChain/tss-lib/v2/eddsa/keygen"
+	eddsasigning "github.com/ModChain/tss-lib/v2/eddsa/signing"
 	"github.com/ModChain/tss-lib/v2/tss"
 )
 
+const (
+	// signTimeout is how long a signature using only local keys can take
+	signTimeout = 15 * time.Second
+	// remoteSignTimeout is how long a signature involving a RemoteKey can take, including reaching the remote
+	remoteSignTimeout = 2 * time.Minute
+	// keygenTimeout is how long the key generation protocol can take, once pre parameters are ready
+	keygenTimeout = 2 * time.Minute
+	// reshareTimeout is how long the reshare protocol can take, once pre parameters are ready
+	reshareTimeout = 2 * time.Minute
+)
+
 // Wallet represents a multi-signature wallet with threshold signature scheme (TSS) support
 // It can contain multiple keys with a configurable threshold for signatures
 type Wallet struct {
 	Id        *xuid.XUID   `gorm:"primaryKey"` // Unique identifier for the wallet
 	Name      string       // User-friendly name
 	Curve     string       // Elliptic curve used (e.g., "secp256k1")
-	Threshold int          // Minimum number of keys required for signing
+	Threshold int          // TSS threshold, Threshold+1 keys are required for signing
 	Keys      []*WalletKey `gorm:"-:all"`              // Associated keys (not stored in database)
 	Gen       uint64       `gorm:"not null;default:0"` // incremented on reshare
 	Pubkey    string       // Base64 encoded public key
@@ -54,6 +68,13 @@ func (w *Wallet) save(e wltintf.Env) error {
 		}
 	}
 
+	if gen != w.Gen {
+		// presignatures of the previous generation cannot be used anymore
+		if err := e.DeleteWhere(&Presignature{}, map[string]any{"Wallet": w.Id.String(), "Gen": w.Gen}); err != nil {
+			return fmt.Errorf("failed to delete presignatures of wallet %s: %w", w.Id, err)
+		}
+	}
+
 	// update w.Gen to make sure we load those keys in the future
 	w.Gen = gen
 
@@ -94,7 +115,7 @@ func (w *Wallet) ApiUpdate(ctx *apirouter.Context) error {
 }
 
 // ApiDelete handles API requests to delete a wallet
-// Emits a "wallet:deleted" event and removes the wallet and its keys from the database
+// Emits a "wallet:deleted" event and removes the wallet, its keys and presignatures from the database
 // Returns error with context if the deletion fails
 func (w *Wallet) ApiDelete(ctx *apirouter.Context) error {
 	e := wltintf.GetEnv(ctx)
@@ -108,6 +129,9 @@ func (w *Wallet) ApiDelete(ctx *apirouter.Context) error {
 	if err := e.DeleteWhere(&WalletKey{}, map[string]any{"Wallet": w.Id.String()}); err != nil {
 		return fmt.Errorf("failed to delete wallet keys for wallet %s: %w", w.Id, err)
 	}
+	if err := e.DeleteWhere(&Presignature{}, map[string]any{"Wallet": w.Id.String()}); err != nil {
+		return fmt.Errorf("failed to delete presignatures of wallet %s: %w", w.Id, err)
+	}
 
 	if err := e.Delete(w); err != nil {
 		return fmt.Errorf("failed to delete wallet %s: %w", w.Id, err)
@@ -121,6 +145,8 @@ func (w *Wallet) ApiDelete(ctx *apirouter.Context) error {
 //   - ctx: context for progress reporting and cancellation
 //   - kDesc: array of key descriptions for wallet creation
 //
+// The deadline of ctx applies to the whole operation, if ctx has none the key generation protocol itself times out
+// after keygenTimeout.
 // Returns any error encountered during wallet initialization
 func (w *Wallet) initializeWallet(ctx context.Context, kDesc []*wltsign.KeyDescription) error {
 	if w.Threshold == 0 {
@@ -138,6 +164,12 @@ func (w *Wallet) initializeWallet(ctx context.Context, kDesc []*wltsign.KeyDescr
 	if w.Threshold < 0 {
 		return errors.New("threshold too low")
 	}
+	switch w.walletCurve() {
+	case tss.Secp256k1, tss.Ed25519:
+		w.Curve = string(w.walletCurve())
+	default:
+		return fmt.Errorf("unsupported curve %s", w.Curve)
+	}
 
 	// Create wallet keys for each key description
 	for i, kInfo := range kDesc {
@@ -152,6 +184,9 @@ func (w *Wallet) initializeWallet(ctx context.Context, kDesc []*wltsign.KeyDescr
 
 		k, err := w.createWalletKey(ctx, kInfo.Type)
 		if err != nil {
+			if ctx.Err() != nil {
+				return tssContextError(ctx)
+			}
 			return fmt.Errorf("failed to create wallet key of type %s (key %d/%d): %w", kInfo.Type, i+1, nk, err)
 		}
 		w.Keys[i] = k
@@ -174,34 +209,63 @@ func (w *Wallet) initializeWallet(ctx context.Context, kDesc []*wltsign.KeyDescr
 	}
 	sids := tss.SortPartyIDs(ids)
 
-	curve := tss.EC()
+	curve, _ := tss.GetCurveByName(w.walletCurve())
 	tssctx := tss.NewPeerContext(sids)
 
+	// The protocol has its own timeout, pre parameters generation only stops if ctx is done
+	ctx, cancel := tssContext(ctx, 0, keygenTimeout)
+	defer cancel()
+
 	// Create channels for TSS communication
-	outCh := make(chan tss.Message)
-	defer close(outCh)
+	outCh := make(chan tss.Message, tssMessageBuffer(nk))
+	errCh := make(chan error, 1)
+	fail := func(err error) {
+		select {
+		case errCh <- err:
+		default:
+		}
+	}
 	var wg sync.WaitGroup
 	wg.Add(len(w.Keys))
 
 	// Start TSS key generation for each party
 	for n, p := range w.Keys {
-		endCh := make(chan *keygen.LocalPartySaveData)
 		params := tss.NewParameters(curve, tssctx, idmap[n], nk, w.Threshold)
-		party := keygen.NewLocalParty(params, outCh, endCh, *p.pre)
+		var party tss.Party
+		var wait func()
+		if w.IsEdDSA() {
+			endCh := make(chan *eddsakeygen.LocalPartySaveData, 1)
+			party = eddsakeygen.NewLocalParty(params, outCh, endCh)
+			wait = func() {
+				select {
+				case p.edata = <-endCh:
+				case <-ctx.Done():
+				}
+			}
+		} else {
+			endCh := make(chan *keygen.LocalPartySaveData, 1)
+			party = keygen.NewLocalParty(params, outCh, endCh, *p.pre)
+			wait = func() {
+				select {
+				case p.sdata = <-endCh:
+				case <-ctx.Done():
+				}
+			}
+		}
 		m[p.Id.String()] = party
-		go func(p *WalletKey) {
+		go func() {
 			defer wg.Done()
 			err := party.Start()
 			if err != nil {
 				log.Printf("err = %s", err)
 				// Ensure we don't block on channel read if party failed to start
-				p.sdata = nil
+				fail(tssPartyError(p.Id.String(), err))
 				return
 			}
-			p.sdata = <-endCh
-		}(p)
+			wait()
+		}()
 	}
-	go tssRouter(ctx, m, outCh)
+	go tssRouter(ctx, m, outCh, fail)
 
 	// Generate random chaincode for HD wallet derivation
 	chaincode := make([]byte, 32)
@@ -210,14 +274,25 @@ func (w *Wallet) initializeWallet(ctx context.Context, kDesc []*wltsign.KeyDescr
 		return fmt.Errorf("failed to generate secure chaincode for wallet: %w", err)
 	}
 
-	// Wait for all key generation to complete
-	wg.Wait()
+	// Wait for all key generation to complete, a party to fail, or cancellation
+	if err := tssWait(ctx, &wg, errCh); err != nil {
+		return err
+	}
 
 	// Set wallet properties from generated keys
-	pk := w.Keys[0].sdata.ECDSAPub.ToSecp256k1PubKey()
-	w.Pubkey = base64.RawURLEncoding.EncodeToString(pk.SerializeCompressed())
+	if w.IsEdDSA() {
+		if w.Keys[0].edata == nil {
+			return fmt.Errorf("%w: key generation failed", ErrTSSPartyFailed)
+		}
+		w.Pubkey = base64.RawURLEncoding.EncodeToString(eddsaPubkey(w.Keys[0].edata.EDDSAPub))
+	} else {
+		if w.Keys[0].sdata == nil {
+			return fmt.Errorf("%w: key generation failed", ErrTSSPartyFailed)
+		}
+		pk := w.Keys[0].sdata.ECDSAPub.ToSecp256k1PubKey()
+		w.Pubkey = base64.RawURLEncoding.EncodeToString(pk.SerializeCompressed())
+	}
 	w.Chaincode = base64.RawURLEncoding.EncodeToString(chaincode)
-	w.Curve = curve.Params().Name
 
 	// Encrypt keys with their respective key descriptions
 	for i, kInfo := range kDesc {
@@ -241,16 +316,46 @@ func (w *Wallet) getKey(id string) *WalletKey {
 	return nil
 }
 
+// checkShare returns ErrBadShare if the decrypted share data of wk is not a share of this wallet's key
+func (w *Wallet) checkShare(wk *WalletKey, data any) error {
+	var pub []byte
+	switch v := data.(type) {
+	case *keygen.LocalPartySaveData:
+		if v.Xi != nil && v.ECDSAPub != nil {
+			pub = v.ECDSAPub.ToSecp256k1PubKey().SerializeCompressed()
+		}
+	case *eddsakeygen.LocalPartySaveData:
+		if v.Xi != nil && v.EDDSAPub != nil {
+			pub = eddsaPubkey(v.EDDSAPub)
+		}
+	}
+	if pub == nil || base64.RawURLEncoding.EncodeToString(pub) != w.Pubkey {
+		return fmt.Errorf("%w: key %s", ErrBadShare, wk.Id)
+	}
+	return nil
+}
+
 // Sign the digest using the wallet, returning a DER encoded signature
+// For ed25519 wallets digest is the full message and the signature is the standard 64 bytes ed25519 signature
 // Implements the crypto.Signer interface
 // Parameters:
 //   - rand: random source (not used in TSS signatures)
 //   - digest: the hash or message to sign
 //   - opts: must be *wltsign.Opts containing context and key information
 //
-// Returns the signature and any error encountered
+// Returns the signature and any error encountered, TSS failures match ErrTSSTimeout, ErrTSSPartyFailed or ErrBadShare
+// with errors.Is
 // Has panic recovery to prevent crashes during signature generation
-func (w *Wallet) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) (dat []byte, err error) {
+func (w *Wallet) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	sig, err := w.safeSign(rand, digest, opts, nil)
+	if err != nil {
+		return nil, apiError(err)
+	}
+	return sig, nil
+}
+
+// safeSign calls subSign, recovering from any panic during the signature
+func (w *Wallet) safeSign(rand io.Reader, digest []byte, opts crypto.SignerOpts, shares *shareCache) (dat []byte, err error) {
 	defer func() {
 		if e := recover(); e != nil {
 			// TODO might want to find a way to get the crash log
@@ -261,7 +366,7 @@ func (w *Wallet) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) (da
 			}
 		}
 	}()
-	dat, err = w.subSign(rand, digest, opts)
+	dat, err = w.subSign(rand, digest, opts, shares)
 	return
 }
 
@@ -271,9 +376,10 @@ func (w *Wallet) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) (da
 //   - rand: random source (not used in TSS signatures)
 //   - digest: the hash or message to sign
 //   - opts: must be *wltsign.Opts containing context, key information, and IL (intermediate value)
+//   - shares: decrypted key shares to reuse, nil to decrypt the keys for this signature only
 //
 // Returns the DER-encoded signature and any error encountered
-func (w *Wallet) subSign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+func (w *Wallet) subSign(rand io.Reader, digest []byte, opts crypto.SignerOpts, shares *shareCache) ([]byte, error) {
 	if w.Threshold == 0 {
 		w.Threshold = 1
 	}
@@ -281,18 +387,36 @@ func (w *Wallet) subSign(rand io.Reader, digest []byte, opts crypto.SignerOpts)
 	if !ok {
 		return nil, errors.New("sign requires appropriate options")
 	}
+	ctx := aopt.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
 	msg := new(big.Int).SetBytes(digest)
 	keys := aopt.Keys
+	if len(keys) <= w.Threshold {
+		return nil, fmt.Errorf("at least %d keys are required to sign", w.Threshold+1)
+	}
+	if shares == nil {
+		// presignature shares are sealed one by one and cannot be taken from a batch's shareCache, using them
+		// would decrypt the keys again for each digest
+		if sig, err := w.signPresigned(ctx, digest, aopt); sig != nil || err != nil {
+			return sig, err
+		}
+	}
 
 	// Prepare party IDs for TSS signing
 	var ids tss.UnSortedPartyIDs
 	m := make(map[string]tssPartyUpdateOnly)
 	idmap := make(map[int]*tss.PartyID)
+	timeout := signTimeout
 	for n, kd := range keys {
 		p := w.getKey(kd.Id)
 		if p == nil {
 			return nil, fmt.Errorf("could not find key id=%s", kd.Id)
 		}
+		if p.Type == "RemoteKey" {
+			timeout = remoteSignTimeout
+		}
 		key := new(big.Int).SetBytes(p.Id.UUID[:])
 		id := tss.NewPartyID(p.Id.String(), p.Id.String(), key)
 		ids = append(ids, id)
@@ -300,6 +424,10 @@ func (w *Wallet) subSign(rand io.Reader, digest []byte, opts crypto.SignerOpts)
 	}
 	sids := tss.SortPartyIDs(ids)
 
+	// Set a timeout for the signing operation, including the initialization of remote parties
+	ctx, cancel := tssContext(ctx, aopt.Timeout, timeout)
+	defer cancel()
+
 	// Get the correct curve for the wallet
 	curve, ok := tss.GetCurveByName(tss.CurveName(w.Curve))
 	if !ok {
@@ -307,58 +435,141 @@ func (w *Wallet) subSign(rand io.Reader, digest []byte, opts crypto.SignerOpts)
 	}
 	tssctx := tss.NewPeerContext(sids)
 
-	// Create channels for TSS communication
-	outCh := make(chan tss.Message)
-	defer close(outCh)
-	res := make(chan any, len(keys))
+	// Create channels for TSS communication. Messages are buffered until the router starts, once remote
+	// parties are ready.
+	outCh := make(chan tss.Message, tssMessageBuffer(len(keys)))
+	// res receives the first result (signature or error), others are dropped
+	res := make(chan any, 1)
+	send := func(v any) {
+		select {
+		case res <- v:
+		default:
+		}
+	}
+	fail := func(err error) { send(err) }
 
-	// Start TSS signing parties
+	// Prepare TSS signing parties
+	var local []tss.Party
+	var remote []*spotParty
 	for n, kd := range keys {
 		p := w.getKey(kd.Id)
-		if p == nil {
-			return nil, fmt.Errorf("could not find key id=%s", kd.Id)
+		if p.Type == "RemoteKey" {
+			// the remote peer runs this party, kd.Key is the session obtained with RemoteKey:sign
+			spot, err := spotClient(ctx)
+			if err != nil {
+				return nil, err
+			}
+			info := &walletSignSignInit{
+				Peers:      sids,
+				Name:       idmap[n],
+				Partycount: len(keys),
+				Threshold:  w.Threshold,
+				Curve:      w.Curve,
+				Hash:       hex.EncodeToString(digest),
+			}
+			if aopt.IL != nil {
+				info.IL = hex.EncodeToString(aopt.IL.Bytes())
+			}
+			sp := &spotParty{ctx: ctx, init: info, name: idmap[n], spot: spot, sid: kd.Key, parties: m, fail: fail}
+			m[p.Id.String()] = sp
+			remote = append(remote, sp)
+			continue
 		}
-		endCh := make(chan *common.SignatureData)
+		endCh := make(chan *common.SignatureData, 1)
 		params := tss.NewParameters(curve, tssctx, idmap[n], len(keys), w.Threshold)
-		sdata, err := p.decrypt(kd, keySignPurpose)
-		if err != nil {
-			return nil, fmt.Errorf("failed to decrypt key %s for signing: %w", kd.Id, err)
+		var party tss.Party
+		if w.IsEdDSA() {
+			// EdDSA signs the full message, and HD derivation is applied to the shares directly
+			edata, err := shares.decryptEdDSA(p, kd)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt key %s for signing: %w", kd.Id, err)
+			}
+			if err := w.checkShare(p, edata); err != nil {
+				return nil, err
+			}
+			// derive a copy, edata may be shared with other signatures of the same batch
+			d := *edata
+			if aopt.IL != nil {
+				d, err = eddsaApplyDelta(d, aopt.IL)
+				if err != nil {
+					return nil, err
+				}
+			}
+			party = eddsasigning.NewLocalParty(msg, params, d, outCh, endCh, len(digest))
+		} else {
+			sdata, err := shares.decrypt(p, kd)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt key %s for signing: %w", kd.Id, err)
+			}
+			if err := w.checkShare(p, sdata); err != nil {
+				return nil, err
+			}
+			party = signing.NewLocalPartyWithAutoKDD(msg, params, *sdata, aopt.IL, outCh, endCh, len(digest))
 		}
-		party := signing.NewLocalPartyWithAutoKDD(msg, params, *sdata, aopt.IL, outCh, endCh, len(digest))
 		m[p.Id.String()] = party
-		go func(p *WalletKey) {
+		local = append(local, party)
+		go func() {
 			defer func() {
 				wltcrash.Log(aopt.Context, recover(), "signing party thread")
 			}()
-			err := party.Start()
-			if err != nil {
-				log.Printf("err = %s", err)
-				res <- err
-				return // Return early to prevent blocking on endCh
+			select {
+			case sig := <-endCh:
+				if w.IsEdDSA() {
+					send(sig.Signature)
+					return
+				}
+				send(sig.GetSignatureObject().Serialize())
+			case <-ctx.Done():
 			}
-			sig := <-endCh
-			res <- sig.GetSignatureObject().Serialize()
-		}(p)
+		}()
+	}
+	if len(local) == 0 {
+		return nil, errors.New("at least one local key is required to sign")
 	}
-	go tssRouter(aopt.Context, m, outCh)
 
-	// Set a timeout for the signing operation
-	timer := time.NewTimer(15 * time.Second)
-	defer timer.Stop()
+	done := false
+	defer func() {
+		for _, sp := range remote {
+			sp.close(!done)
+		}
+	}()
+
+	// remote parties must be ready to receive messages before local parties start
+	for _, sp := range remote {
+		if err := sp.Start(); err != nil {
+			if ctx.Err() != nil {
+				return nil, tssContextError(ctx)
+			}
+			return nil, tssPartyError(sp.name.Id, fmt.Errorf("failed to start remote signature: %w", err))
+		}
+	}
+	for _, party := range local {
+		go func() {
+			defer func() {
+				wltcrash.Log(aopt.Context, recover(), "signing party thread")
+			}()
+			if err := party.Start(); err != nil {
+				log.Printf("err = %s", err)
+				fail(tssPartyError(party.PartyID().Id, err))
+			}
+		}()
+	}
+	go tssRouter(ctx, m, outCh, fail)
 
-	// Wait for result or timeout
+	// Wait for result, cancellation or timeout
 	select {
 	case final := <-res:
 		switch v := final.(type) {
 		case error:
 			return nil, v
 		case []byte:
+			done = true
 			return v, nil
 		default:
 			return nil, fmt.Errorf("invalid data type %T", v)
 		}
-	case <-timer.C:
-		return nil, fmt.Errorf("signature operation timed out")
+	case <-ctx.Done():
+		return nil, tssContextError(ctx)
 	}
 }
 
@@ -366,6 +577,9 @@ func (w *Wallet) subSign(rand io.Reader, digest []byte, opts crypto.SignerOpts)
 // Decodes the base64-encoded public key stored in the wallet
 // Returns the public key and any error encountered during decoding
 func (w *Wallet) GetPubkey() (*secp256k1.PublicKey, error) {
+	if w.IsEdDSA() {
+		return nil, errors.New("ed25519 wallet has no secp256k1 public key, use Ed25519Pubkey")
+	}
 	dat, err := base64.RawURLEncoding.DecodeString(w.Pubkey)
 	if err != nil {
 		return nil, err
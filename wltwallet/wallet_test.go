@@ -5,10 +5,12 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 	"log"
 	"testing"
 
 	"github.com/EllipX/libwallet/wltsign"
+	"github.com/KarpelesLab/xuid"
 	"github.com/ModChain/secp256k1"
 )
 
@@ -90,7 +92,7 @@ func TestWalletCreate(t *testing.T) {
 		&wltsign.KeyDescription{Type: "Plain"},
 	}
 
-	err = w.Reshare(context.Background(), opts.Keys, newKd)
+	err = w.Reshare(context.Background(), opts.Keys, newKd, 0)
 	if err != nil {
 		t.Errorf("failed to reshare wallet: %s", err)
 		return
@@ -149,3 +151,41 @@ func must[T any](a T, err error) T {
 	}
 	return a
 }
+
+func TestReshareThreshold(t *testing.T) {
+	w := &Wallet{Threshold: 1}
+	plain := func(n int) []*wltsign.KeyDescription {
+		var res []*wltsign.KeyDescription
+		for i := 0; i < n; i++ {
+			res = append(res, &wltsign.KeyDescription{Type: "Plain"})
+		}
+		return res
+	}
+
+	// validation happens before any key is generated
+	if err := w.Reshare(context.Background(), plain(2), plain(3), 3); err == nil {
+		t.Errorf("expected error for a threshold equal to the number of keys")
+	}
+	if err := w.Reshare(context.Background(), plain(2), plain(5), -1); err == nil {
+		t.Errorf("expected error for a negative threshold")
+	}
+	if err := w.Reshare(context.Background(), plain(1), plain(5), 2); err == nil {
+		t.Errorf("expected error when not enough current keys take part")
+	}
+	if w.Threshold != 1 {
+		t.Errorf("threshold should not change on failure, got %d", w.Threshold)
+	}
+}
+
+func TestWalletCreateCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, curve := range []string{"secp256k1", "ed25519"} {
+		w := &Wallet{Id: xuid.New("wlt"), Name: "Test", Threshold: 1, Curve: curve}
+		keys := []*wltsign.KeyDescription{{Type: "Plain"}, {Type: "Plain"}, {Type: "Plain"}}
+		if err := w.initializeWallet(ctx, keys); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: expected context.Canceled, got %v", curve, err)
+		}
+	}
+}
@@ -20,6 +20,8 @@ import (
 	"github.com/KarpelesLab/spotlib"
 	"github.com/KarpelesLab/xuid"
 	"github.com/ModChain/tss-lib/v2/ecdsa/keygen"
+	eddsakeygen "github.com/ModChain/tss-lib/v2/eddsa/keygen"
+	"github.com/ModChain/tss-lib/v2/tss"
 	"github.com/fxamacker/cbor/v2"
 )
 
@@ -31,7 +33,8 @@ type WalletKey struct {
 	Data   []byte `json:",protect"`
 	Gen    uint64 `gorm:"not null;default:0"` // key generation
 	pre    *keygen.LocalPreParams
-	sdata  *keygen.LocalPartySaveData
+	sdata  *keygen.LocalPartySaveData      // secp256k1 wallets
+	edata  *eddsakeygen.LocalPartySaveData // ed25519 wallets
 }
 
 func (wk *WalletKey) save(e wltintf.Env) error {
@@ -39,24 +42,37 @@ func (wk *WalletKey) save(e wltintf.Env) error {
 }
 
 func (w *Wallet) createWalletKey(ctx context.Context, typ string) (*WalletKey, error) {
-	// generate key
-	preParams, err := keygen.GeneratePreParamsWithContext(ctx)
-	if err != nil {
-		return nil, err
-	}
 	final := &WalletKey{
 		Id:     xuid.New("wkey"),
 		Wallet: w.Id,
 		Type:   typ,
 		Gen:    w.Gen + 1, // always use base gen +1, wallet gen will be updated on save
-		pre:    preParams,
 	}
+	if tss.CurveName(w.Curve) == tss.Ed25519 {
+		// EdDSA does not use Paillier, no pre params needed
+		return final, nil
+	}
+
+	// generate key
+	preParams, err := keygen.GeneratePreParamsWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	final.pre = preParams
 	return final, nil
 }
 
-// encrypt stores wk.sdata into wk.Data
+// saveData returns the TSS save data of this key, for either curve
+func (wk *WalletKey) saveData() any {
+	if wk.edata != nil {
+		return wk.edata
+	}
+	return wk.sdata
+}
+
+// encrypt stores wk.sdata (or wk.edata) into wk.Data
 func (wk *WalletKey) encrypt(kd *wltsign.KeyDescription) error {
-	res, err := cryptutil.MarshalJson(wk.sdata)
+	res, err := cryptutil.MarshalJson(wk.saveData())
 	if err != nil {
 		return err
 	}
@@ -148,7 +164,61 @@ func (wk *WalletKey) encrypt(kd *wltsign.KeyDescription) error {
 }
 
 func (wk *WalletKey) decrypt(kd *wltsign.KeyDescription, purpose keyUsagePurpose) (*keygen.LocalPartySaveData, error) {
-	bottle := cryptutil.AsCborBottle(wk.Data)
+	var final *keygen.LocalPartySaveData
+	if err := wk.open(kd, purpose, &final); err != nil {
+		return nil, err
+	}
+	return final, nil
+}
+
+// decryptEdDSA is the same as decrypt for keys of ed25519 wallets
+func (wk *WalletKey) decryptEdDSA(kd *wltsign.KeyDescription, purpose keyUsagePurpose) (*eddsakeygen.LocalPartySaveData, error) {
+	var final *eddsakeygen.LocalPartySaveData
+	if err := wk.open(kd, purpose, &final); err != nil {
+		return nil, err
+	}
+	return final, nil
+}
+
+// open decrypts wk.Data into v
+func (wk *WalletKey) open(kd *wltsign.KeyDescription, purpose keyUsagePurpose, v any) error {
+	return wk.openData(wk.Data, kd, purpose, v)
+}
+
+// seal encrypts v for this key in the same format as wk.Data, so it can be opened with openData. Only the public key
+// is needed, which is not available for RemoteKey.
+func (wk *WalletKey) seal(v any) ([]byte, error) {
+	res, err := cryptutil.MarshalJson(v)
+	if err != nil {
+		return nil, err
+	}
+
+	switch wk.Type {
+	case "StoreKey", "Password":
+		pubKeyB, err := base64.RawURLEncoding.DecodeString(wk.Key)
+		if err != nil {
+			return nil, err
+		}
+		pubKey, err := x509.ParsePKIXPublicKey(pubKeyB)
+		if err != nil {
+			return nil, err
+		}
+		err = res.Encrypt(rand.Reader, pubKey)
+		if err != nil {
+			return nil, err
+		}
+	case "Plain":
+		// do nothing
+	default:
+		return nil, fmt.Errorf("cannot seal data for keys of type %s", wk.Type)
+	}
+
+	return cbor.Marshal(res)
+}
+
+// openData decrypts data sealed for this key into v
+func (wk *WalletKey) openData(data []byte, kd *wltsign.KeyDescription, purpose keyUsagePurpose, v any) error {
+	bottle := cryptutil.AsCborBottle(data)
 
 	op := cryptutil.EmptyOpener
 
@@ -156,55 +226,54 @@ func (wk *WalletKey) decrypt(kd *wltsign.KeyDescription, purpose keyUsagePurpose
 	case "StoreKey":
 		k, err := storeKeyToEd25519(kd.Key)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		pkBin, err := x509.MarshalPKIXPublicKey(k.Public())
 		if err != nil {
-			return nil, err
+			return err
 		}
 		curPkBin, err := base64.RawURLEncoding.DecodeString(wk.Key)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		if !bytes.Equal(pkBin, curPkBin) {
-			return nil, ErrBadStoreKey
+			return ErrBadStoreKey
 		}
 		op, err = cryptutil.NewOpener(k)
 		if err != nil {
-			return nil, err
+			return err
 		}
 	case "Password":
 		pk, err := passwordToEd25519(kd.Key, wk.Id.UUID[:])
 		if err != nil {
-			return nil, err
+			return err
 		}
 		pkBin, err := x509.MarshalPKIXPublicKey(pk.Public())
 		if err != nil {
-			return nil, err
+			return err
 		}
 		curPkBin, err := base64.RawURLEncoding.DecodeString(wk.Key)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		if !bytes.Equal(pkBin, curPkBin) {
-			return nil, ErrBadPassword
+			return ErrBadPassword
 		}
 		op, err = cryptutil.NewOpener(pk)
 		if err != nil {
-			return nil, err
+			return err
 		}
 	case "Plain":
 		// do nothing
 	default:
-		return nil, fmt.Errorf("cannot open keys of type %s", wk.Type)
+		return fmt.Errorf("cannot open keys of type %s", wk.Type)
 	}
 
-	var final *keygen.LocalPartySaveData
-	_, err := op.Unmarshal(bottle, &final)
+	_, err := op.Unmarshal(bottle, v)
 	if err != nil {
-		return nil, fmt.Errorf("while decrypting key %s: %w", wk.Id, err)
+		return fmt.Errorf("%w: while decrypting key %s: %w", ErrBadShare, wk.Id, err)
 	}
-	return final, err
+	return nil
 }
 
 func selectPeer(ctx context.Context, spot *spotlib.Client) (string, error) {
@@ -8,6 +8,7 @@ import (
 	"github.com/KarpelesLab/apirouter"
 	"github.com/KarpelesLab/pobj"
 	"github.com/KarpelesLab/xuid"
+	"github.com/ModChain/tss-lib/v2/tss"
 )
 
 func init() {
@@ -52,9 +53,16 @@ func walletKeyRecrypt(ctx *apirouter.Context, in struct {
 		return nil, errors.New("Wallet/Key required")
 	}
 
-	var err error
+	w, err := wltintf.ByPrimaryKey[Wallet](e, wk.Wallet)
+	if err != nil {
+		return nil, err
+	}
 
-	wk.sdata, err = wk.decrypt(in.Old, keyRecryptPurpose)
+	if tss.CurveName(w.Curve) == tss.Ed25519 {
+		wk.edata, err = wk.decryptEdDSA(in.Old, keyRecryptPurpose)
+	} else {
+		wk.sdata, err = wk.decrypt(in.Old, keyRecryptPurpose)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,43 @@
+package wltwc
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/ModChain/base58"
+)
+
+// didKey returns the did:key identifier of an ed25519 public key (multicodec 0xed01, base58btc multibase)
+func didKey(pub ed25519.PublicKey) string {
+	return "did:key:z" + base58.Bitcoin.Encode(append([]byte{0xed, 0x01}, pub...))
+}
+
+// relayAuthToken returns the JWT used to authenticate with the relay, signed with the client's ed25519 key
+func relayAuthToken(key ed25519.PrivateKey, aud string, ttl time.Duration) (string, error) {
+	sub := make([]byte, 32)
+	if _, err := rand.Read(sub); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	hdr, err := json.Marshal(map[string]string{"alg": "EdDSA", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iss": didKey(key.Public().(ed25519.PublicKey)),
+		"sub": hex.EncodeToString(sub),
+		"aud": aud,
+		"iat": now.Unix(),
+		"exp": now.Add(ttl).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	data := enc.EncodeToString(hdr) + "." + enc.EncodeToString(claims)
+	return data + "." + enc.EncodeToString(ed25519.Sign(key, []byte(data))), nil
+}
@@ -0,0 +1,443 @@
+package wltwc
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/EllipX/libwallet/wltintf"
+)
+
+// sessionTTL is how long sessions we settle remain valid
+const sessionTTL = 7 * 24 * time.Hour
+
+// Handler receives the proposals & requests of dApps. Its methods may block while waiting for the user.
+type Handler interface {
+	// SessionProposal is called when a dApp wants to open a session, and returns the namespaces to settle
+	// the session with, or an error (typically ErrUserRejected) if it should be refused
+	SessionProposal(p *Proposal) (map[string]*Namespace, error)
+	// SessionRequest is called for each JSON-RPC request received in a session, on the given chain (CAIP-2)
+	SessionRequest(s *Session, chainId, method string, params any) (any, error)
+	// SessionDeleted is called when the dApp closed a session
+	SessionDeleted(s *Session)
+}
+
+// Client is a WalletConnect v2 wallet client, handling pairings and sessions over a Relay
+type Client struct {
+	e        wltintf.Env
+	relay    Relay
+	meta     *Metadata
+	handler  Handler
+	pairings map[string]*Pairing
+	sessions map[string]*Session
+	lk       sync.RWMutex
+}
+
+// NewClient returns a client using the given relay. Pairings and sessions saved in e are restored and the
+// relay is subscribed to their topics.
+func NewClient(ctx context.Context, e wltintf.Env, relay Relay, meta *Metadata, h Handler) (*Client, error) {
+	c := &Client{
+		e:        e,
+		relay:    relay,
+		meta:     meta,
+		handler:  h,
+		pairings: make(map[string]*Pairing),
+		sessions: make(map[string]*Session),
+	}
+
+	var pairings []*Pairing
+	if err := e.Find(&pairings, map[string]any{}); err != nil {
+		return nil, err
+	}
+	var sessions []*Session
+	if err := e.Find(&sessions, map[string]any{}); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, p := range pairings {
+		if !p.Expiry.IsZero() && p.Expiry.Before(now) {
+			e.Delete(p)
+			continue
+		}
+		c.pairings[p.Topic] = p
+	}
+	for _, s := range sessions {
+		if s.Expiry.Before(now) {
+			e.Delete(s)
+			continue
+		}
+		c.sessions[s.Topic] = s
+	}
+
+	go c.readLoop()
+
+	for topic := range c.topics() {
+		if err := relay.Subscribe(ctx, topic); err != nil {
+			return nil, fmt.Errorf("walletconnect: failed to subscribe to %s: %w", topic, err)
+		}
+	}
+	return c, nil
+}
+
+func (c *Client) topics() map[string]bool {
+	c.lk.RLock()
+	defer c.lk.RUnlock()
+
+	res := make(map[string]bool)
+	for t := range c.pairings {
+		res[t] = true
+	}
+	for t := range c.sessions {
+		res[t] = true
+	}
+	return res
+}
+
+// Pair establishes a pairing from a wc: URI. The session proposal of the dApp will be passed to the handler.
+func (c *Client) Pair(ctx context.Context, uri string) (*Pairing, error) {
+	u, err := ParseURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	if u.Relay != "irn" {
+		return nil, fmt.Errorf("walletconnect: unsupported relay protocol %s", u.Relay)
+	}
+	if u.Expiry.IsZero() {
+		u.Expiry = time.Now().Add(5 * time.Minute)
+	} else if u.Expiry.Before(time.Now()) {
+		return nil, errors.New("walletconnect: pairing uri has expired")
+	}
+
+	p := &Pairing{Topic: u.Topic, SymKey: u.SymKey, Expiry: u.Expiry}
+	if err := c.e.Save(p); err != nil {
+		return nil, err
+	}
+	c.lk.Lock()
+	c.pairings[p.Topic] = p
+	c.lk.Unlock()
+
+	if err := c.relay.Subscribe(ctx, p.Topic); err != nil {
+		return nil, fmt.Errorf("walletconnect: failed to subscribe to pairing: %w", err)
+	}
+	return p, nil
+}
+
+// Sessions returns the currently active sessions
+func (c *Client) Sessions() []*Session {
+	c.lk.RLock()
+	defer c.lk.RUnlock()
+
+	res := make([]*Session, 0, len(c.sessions))
+	for _, s := range c.sessions {
+		res = append(res, s)
+	}
+	slices.SortFunc(res, func(a, b *Session) int { return a.Created.Compare(b.Created) })
+	return res
+}
+
+// Session returns the session with the given topic
+func (c *Client) Session(topic string) (*Session, bool) {
+	c.lk.RLock()
+	defer c.lk.RUnlock()
+	s, ok := c.sessions[topic]
+	return s, ok
+}
+
+// Disconnect closes the session with the given topic and notifies the dApp
+func (c *Client) Disconnect(ctx context.Context, topic string) error {
+	s, ok := c.Session(topic)
+	if !ok {
+		return fmt.Errorf("walletconnect: session %s not found", topic)
+	}
+	msg, err := newRequest("wc_sessionDelete", &deleteParams{Code: ErrUserDisconnected.Code, Message: ErrUserDisconnected.Message})
+	if err != nil {
+		return err
+	}
+	if err := c.publish(ctx, s.Topic, s.SymKey, msg); err != nil {
+		log.Printf("walletconnect: failed to notify session deletion: %s", err)
+	}
+	c.removeSession(ctx, s)
+	return nil
+}
+
+// Emit sends an event (such as accountsChanged or chainChanged) to the dApp of a session
+func (c *Client) Emit(ctx context.Context, topic, chainId, name string, data any) error {
+	s, ok := c.Session(topic)
+	if !ok {
+		return fmt.Errorf("walletconnect: session %s not found", topic)
+	}
+	var params sessionEventParams
+	params.Event.Name = name
+	params.Event.Data = data
+	params.ChainId = chainId
+	msg, err := newRequest("wc_sessionEvent", &params)
+	if err != nil {
+		return err
+	}
+	return c.publish(ctx, s.Topic, s.SymKey, msg)
+}
+
+// Close closes the relay connection. Pairings and sessions remain saved.
+func (c *Client) Close() error {
+	return c.relay.Close()
+}
+
+func (c *Client) removeSession(ctx context.Context, s *Session) {
+	c.lk.Lock()
+	delete(c.sessions, s.Topic)
+	c.lk.Unlock()
+	c.relay.Unsubscribe(ctx, s.Topic)
+	c.e.Delete(s)
+}
+
+func (c *Client) removePairing(ctx context.Context, p *Pairing) {
+	c.lk.Lock()
+	delete(c.pairings, p.Topic)
+	c.lk.Unlock()
+	c.relay.Unsubscribe(ctx, p.Topic)
+	c.e.Delete(p)
+}
+
+// publish encrypts a request with symKey and sends it on topic
+func (c *Client) publish(ctx context.Context, topic string, symKey []byte, msg *Message) error {
+	buf, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	env, err := Encrypt(symKey, buf)
+	if err != nil {
+		return err
+	}
+
+	info, ok := methods[msg.Method]
+	if !ok {
+		return fmt.Errorf("walletconnect: unknown method %s", msg.Method)
+	}
+	return c.relay.Publish(ctx, topic, env, info.ttl, info.reqTag)
+}
+
+// respond sends the response to req, using the response tag of its method
+func (c *Client) respond(ctx context.Context, topic string, symKey []byte, req *Message, res any, err error) {
+	var msg *Message
+	if err != nil {
+		msg = newError(req.Id, err)
+	} else if msg, err = newResult(req.Id, res); err != nil {
+		msg = newError(req.Id, err)
+	}
+
+	buf, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("walletconnect: failed to encode response: %s", err)
+		return
+	}
+	env, err := Encrypt(symKey, buf)
+	if err != nil {
+		log.Printf("walletconnect: failed to encrypt response: %s", err)
+		return
+	}
+	tag, ttl := 0, 5*time.Minute
+	if info, ok := methods[req.Method]; ok {
+		tag, ttl = info.respTag, info.ttl
+	}
+	if err := c.relay.Publish(ctx, topic, env, ttl, tag); err != nil {
+		log.Printf("walletconnect: failed to publish response to %s: %s", req.Method, err)
+	}
+}
+
+func (c *Client) readLoop() {
+	for m := range c.relay.Messages() {
+		c.handleMessage(m)
+	}
+}
+
+func (c *Client) handleMessage(m *RelayMessage) {
+	c.lk.RLock()
+	p := c.pairings[m.Topic]
+	s := c.sessions[m.Topic]
+	c.lk.RUnlock()
+
+	var symKey []byte
+	switch {
+	case s != nil:
+		symKey = s.SymKey
+	case p != nil:
+		symKey = p.SymKey
+	default:
+		// not for us (anymore)
+		return
+	}
+
+	buf, err := Decrypt(symKey, m.Message)
+	if err != nil {
+		log.Printf("walletconnect: dropping message on %s: %s", m.Topic, err)
+		return
+	}
+	var msg *Message
+	if err := json.Unmarshal(buf, &msg); err != nil || msg == nil {
+		log.Printf("walletconnect: dropping invalid message on %s", m.Topic)
+		return
+	}
+	if msg.isResponse() {
+		// responses to our own requests (settle, events, delete) do not require any action
+		if msg.Error != nil {
+			log.Printf("walletconnect: peer returned error %d: %s", msg.Error.Code, msg.Error.Message)
+		}
+		return
+	}
+
+	ctx := context.Background()
+	if s != nil {
+		c.handleSessionMessage(ctx, s, msg)
+	} else {
+		c.handlePairingMessage(ctx, p, msg)
+	}
+}
+
+func (c *Client) handlePairingMessage(ctx context.Context, p *Pairing, msg *Message) {
+	switch msg.Method {
+	case "wc_sessionPropose":
+		var prop *Proposal
+		if err := json.Unmarshal(msg.Params, &prop); err != nil || prop == nil || prop.Proposer == nil {
+			c.respond(ctx, p.Topic, p.SymKey, msg, nil, errors.New("invalid session proposal"))
+			return
+		}
+		prop.Id = msg.Id
+		prop.PairingTopic = p.Topic
+		if prop.Proposer.Metadata != nil {
+			p.Peer = prop.Proposer.Metadata
+			c.e.Save(p)
+		}
+		// the handler waits for the user, do not block other messages
+		go c.handleProposal(ctx, p, prop, msg)
+	case "wc_pairingPing":
+		c.respond(ctx, p.Topic, p.SymKey, msg, true, nil)
+	case "wc_pairingDelete":
+		c.respond(ctx, p.Topic, p.SymKey, msg, true, nil)
+		c.removePairing(ctx, p)
+	default:
+		c.respond(ctx, p.Topic, p.SymKey, msg, nil, ErrUnsupportedMethods)
+	}
+}
+
+func (c *Client) handleProposal(ctx context.Context, p *Pairing, prop *Proposal, msg *Message) {
+	ns, err := c.handler.SessionProposal(prop)
+	if err != nil {
+		c.respond(ctx, p.Topic, p.SymKey, msg, nil, err)
+		return
+	}
+
+	peerPub, err := hex.DecodeString(prop.Proposer.PublicKey)
+	if err != nil {
+		c.respond(ctx, p.Topic, p.SymKey, msg, nil, errors.New("invalid proposer public key"))
+		return
+	}
+	priv, err := GenerateKey()
+	if err != nil {
+		c.respond(ctx, p.Topic, p.SymKey, msg, nil, err)
+		return
+	}
+	symKey, err := DeriveSymKey(priv, peerPub)
+	if err != nil {
+		c.respond(ctx, p.Topic, p.SymKey, msg, nil, err)
+		return
+	}
+	pubKey := hex.EncodeToString(priv.PublicKey().Bytes())
+
+	s := &Session{
+		Topic:        Topic(symKey),
+		PairingTopic: p.Topic,
+		SymKey:       symKey,
+		Peer:         prop.Proposer,
+		Namespaces:   ns,
+		Expiry:       time.Now().Add(sessionTTL),
+	}
+	if err := c.e.Save(s); err != nil {
+		c.respond(ctx, p.Topic, p.SymKey, msg, nil, err)
+		return
+	}
+	c.lk.Lock()
+	c.sessions[s.Topic] = s
+	c.lk.Unlock()
+
+	// the session topic must be subscribed before the dApp receives our public key
+	if err := c.relay.Subscribe(ctx, s.Topic); err != nil {
+		log.Printf("walletconnect: failed to subscribe to session: %s", err)
+		c.removeSession(ctx, s)
+		c.respond(ctx, p.Topic, p.SymKey, msg, nil, err)
+		return
+	}
+	c.respond(ctx, p.Topic, p.SymKey, msg, &proposalResponse{Relay: &relayProtocol{Protocol: "irn"}, ResponderPublicKey: pubKey}, nil)
+
+	settle, err := newRequest("wc_sessionSettle", &settleParams{
+		Relay:      &relayProtocol{Protocol: "irn"},
+		Namespaces: ns,
+		Controller: &Participant{PublicKey: pubKey, Metadata: c.meta},
+		Expiry:     s.Expiry.Unix(),
+	})
+	if err == nil {
+		err = c.publish(ctx, s.Topic, s.SymKey, settle)
+	}
+	if err != nil {
+		log.Printf("walletconnect: failed to settle session: %s", err)
+	}
+}
+
+func (c *Client) handleSessionMessage(ctx context.Context, s *Session, msg *Message) {
+	switch msg.Method {
+	case "wc_sessionRequest":
+		var params *sessionRequestParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil || params == nil {
+			c.respond(ctx, s.Topic, s.SymKey, msg, nil, errors.New("invalid session request"))
+			return
+		}
+		if err := s.check(params.ChainId, params.Request.Method); err != nil {
+			c.respond(ctx, s.Topic, s.SymKey, msg, nil, err)
+			return
+		}
+		go func() {
+			res, err := c.handler.SessionRequest(s, params.ChainId, params.Request.Method, params.Request.Params)
+			c.respond(ctx, s.Topic, s.SymKey, msg, res, err)
+		}()
+	case "wc_sessionPing":
+		c.respond(ctx, s.Topic, s.SymKey, msg, true, nil)
+	case "wc_sessionDelete":
+		c.respond(ctx, s.Topic, s.SymKey, msg, true, nil)
+		c.removeSession(ctx, s)
+		c.handler.SessionDeleted(s)
+	default:
+		c.respond(ctx, s.Topic, s.SymKey, msg, nil, ErrUnsupportedMethods)
+	}
+}
+
+// check returns an error if the chain or method were not approved for this session
+func (s *Session) check(chainId, method string) error {
+	family, _, _ := strings.Cut(chainId, ":")
+	ns, ok := s.Namespaces[family]
+	if !ok || !slices.Contains(ns.chains(), chainId) {
+		return ErrUnsupportedChains
+	}
+	if !slices.Contains(ns.Methods, method) {
+		return ErrUnsupportedMethods
+	}
+	return nil
+}
+
+// chains returns the chains of the namespace, including those only found in accounts
+func (ns *Namespace) chains() []string {
+	res := slices.Clone(ns.Chains)
+	for _, a := range ns.Accounts {
+		// family:reference:address
+		if i := strings.LastIndexByte(a, ':'); i > 0 && !slices.Contains(res, a[:i]) {
+			res = append(res, a[:i])
+		}
+	}
+	return res
+}
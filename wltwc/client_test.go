@@ -0,0 +1,366 @@
+package wltwc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/EllipX/libwallet/wltintf"
+)
+
+// memHub is an in-memory relay network. Messages are kept so subscribers receive those published before they subscribed.
+type memHub struct {
+	lk      sync.Mutex
+	subs    map[string][]*memRelay
+	history map[string][]*RelayMessage
+}
+
+func newMemHub() *memHub {
+	return &memHub{subs: make(map[string][]*memRelay), history: make(map[string][]*RelayMessage)}
+}
+
+type memRelay struct {
+	hub  *memHub
+	msgs chan *RelayMessage
+}
+
+func (h *memHub) connect() *memRelay {
+	return &memRelay{hub: h, msgs: make(chan *RelayMessage, 32)}
+}
+
+func (r *memRelay) Subscribe(ctx context.Context, topic string) error {
+	r.hub.lk.Lock()
+	defer r.hub.lk.Unlock()
+	r.hub.subs[topic] = append(r.hub.subs[topic], r)
+	for _, m := range r.hub.history[topic] {
+		r.msgs <- m
+	}
+	return nil
+}
+
+func (r *memRelay) Unsubscribe(ctx context.Context, topic string) error {
+	r.hub.lk.Lock()
+	defer r.hub.lk.Unlock()
+	subs := r.hub.subs[topic]
+	for i, s := range subs {
+		if s == r {
+			r.hub.subs[topic] = append(subs[:i], subs[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (r *memRelay) Publish(ctx context.Context, topic, message string, ttl time.Duration, tag int) error {
+	r.hub.lk.Lock()
+	defer r.hub.lk.Unlock()
+	m := &RelayMessage{Topic: topic, Message: message, PublishedAt: time.Now().UnixMilli(), Tag: tag}
+	r.hub.history[topic] = append(r.hub.history[topic], m)
+	for _, s := range r.hub.subs[topic] {
+		if s != r {
+			s.msgs <- m
+		}
+	}
+	return nil
+}
+
+func (r *memRelay) Messages() <-chan *RelayMessage {
+	return r.msgs
+}
+
+func (r *memRelay) Close() error {
+	return nil
+}
+
+// memEnv stores objects in memory, only implementing what Client needs
+type memEnv struct {
+	wltintf.Env
+	lk       sync.Mutex
+	pairings map[string]*Pairing
+	sessions map[string]*Session
+}
+
+func (e *memEnv) Save(obj any) error {
+	e.lk.Lock()
+	defer e.lk.Unlock()
+	switch v := obj.(type) {
+	case *Pairing:
+		e.pairings[v.Topic] = v
+	case *Session:
+		e.sessions[v.Topic] = v
+	}
+	return nil
+}
+
+func (e *memEnv) Delete(obj any) error {
+	e.lk.Lock()
+	defer e.lk.Unlock()
+	switch v := obj.(type) {
+	case *Pairing:
+		delete(e.pairings, v.Topic)
+	case *Session:
+		delete(e.sessions, v.Topic)
+	}
+	return nil
+}
+
+func (e *memEnv) Find(target any, where map[string]any) error {
+	e.lk.Lock()
+	defer e.lk.Unlock()
+	switch v := target.(type) {
+	case *[]*Pairing:
+		for _, p := range e.pairings {
+			*v = append(*v, p)
+		}
+	case *[]*Session:
+		for _, s := range e.sessions {
+			*v = append(*v, s)
+		}
+	}
+	return nil
+}
+
+type testHandler struct {
+	deleted chan *Session
+}
+
+func (h *testHandler) SessionProposal(p *Proposal) (map[string]*Namespace, error) {
+	if p.Host() != "https://dapp.example.com" {
+		return nil, ErrUserRejected
+	}
+	return map[string]*Namespace{
+		"eip155": {
+			Chains:   []string{"eip155:1"},
+			Methods:  []string{"personal_sign", "eth_sendTransaction"},
+			Events:   []string{"accountsChanged"},
+			Accounts: []string{"eip155:1:0xab16a96D359eC26a11e2C2b3d8f8B8942d5Bfcdb"},
+		},
+	}, nil
+}
+
+func (h *testHandler) SessionRequest(s *Session, chainId, method string, params any) (any, error) {
+	if method == "eth_sendTransaction" {
+		return nil, &RPCError{Code: 4001, Message: "User rejected the request."}
+	}
+	return "0x1234", nil
+}
+
+func (h *testHandler) SessionDeleted(s *Session) {
+	h.deleted <- s
+}
+
+// testDApp plays the dApp side of the protocol
+type testDApp struct {
+	t     *testing.T
+	relay *memRelay
+	keys  map[string][]byte // topic → symKey
+}
+
+func (d *testDApp) send(topic, method string, params any) int64 {
+	d.t.Helper()
+	msg, err := newRequest(method, params)
+	if err != nil {
+		d.t.Fatal(err)
+	}
+	buf, _ := json.Marshal(msg)
+	env, err := Encrypt(d.keys[topic], buf)
+	if err != nil {
+		d.t.Fatal(err)
+	}
+	d.relay.Publish(context.Background(), topic, env, time.Minute, methods[method].reqTag)
+	return msg.Id
+}
+
+func (d *testDApp) recv() (string, *Message) {
+	d.t.Helper()
+	select {
+	case m := <-d.relay.Messages():
+		buf, err := Decrypt(d.keys[m.Topic], m.Message)
+		if err != nil {
+			d.t.Fatalf("failed to decrypt message: %s", err)
+		}
+		var msg *Message
+		if err := json.Unmarshal(buf, &msg); err != nil {
+			d.t.Fatalf("invalid message: %s", err)
+		}
+		return m.Topic, msg
+	case <-time.After(5 * time.Second):
+		d.t.Fatalf("timed out waiting for message")
+	}
+	return "", nil
+}
+
+func TestClientSession(t *testing.T) {
+	ctx := context.Background()
+	hub := newMemHub()
+	e := &memEnv{pairings: make(map[string]*Pairing), sessions: make(map[string]*Session)}
+	h := &testHandler{deleted: make(chan *Session, 1)}
+
+	c, err := NewClient(ctx, e, hub.connect(), &Metadata{Name: "Test Wallet"}, h)
+	if err != nil {
+		t.Fatalf("failed to create client: %s", err)
+	}
+
+	// dApp creates a pairing
+	symKey := make([]byte, 32)
+	topic := make([]byte, 32)
+	rand.Read(symKey)
+	rand.Read(topic)
+	uri := &PairingURI{Topic: hex.EncodeToString(topic), Version: 2, SymKey: symKey, Relay: "irn", Expiry: time.Now().Add(time.Minute)}
+	dapp := &testDApp{t: t, relay: hub.connect(), keys: map[string][]byte{uri.Topic: symKey}}
+	dapp.relay.Subscribe(ctx, uri.Topic)
+
+	if _, err := c.Pair(ctx, uri.String()); err != nil {
+		t.Fatalf("failed to pair: %s", err)
+	}
+
+	dappKey, _ := GenerateKey()
+	propId := dapp.send(uri.Topic, "wc_sessionPropose", &Proposal{
+		Relays: []*relayProtocol{{Protocol: "irn"}},
+		Proposer: &Participant{
+			PublicKey: hex.EncodeToString(dappKey.PublicKey().Bytes()),
+			Metadata:  &Metadata{Name: "Test dApp", URL: "https://dapp.example.com/app"},
+		},
+		RequiredNamespaces: map[string]*Namespace{"eip155": {Chains: []string{"eip155:1"}, Methods: []string{"personal_sign"}, Events: []string{"accountsChanged"}}},
+	})
+
+	// proposal response
+	rtopic, msg := dapp.recv()
+	if rtopic != uri.Topic || msg.Id != propId || msg.Error != nil {
+		t.Fatalf("unexpected proposal response on %s: %+v", rtopic, msg)
+	}
+	var resp *proposalResponse
+	json.Unmarshal(msg.Result, &resp)
+	walletPub, _ := hex.DecodeString(resp.ResponderPublicKey)
+	sessKey, err := DeriveSymKey(dappKey, walletPub)
+	if err != nil {
+		t.Fatalf("failed to derive session key: %s", err)
+	}
+	sessTopic := Topic(sessKey)
+	dapp.keys[sessTopic] = sessKey
+	dapp.relay.Subscribe(ctx, sessTopic)
+
+	// settle
+	rtopic, msg = dapp.recv()
+	if rtopic != sessTopic || msg.Method != "wc_sessionSettle" {
+		t.Fatalf("expected wc_sessionSettle on session topic, got %s on %s", msg.Method, rtopic)
+	}
+	var settle *settleParams
+	json.Unmarshal(msg.Params, &settle)
+	if settle.Controller.PublicKey != resp.ResponderPublicKey || settle.Namespaces["eip155"] == nil {
+		t.Errorf("unexpected settle params %s", msg.Params)
+	}
+	if len(c.Sessions()) != 1 || c.Sessions()[0].Host() != "https://dapp.example.com" {
+		t.Errorf("expected one session for the dApp")
+	}
+
+	// requests
+	var req sessionRequestParams
+	req.ChainId = "eip155:1"
+	req.Request.Method = "personal_sign"
+	req.Request.Params = []any{"0x68656c6c6f", "0xab16a96D359eC26a11e2C2b3d8f8B8942d5Bfcdb"}
+	id := dapp.send(sessTopic, "wc_sessionRequest", &req)
+	_, msg = dapp.recv()
+	if msg.Id != id || string(msg.Result) != `"0x1234"` {
+		t.Errorf("unexpected personal_sign response %+v", msg)
+	}
+
+	req.Request.Method = "eth_sendTransaction"
+	id = dapp.send(sessTopic, "wc_sessionRequest", &req)
+	_, msg = dapp.recv()
+	if msg.Id != id || msg.Error == nil || msg.Error.Code != 4001 {
+		t.Errorf("expected rejection, got %+v", msg)
+	}
+
+	req.Request.Method = "eth_sign"
+	dapp.send(sessTopic, "wc_sessionRequest", &req)
+	_, msg = dapp.recv()
+	if msg.Error == nil || msg.Error.Code != ErrUnsupportedMethods.Code {
+		t.Errorf("expected unsupported method error, got %+v", msg)
+	}
+
+	req.Request.Method = "personal_sign"
+	req.ChainId = "eip155:137"
+	dapp.send(sessTopic, "wc_sessionRequest", &req)
+	_, msg = dapp.recv()
+	if msg.Error == nil || msg.Error.Code != ErrUnsupportedChains.Code {
+		t.Errorf("expected unsupported chain error, got %+v", msg)
+	}
+
+	// a new client restores the session from the env
+	c2, err := NewClient(ctx, e, newMemHub().connect(), nil, h)
+	if err != nil {
+		t.Fatalf("failed to create client: %s", err)
+	}
+	if s := c2.Sessions(); len(s) != 1 || s[0].Topic != sessTopic || !reflect.DeepEqual(s[0].SymKey, sessKey) {
+		t.Errorf("session was not restored")
+	}
+
+	// dApp deletes the session
+	dapp.send(sessTopic, "wc_sessionDelete", &deleteParams{Code: 6000, Message: "User disconnected."})
+	_, msg = dapp.recv()
+	if string(msg.Result) != "true" {
+		t.Errorf("unexpected delete response %+v", msg)
+	}
+	select {
+	case s := <-h.deleted:
+		if s.Topic != sessTopic {
+			t.Errorf("wrong session deleted")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("handler was not notified of session deletion")
+	}
+	if len(c.Sessions()) != 0 || len(e.sessions) != 0 {
+		t.Errorf("session should have been removed")
+	}
+
+	if err := c.Disconnect(ctx, sessTopic); err == nil {
+		t.Errorf("disconnecting a deleted session should fail")
+	}
+}
+
+func TestClientRejectedProposal(t *testing.T) {
+	ctx := context.Background()
+	hub := newMemHub()
+	e := &memEnv{pairings: make(map[string]*Pairing), sessions: make(map[string]*Session)}
+
+	c, err := NewClient(ctx, e, hub.connect(), nil, &testHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	symKey := make([]byte, 32)
+	topic := make([]byte, 32)
+	rand.Read(symKey)
+	rand.Read(topic)
+	uri := &PairingURI{Topic: hex.EncodeToString(topic), Version: 2, SymKey: symKey, Relay: "irn"}
+	dapp := &testDApp{t: t, relay: hub.connect(), keys: map[string][]byte{uri.Topic: symKey}}
+	dapp.relay.Subscribe(ctx, uri.Topic)
+	if _, err := c.Pair(ctx, uri.String()); err != nil {
+		t.Fatal(err)
+	}
+
+	dappKey, _ := GenerateKey()
+	dapp.send(uri.Topic, "wc_sessionPropose", &Proposal{
+		Proposer: &Participant{
+			PublicKey: hex.EncodeToString(dappKey.PublicKey().Bytes()),
+			Metadata:  &Metadata{Name: "Evil", URL: "https://evil.example.com"},
+		},
+	})
+	_, msg := dapp.recv()
+	if msg.Error == nil || msg.Error.Code != ErrUserRejected.Code {
+		t.Errorf("expected user rejected error, got %+v", msg)
+	}
+	if len(c.Sessions()) != 0 {
+		t.Errorf("no session should have been created")
+	}
+	if !errors.Is(newError(1, ErrUserRejected).Error, ErrUserRejected) {
+		t.Errorf("newError should keep RPC errors as is")
+	}
+}
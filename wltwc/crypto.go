@@ -0,0 +1,95 @@
+package wltwc
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+// envelope types
+const (
+	envelopeType0 = 0 // sealed with a symmetric key both sides know
+	envelopeType1 = 1 // includes the sender's public key, used when the receiver does not know it yet
+)
+
+// GenerateKey returns a new X25519 key pair used to derive a session key with a peer
+func GenerateKey() (*ecdh.PrivateKey, error) {
+	return ecdh.X25519().GenerateKey(rand.Reader)
+}
+
+// DeriveSymKey returns the symmetric key shared with a peer: HKDF-SHA256 of the X25519 shared secret
+func DeriveSymKey(priv *ecdh.PrivateKey, peerPublic []byte) ([]byte, error) {
+	pub, err := ecdh.X25519().NewPublicKey(peerPublic)
+	if err != nil {
+		return nil, fmt.Errorf("walletconnect: invalid peer public key: %w", err)
+	}
+	shared, err := priv.ECDH(pub)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, nil, nil), res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Topic returns the topic associated with a symmetric key, which is the hex encoded sha256 of the key
+func Topic(symKey []byte) string {
+	h := sha256.Sum256(symKey)
+	return hex.EncodeToString(h[:])
+}
+
+// Encrypt seals msg in a type 0 envelope and returns it base64 encoded
+func Encrypt(symKey, msg []byte) (string, error) {
+	aead, err := chacha20poly1305.New(symKey)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	buf := append([]byte{envelopeType0}, iv...)
+	buf = aead.Seal(buf, iv, msg, nil)
+	return base64.StdEncoding.EncodeToString(buf), nil
+}
+
+// Decrypt opens a base64 encoded type 0 envelope
+func Decrypt(symKey []byte, envelope string) ([]byte, error) {
+	buf, err := base64.StdEncoding.DecodeString(envelope)
+	if err != nil {
+		return nil, fmt.Errorf("walletconnect: invalid envelope: %w", err)
+	}
+	if len(buf) == 0 {
+		return nil, errors.New("walletconnect: empty envelope")
+	}
+	switch buf[0] {
+	case envelopeType0:
+		buf = buf[1:]
+	case envelopeType1:
+		return nil, errors.New("walletconnect: type 1 envelopes are not supported")
+	default:
+		return nil, fmt.Errorf("walletconnect: unknown envelope type %d", buf[0])
+	}
+	aead, err := chacha20poly1305.New(symKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) < aead.NonceSize()+aead.Overhead() {
+		return nil, errors.New("walletconnect: envelope too short")
+	}
+	res, err := aead.Open(nil, buf[:aead.NonceSize()], buf[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("walletconnect: failed to decrypt envelope: %w", err)
+	}
+	return res, nil
+}
@@ -0,0 +1,8 @@
+package wltwc
+
+import "github.com/EllipX/libwallet/wltintf"
+
+func InitEnv(e wltintf.Env) {
+	e.AutoMigrate(&Pairing{})
+	e.AutoMigrate(&Session{})
+}
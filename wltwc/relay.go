@@ -0,0 +1,29 @@
+package wltwc
+
+import (
+	"context"
+	"time"
+)
+
+// Relay is the transport used to exchange messages with dApps. WsRelay connects to the WalletConnect relay
+// network, other implementations can be used for testing.
+type Relay interface {
+	// Subscribe starts receiving messages published on topic
+	Subscribe(ctx context.Context, topic string) error
+	// Unsubscribe stops receiving messages published on topic
+	Unsubscribe(ctx context.Context, topic string) error
+	// Publish sends an (encrypted, base64 encoded) message on topic
+	Publish(ctx context.Context, topic, message string, ttl time.Duration, tag int) error
+	// Messages returns the channel on which messages for subscribed topics are received. It is closed
+	// when the relay is disconnected.
+	Messages() <-chan *RelayMessage
+	Close() error
+}
+
+// RelayMessage is a message received from the relay
+type RelayMessage struct {
+	Topic       string `json:"topic"`
+	Message     string `json:"message"`
+	PublishedAt int64  `json:"publishedAt"`
+	Tag         int    `json:"tag"`
+}
@@ -0,0 +1,90 @@
+package wltwc
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"time"
+)
+
+// Message is a JSON-RPC message, exchanged with the relay or (encrypted) with a peer
+type Message struct {
+	Id      int64           `json:"id"`
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// RPCError is a JSON-RPC error
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+// error codes defined by the WalletConnect specs
+var (
+	ErrUserRejected       = &RPCError{Code: 5000, Message: "User rejected."}
+	ErrUnsupportedChains  = &RPCError{Code: 5100, Message: "Unsupported chains."}
+	ErrUnsupportedMethods = &RPCError{Code: 5101, Message: "Unsupported methods."}
+	ErrUserDisconnected   = &RPCError{Code: 6000, Message: "User disconnected."}
+)
+
+// methodInfo contains the relay tags & ttl of each method, see https://specs.walletconnect.com/2.0/specs/clients/sign/rpc-methods
+type methodInfo struct {
+	reqTag, respTag int
+	ttl             time.Duration
+}
+
+var methods = map[string]*methodInfo{
+	"wc_pairingDelete":  {1000, 1001, 24 * time.Hour},
+	"wc_pairingPing":    {1002, 1003, 30 * time.Second},
+	"wc_sessionPropose": {1100, 1101, 5 * time.Minute},
+	"wc_sessionSettle":  {1102, 1103, 5 * time.Minute},
+	"wc_sessionUpdate":  {1104, 1105, 24 * time.Hour},
+	"wc_sessionExtend":  {1106, 1107, 24 * time.Hour},
+	"wc_sessionRequest": {1108, 1109, 5 * time.Minute},
+	"wc_sessionEvent":   {1110, 1111, 5 * time.Minute},
+	"wc_sessionDelete":  {1112, 1113, 24 * time.Hour},
+	"wc_sessionPing":    {1114, 1115, 30 * time.Second},
+}
+
+// payloadId returns a new JSON-RPC id, as generated by WalletConnect clients: current time in ms followed by 3 random digits
+func payloadId() int64 {
+	n, _ := rand.Int(rand.Reader, big.NewInt(1000))
+	return time.Now().UnixMilli()*1000 + n.Int64()
+}
+
+func newRequest(method string, params any) (*Message, error) {
+	buf, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{Id: payloadId(), JSONRPC: "2.0", Method: method, Params: buf}, nil
+}
+
+func newResult(id int64, result any) (*Message, error) {
+	buf, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{Id: id, JSONRPC: "2.0", Result: buf}, nil
+}
+
+func newError(id int64, err error) *Message {
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) {
+		rpcErr = &RPCError{Code: -32000, Message: err.Error()}
+	}
+	return &Message{Id: id, JSONRPC: "2.0", Error: rpcErr}
+}
+
+func (m *Message) isResponse() bool {
+	return m.Method == ""
+}
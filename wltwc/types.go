@@ -0,0 +1,120 @@
+package wltwc
+
+import (
+	"net/url"
+	"time"
+)
+
+// Metadata describes a dApp or wallet
+type Metadata struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	URL         string   `json:"url"`
+	Icons       []string `json:"icons"`
+}
+
+// Participant is one side of a session
+type Participant struct {
+	PublicKey string    `json:"publicKey"`
+	Metadata  *Metadata `json:"metadata"`
+}
+
+// Namespace lists chains (CAIP-2), methods, events and accounts (CAIP-10) of a blockchain family (e.g. eip155)
+type Namespace struct {
+	Chains   []string `json:"chains,omitempty"`
+	Methods  []string `json:"methods"`
+	Events   []string `json:"events"`
+	Accounts []string `json:"accounts,omitempty"`
+}
+
+type relayProtocol struct {
+	Protocol string `json:"protocol"`
+}
+
+// Proposal is a session proposal received from a dApp
+type Proposal struct {
+	Id                 int64                 `json:"id"`           // JSON-RPC id of the proposal
+	PairingTopic       string                `json:"pairingTopic"` // topic the proposal was received on
+	Relays             []*relayProtocol      `json:"relays"`
+	Proposer           *Participant          `json:"proposer"`
+	RequiredNamespaces map[string]*Namespace `json:"requiredNamespaces"`
+	OptionalNamespaces map[string]*Namespace `json:"optionalNamespaces,omitempty"`
+	ExpiryTimestamp    int64                 `json:"expiryTimestamp,omitempty"`
+}
+
+// Host returns the origin of the dApp, as scheme://host
+func (p *Proposal) Host() string {
+	if p.Proposer == nil || p.Proposer.Metadata == nil {
+		return ""
+	}
+	return originOf(p.Proposer.Metadata.URL)
+}
+
+type proposalResponse struct {
+	Relay              *relayProtocol `json:"relay"`
+	ResponderPublicKey string         `json:"responderPublicKey"`
+}
+
+type settleParams struct {
+	Relay      *relayProtocol        `json:"relay"`
+	Namespaces map[string]*Namespace `json:"namespaces"`
+	Controller *Participant          `json:"controller"`
+	Expiry     int64                 `json:"expiry"`
+}
+
+type sessionRequestParams struct {
+	Request struct {
+		Method string `json:"method"`
+		Params any    `json:"params"` // usually an array, but can be an object
+	} `json:"request"`
+	ChainId string `json:"chainId"`
+}
+
+type sessionEventParams struct {
+	Event struct {
+		Name string `json:"name"`
+		Data any    `json:"data"`
+	} `json:"event"`
+	ChainId string `json:"chainId"`
+}
+
+type deleteParams struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Pairing is a pairing established from a wc: URI. Proposals for new sessions are received on its topic.
+type Pairing struct {
+	Topic   string    `json:"topic" gorm:"primaryKey"`
+	SymKey  []byte    `json:"-"`
+	Expiry  time.Time `json:"expiry"`
+	Peer    *Metadata `json:"peer,omitempty" gorm:"serializer:json"`
+	Created time.Time `json:"created" gorm:"autoCreateTime"`
+}
+
+// Session is a session settled with a dApp
+type Session struct {
+	Topic        string                `json:"topic" gorm:"primaryKey"`
+	PairingTopic string                `json:"pairing_topic"`
+	SymKey       []byte                `json:"-"`
+	Peer         *Participant          `json:"peer" gorm:"serializer:json"`
+	Namespaces   map[string]*Namespace `json:"namespaces" gorm:"serializer:json"`
+	Expiry       time.Time             `json:"expiry"`
+	Created      time.Time             `json:"created" gorm:"autoCreateTime"`
+}
+
+// Host returns the origin of the dApp, as scheme://host
+func (s *Session) Host() string {
+	if s.Peer == nil || s.Peer.Metadata == nil {
+		return ""
+	}
+	return originOf(s.Peer.Metadata.URL)
+}
+
+func originOf(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return (&url.URL{Scheme: u.Scheme, Host: u.Host}).String()
+}
@@ -0,0 +1,86 @@
+package wltwc
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PairingURI is a WalletConnect v2 pairing URI, as displayed by dApps in QR codes:
+// wc:{topic}@2?symKey={key}&relay-protocol=irn&expiryTimestamp={ts}
+type PairingURI struct {
+	Topic   string
+	Version int
+	SymKey  []byte
+	Relay   string    // relay protocol, normally irn
+	Expiry  time.Time // zero if not specified
+	Methods []string  // methods the dApp expects the wallet to support, if specified
+}
+
+// ParseURI parses a wc: pairing URI
+func ParseURI(s string) (*PairingURI, error) {
+	v, ok := strings.CutPrefix(strings.TrimSpace(s), "wc:")
+	if !ok {
+		return nil, errors.New("walletconnect: uri must start with wc:")
+	}
+	v = strings.TrimPrefix(v, "//")
+	path, query, _ := strings.Cut(v, "?")
+	topic, version, ok := strings.Cut(path, "@")
+	if !ok || topic == "" {
+		return nil, errors.New("walletconnect: invalid uri, topic and version are required")
+	}
+	res := &PairingURI{Topic: topic, Relay: "irn"}
+	var err error
+	res.Version, err = strconv.Atoi(version)
+	if err != nil {
+		return nil, fmt.Errorf("walletconnect: invalid version %s", version)
+	}
+	if res.Version != 2 {
+		return nil, fmt.Errorf("walletconnect: unsupported version %d", res.Version)
+	}
+	if _, err := hex.DecodeString(topic); err != nil || len(topic) != 64 {
+		return nil, errors.New("walletconnect: invalid topic")
+	}
+
+	q, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("walletconnect: invalid uri: %w", err)
+	}
+	res.SymKey, err = hex.DecodeString(q.Get("symKey"))
+	if err != nil || len(res.SymKey) != 32 {
+		return nil, errors.New("walletconnect: invalid or missing symKey")
+	}
+	if p := q.Get("relay-protocol"); p != "" {
+		res.Relay = p
+	}
+	if ts := q.Get("expiryTimestamp"); ts != "" {
+		v, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("walletconnect: invalid expiryTimestamp %s", ts)
+		}
+		res.Expiry = time.Unix(v, 0)
+	}
+	if m := q.Get("methods"); m != "" {
+		for _, s := range strings.Split(m, ",") {
+			if s = strings.Trim(s, "[] "); s != "" {
+				res.Methods = append(res.Methods, s)
+			}
+		}
+	}
+	return res, nil
+}
+
+// String returns the URI in its wc: form
+func (u *PairingURI) String() string {
+	q := url.Values{}
+	q.Set("symKey", hex.EncodeToString(u.SymKey))
+	q.Set("relay-protocol", u.Relay)
+	if !u.Expiry.IsZero() {
+		q.Set("expiryTimestamp", strconv.FormatInt(u.Expiry.Unix(), 10))
+	}
+	return "wc:" + u.Topic + "@" + strconv.Itoa(u.Version) + "?" + q.Encode()
+}
@@ -0,0 +1,95 @@
+package wltwc
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestParseURI(t *testing.T) {
+	const uri = "wc:7f6e504bfad60b485450578e05678ed3e8e8c4751d3c6160be17160d63ec90f9@2?relay-protocol=irn&symKey=587d5484ce2a2a6ee3ba1962fdd7e8588e06200c46823bd18fbd67def96ad303&expiryTimestamp=1705500000&methods=[wc_sessionPropose],[wc_authRequest,wc_authBatchRequest]"
+
+	u, err := ParseURI(uri)
+	if err != nil {
+		t.Fatalf("failed to parse uri: %s", err)
+	}
+	if u.Topic != "7f6e504bfad60b485450578e05678ed3e8e8c4751d3c6160be17160d63ec90f9" {
+		t.Errorf("unexpected topic %s", u.Topic)
+	}
+	if u.Version != 2 || u.Relay != "irn" {
+		t.Errorf("unexpected version/relay %d/%s", u.Version, u.Relay)
+	}
+	if len(u.SymKey) != 32 || u.SymKey[0] != 0x58 {
+		t.Errorf("unexpected symKey %x", u.SymKey)
+	}
+	if !u.Expiry.Equal(time.Unix(1705500000, 0)) {
+		t.Errorf("unexpected expiry %s", u.Expiry)
+	}
+	if len(u.Methods) != 3 || u.Methods[0] != "wc_sessionPropose" || u.Methods[2] != "wc_authBatchRequest" {
+		t.Errorf("unexpected methods %v", u.Methods)
+	}
+
+	// String() output should parse back to the same values
+	u2, err := ParseURI(u.String())
+	if err != nil {
+		t.Fatalf("failed to parse generated uri: %s", err)
+	}
+	if u2.Topic != u.Topic || !bytes.Equal(u2.SymKey, u.SymKey) || !u2.Expiry.Equal(u.Expiry) {
+		t.Errorf("roundtrip mismatch: %s", u.String())
+	}
+
+	bad := []string{
+		"https://example.com",
+		"wc:7f6e504bfad60b485450578e05678ed3e8e8c4751d3c6160be17160d63ec90f9@1?symKey=587d5484ce2a2a6ee3ba1962fdd7e8588e06200c46823bd18fbd67def96ad303",
+		"wc:7f6e504bfad60b485450578e05678ed3e8e8c4751d3c6160be17160d63ec90f9@2",
+		"wc:7f6e@2?symKey=587d5484ce2a2a6ee3ba1962fdd7e8588e06200c46823bd18fbd67def96ad303",
+		"wc:7f6e504bfad60b485450578e05678ed3e8e8c4751d3c6160be17160d63ec90f9@2?symKey=587d",
+	}
+	for _, s := range bad {
+		if _, err := ParseURI(s); err == nil {
+			t.Errorf("expected error parsing %s", s)
+		}
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	a, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k1, err := DeriveSymKey(a, b.PublicKey().Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	k2, err := DeriveSymKey(b, a.PublicKey().Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(k1, k2) {
+		t.Fatalf("both sides should derive the same key")
+	}
+	if len(Topic(k1)) != 64 {
+		t.Errorf("unexpected topic %s", Topic(k1))
+	}
+
+	env, err := Encrypt(k1, []byte(`{"hello":"world"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Decrypt(k2, env)
+	if err != nil {
+		t.Fatalf("failed to decrypt: %s", err)
+	}
+	if string(res) != `{"hello":"world"}` {
+		t.Errorf("unexpected decrypted value %s", res)
+	}
+
+	k2[0] ^= 1
+	if _, err := Decrypt(k2, env); err == nil {
+		t.Errorf("decrypt with a bad key should fail")
+	}
+}
@@ -0,0 +1,205 @@
+package wltwc
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+// DefaultRelayURL is the WalletConnect relay server
+const DefaultRelayURL = "wss://relay.walletconnect.com"
+
+// WsRelay is a Relay using the WalletConnect relay JSON-RPC protocol (irn_*) over a websocket
+type WsRelay struct {
+	conn    *websocket.Conn
+	ctx     context.Context
+	cancel  context.CancelFunc
+	msgs    chan *RelayMessage
+	pending map[int64]chan *Message
+	subs    map[string]string // topic → subscription id
+	lk      sync.Mutex
+	wlk     sync.Mutex
+}
+
+// DialRelay connects to the relay at relayURL. If projectId is not empty, it is passed to the relay along
+// with an auth token signed with key.
+func DialRelay(ctx context.Context, relayURL, projectId string, key ed25519.PrivateKey) (*WsRelay, error) {
+	u, err := url.Parse(relayURL)
+	if err != nil {
+		return nil, err
+	}
+	if projectId != "" {
+		// the audience of the token is the relay url without query
+		aud := (&url.URL{Scheme: "https", Host: u.Host}).String()
+		tok, err := relayAuthToken(key, aud, 24*time.Hour)
+		if err != nil {
+			return nil, err
+		}
+		q := u.Query()
+		q.Set("auth", tok)
+		q.Set("projectId", projectId)
+		u.RawQuery = q.Encode()
+	}
+
+	conn, _, err := websocket.Dial(ctx, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("walletconnect: failed to connect to relay: %w", err)
+	}
+	conn.SetReadLimit(1 << 20)
+
+	r := &WsRelay{
+		conn:    conn,
+		msgs:    make(chan *RelayMessage, 32),
+		pending: make(map[int64]chan *Message),
+		subs:    make(map[string]string),
+	}
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	go r.readLoop()
+	return r, nil
+}
+
+func (r *WsRelay) readLoop() {
+	defer close(r.msgs)
+	defer r.cancel()
+
+	for {
+		var msg *Message
+		if err := wsjson.Read(r.ctx, r.conn, &msg); err != nil {
+			if r.ctx.Err() == nil {
+				log.Printf("walletconnect: relay connection lost: %s", err)
+			}
+			return
+		}
+		if msg == nil {
+			continue
+		}
+		if !msg.isResponse() {
+			r.handleRequest(msg)
+			continue
+		}
+		r.lk.Lock()
+		ch, ok := r.pending[msg.Id]
+		delete(r.pending, msg.Id)
+		r.lk.Unlock()
+		if ok {
+			ch <- msg
+		}
+	}
+}
+
+func (r *WsRelay) handleRequest(msg *Message) {
+	switch msg.Method {
+	case "irn_subscription":
+		var params struct {
+			Id   string        `json:"id"`
+			Data *RelayMessage `json:"data"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil || params.Data == nil {
+			r.send(newError(msg.Id, errors.New("invalid params")))
+			return
+		}
+		// acknowledge first so the relay doesn't send the message again
+		if res, err := newResult(msg.Id, true); err == nil {
+			r.send(res)
+		}
+		select {
+		case r.msgs <- params.Data:
+		case <-r.ctx.Done():
+		}
+	default:
+		r.send(newError(msg.Id, fmt.Errorf("unsupported method %s", msg.Method)))
+	}
+}
+
+func (r *WsRelay) send(msg *Message) error {
+	r.wlk.Lock()
+	defer r.wlk.Unlock()
+	return wsjson.Write(r.ctx, r.conn, msg)
+}
+
+// call sends a request to the relay and waits for the response
+func (r *WsRelay) call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	msg, err := newRequest(method, params)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan *Message, 1)
+	r.lk.Lock()
+	r.pending[msg.Id] = ch
+	r.lk.Unlock()
+	defer func() {
+		r.lk.Lock()
+		delete(r.pending, msg.Id)
+		r.lk.Unlock()
+	}()
+
+	if err := r.send(msg); err != nil {
+		return nil, err
+	}
+	select {
+	case res := <-ch:
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		return res.Result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-r.ctx.Done():
+		return nil, errors.New("walletconnect: relay connection closed")
+	}
+}
+
+func (r *WsRelay) Subscribe(ctx context.Context, topic string) error {
+	res, err := r.call(ctx, "irn_subscribe", map[string]any{"topic": topic})
+	if err != nil {
+		return err
+	}
+	var id string
+	if err := json.Unmarshal(res, &id); err != nil {
+		return fmt.Errorf("walletconnect: invalid subscription id: %w", err)
+	}
+	r.lk.Lock()
+	r.subs[topic] = id
+	r.lk.Unlock()
+	return nil
+}
+
+func (r *WsRelay) Unsubscribe(ctx context.Context, topic string) error {
+	r.lk.Lock()
+	id, ok := r.subs[topic]
+	delete(r.subs, topic)
+	r.lk.Unlock()
+	if !ok {
+		return nil
+	}
+	_, err := r.call(ctx, "irn_unsubscribe", map[string]any{"topic": topic, "id": id})
+	return err
+}
+
+func (r *WsRelay) Publish(ctx context.Context, topic, message string, ttl time.Duration, tag int) error {
+	_, err := r.call(ctx, "irn_publish", map[string]any{
+		"topic":   topic,
+		"message": message,
+		"ttl":     int64(ttl / time.Second),
+		"tag":     tag,
+	})
+	return err
+}
+
+func (r *WsRelay) Messages() <-chan *RelayMessage {
+	return r.msgs
+}
+
+func (r *WsRelay) Close() error {
+	r.cancel()
+	return r.conn.Close(websocket.StatusNormalClosure, "")
+}
@@ -0,0 +1,137 @@
+package wltwc
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+// testRelayServer is a minimal stand-in for the relay: published messages are delivered back to the
+// connection if it subscribed to the topic
+func testRelayServer(t *testing.T, key ed25519.PublicKey) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("projectId") != "test-project" {
+			http.Error(w, "missing projectId", http.StatusUnauthorized)
+			return
+		}
+		// check the auth token signature
+		parts := strings.Split(r.URL.Query().Get("auth"), ".")
+		if len(parts) != 3 {
+			http.Error(w, "invalid auth", http.StatusUnauthorized)
+			return
+		}
+		sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		if !ed25519.Verify(key, []byte(parts[0]+"."+parts[1]), sig) {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		if !strings.Contains(string(claims), `"iss":"did:key:z6Mk`) {
+			http.Error(w, "bad issuer", http.StatusUnauthorized)
+			return
+		}
+
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.CloseNow()
+		ctx := r.Context()
+		subs := make(map[string]bool)
+
+		for {
+			var msg *Message
+			if err := wsjson.Read(ctx, conn, &msg); err != nil {
+				return
+			}
+			if msg.isResponse() {
+				// ack of irn_subscription
+				continue
+			}
+			var params map[string]any
+			json.Unmarshal(msg.Params, &params)
+			topic, _ := params["topic"].(string)
+
+			var res any = true
+			var ev *Message
+			switch msg.Method {
+			case "irn_subscribe":
+				subs[topic] = true
+				res = "sub-" + topic
+			case "irn_unsubscribe":
+				delete(subs, topic)
+			case "irn_publish":
+				if subs[topic] {
+					ev, _ = newRequest("irn_subscription", map[string]any{
+						"id": "sub-" + topic,
+						"data": &RelayMessage{
+							Topic:       topic,
+							Message:     params["message"].(string),
+							PublishedAt: time.Now().UnixMilli(),
+							Tag:         int(params["tag"].(float64)),
+						},
+					})
+				}
+			default:
+				wsjson.Write(ctx, conn, newError(msg.Id, ErrUnsupportedMethods))
+				continue
+			}
+			resp, _ := newResult(msg.Id, res)
+			wsjson.Write(ctx, conn, resp)
+			if ev != nil {
+				wsjson.Write(ctx, conn, ev)
+			}
+		}
+	}))
+}
+
+func TestWsRelay(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	srv := testRelayServer(t, pub)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	if _, err := DialRelay(ctx, url, "", priv); err == nil {
+		t.Errorf("dial without project id should have been refused by the test server")
+	}
+
+	r, err := DialRelay(ctx, url, "test-project", priv)
+	if err != nil {
+		t.Fatalf("failed to dial: %s", err)
+	}
+	defer r.Close()
+
+	if err := r.Subscribe(ctx, "topic1"); err != nil {
+		t.Fatalf("failed to subscribe: %s", err)
+	}
+	if err := r.Publish(ctx, "topic1", "hello", time.Minute, 1108); err != nil {
+		t.Fatalf("failed to publish: %s", err)
+	}
+	select {
+	case m := <-r.Messages():
+		if m.Topic != "topic1" || m.Message != "hello" || m.Tag != 1108 {
+			t.Errorf("unexpected message %+v", m)
+		}
+	case <-ctx.Done():
+		t.Fatalf("timed out waiting for message")
+	}
+
+	if err := r.Unsubscribe(ctx, "topic1"); err != nil {
+		t.Errorf("failed to unsubscribe: %s", err)
+	}
+	if _, err := r.call(ctx, "irn_fetchMessages", nil); err == nil {
+		t.Errorf("expected error for unsupported method")
+	}
+}